@@ -15,13 +15,17 @@ import (
 
 	"github.com/prepmyapp/notification/internal/config"
 	"github.com/prepmyapp/notification/internal/database"
+	"github.com/prepmyapp/notification/internal/domain"
 	"github.com/prepmyapp/notification/internal/handler"
 	"github.com/prepmyapp/notification/internal/handler/middleware"
 	"github.com/prepmyapp/notification/internal/infrastructure/firebase"
 	"github.com/prepmyapp/notification/internal/infrastructure/sendgrid"
+	"github.com/prepmyapp/notification/internal/infrastructure/smtp"
 	"github.com/prepmyapp/notification/internal/infrastructure/websocket"
 	"github.com/prepmyapp/notification/internal/repository/postgres"
 	"github.com/prepmyapp/notification/internal/service"
+	"github.com/prepmyapp/notification/internal/templates"
+	"github.com/prepmyapp/notification/internal/workflows"
 )
 
 func main() {
@@ -41,8 +45,14 @@ func main() {
 	// Initialize database (optional - service works without DB for basic health checks)
 	var db *database.DB
 	var notificationRepo *postgres.NotificationRepository
+	var deliveryJobRepo *postgres.DeliveryJobRepository
 	var deviceTokenRepo *postgres.DeviceTokenRepository
 	var preferencesRepo *postgres.PreferencesRepository
+	var idempotencyRepo *postgres.IdempotencyRepository
+	var workflowRunRepo *postgres.WorkflowRunRepository
+	var wsTicketRepo *postgres.WebSocketTicketRepository
+	var notificationEventRepo *postgres.NotificationEventRepository
+	var suppressionRepo *postgres.SuppressionRepository
 
 	if cfg.Database.URL != "" {
 		dbConfig := database.DefaultConfig(cfg.Database.URL)
@@ -52,24 +62,105 @@ func main() {
 		} else {
 			log.Println("Connected to database")
 			notificationRepo = postgres.NewNotificationRepository(db.Pool)
+			deliveryJobRepo = postgres.NewDeliveryJobRepository(db.Pool)
 			deviceTokenRepo = postgres.NewDeviceTokenRepository(db.Pool)
 			preferencesRepo = postgres.NewPreferencesRepository(db.Pool)
+			idempotencyRepo = postgres.NewIdempotencyRepository(db.Pool)
+			workflowRunRepo = postgres.NewWorkflowRunRepository(db.Pool)
+			wsTicketRepo = postgres.NewWebSocketTicketRepository(db.Pool)
+			notificationEventRepo = postgres.NewNotificationEventRepository(db.Pool)
+			suppressionRepo = postgres.NewSuppressionRepository(db.Pool)
 		}
 	}
 
-	// Initialize SendGrid client (optional)
+	// Load email templates (embedded, optionally overridden from TEMPLATES_DIR)
+	templateRegistry, err := templates.Load(cfg.Templates.Dir)
+	if err != nil {
+		log.Fatalf("Failed to load templates: %v", err)
+	}
+	log.Printf("Loaded %d email templates", len(templateRegistry.Names()))
+
+	// Initialize the email providers (optional)
+	emailSenders := make(map[string]service.EmailSender)
+	for _, route := range cfg.Email.Routes {
+		switch route.Name {
+		case "sendgrid":
+			emailSenders[route.Name] = sendgrid.NewClient(sendgrid.Config{
+				APIKey:    cfg.SendGrid.APIKey,
+				FromEmail: cfg.SendGrid.FromEmail,
+				FromName:  cfg.SendGrid.FromName,
+			})
+			log.Println("SendGrid client initialized")
+		case "smtp":
+			smtpClient, err := smtp.NewClient(smtp.Config{
+				Host:         cfg.SMTP.Host,
+				Port:         cfg.SMTP.Port,
+				Username:     cfg.SMTP.Username,
+				Password:     cfg.SMTP.Password,
+				Security:     cfg.SMTP.Security,
+				FromEmail:    cfg.SMTP.FromEmail,
+				FromName:     cfg.SMTP.FromName,
+				Timeout:      time.Duration(cfg.SMTP.Timeout) * time.Second,
+				MaxIdleConns: cfg.SMTP.MaxIdleConns,
+			}, templateRegistry)
+			if err != nil {
+				log.Fatalf("Failed to initialize SMTP client: %v", err)
+			}
+			emailSenders[route.Name] = smtpClient
+			log.Printf("SMTP client initialized (%s:%d)", cfg.SMTP.Host, cfg.SMTP.Port)
+		}
+	}
+
+	// With several providers, route between them and fail over on outages
 	var emailSender service.EmailSender
-	if cfg.SendGrid.APIKey != "" {
-		emailSender = sendgrid.NewClient(sendgrid.Config{
-			APIKey:    cfg.SendGrid.APIKey,
-			FromEmail: cfg.SendGrid.FromEmail,
-			FromName:  cfg.SendGrid.FromName,
+	if len(cfg.Email.Routes) == 1 {
+		emailSender = emailSenders[cfg.Email.Routes[0].Name]
+	} else if len(cfg.Email.Routes) > 1 {
+		providers := make([]service.EmailProvider, 0, len(cfg.Email.Routes))
+		for _, route := range cfg.Email.Routes {
+			providers = append(providers, service.EmailProvider{
+				Name:     route.Name,
+				Sender:   emailSenders[route.Name],
+				Priority: route.Priority,
+				Weight:   route.Weight,
+			})
+		}
+		emailSender = service.NewEmailRouter(providers, service.EmailRouterConfig{
+			FailureThreshold: cfg.Email.BreakerThreshold,
+			Cooldown:         time.Duration(cfg.Email.BreakerCooldown) * time.Second,
 		})
-		log.Println("SendGrid client initialized")
+		log.Printf("Email router initialized (%s)", cfg.Email.Provider)
 	}
 
-	// Initialize WebSocket hub
-	wsHub := websocket.NewHub()
+	// Initialize the broker that fans in-app notifications out to every instance
+	var broker websocket.Broker
+	brokerKind := cfg.Realtime.Broker
+	if brokerKind == "" && cfg.Redis.URL != "" {
+		brokerKind = "redis"
+	}
+	switch brokerKind {
+	case "", "memory":
+	case "redis":
+		redisBroker, err := websocket.NewRedisBrokerFromURL(ctx, cfg.Redis.URL)
+		if err != nil {
+			log.Printf("Warning: Failed to connect to Redis, in-app notifications only reach this instance: %v", err)
+		} else {
+			broker = redisBroker
+			log.Println("Redis broker initialized")
+		}
+	case "postgres":
+		if db == nil {
+			log.Println("Warning: Postgres broker needs a database, in-app notifications only reach this instance")
+		} else {
+			broker = websocket.NewPostgresBroker(db.Pool, notificationRepo, "")
+			log.Println("Postgres LISTEN/NOTIFY broker initialized")
+		}
+	default:
+		log.Fatalf("Unknown REALTIME_BROKER %q (want memory, redis or postgres)", brokerKind)
+	}
+
+	// Initialize WebSocket hub (in-memory broker unless another one is configured)
+	wsHub := websocket.NewHub(broker, cfg.Realtime.MaxConnectionsPerUser)
 	go wsHub.Run()
 	log.Println("WebSocket hub started")
 
@@ -88,20 +179,101 @@ func main() {
 		}
 	}
 
+	// Load workflow definitions (embedded)
+	workflowRegistry, err := workflows.Load()
+	if err != nil {
+		log.Fatalf("Failed to load workflows: %v", err)
+	}
+	log.Printf("Loaded %d workflows", len(workflowRegistry.Names()))
+
+	// Unsubscribe links need a signing secret and a public URL to point to
+	var unsubscribeService *service.UnsubscribeService
+	if cfg.Unsubscribe.Enabled() && preferencesRepo != nil {
+		unsubscribeService = service.NewUnsubscribeService(preferencesRepo, cfg.Unsubscribe.Secret, cfg.Unsubscribe.BaseURL)
+		log.Println("Unsubscribe links enabled")
+	}
+
 	// Initialize notification service
 	var notificationService *service.NotificationService
 	if notificationRepo != nil {
 		notificationService = service.NewNotificationService(
 			notificationRepo,
+			deliveryJobRepo,
 			deviceTokenRepo,
 			preferencesRepo,
+			suppressionRepo,
 			emailSender,
 			pushSender,
 			wsHub,
+			templateRegistry,
+			unsubscribeService,
 		)
 		log.Println("Notification service initialized")
 	}
 
+	// Start delivery workers (drain the outbox written by NotificationService.Send)
+	var deliveryWorkers *service.DeliveryWorkerPool
+	if notificationService != nil {
+		deliveryWorkers = service.NewDeliveryWorkerPool(notificationService, deliveryJobRepo, service.DeliveryWorkerConfig{
+			Workers:      cfg.Delivery.Workers,
+			BatchSize:    cfg.Delivery.BatchSize,
+			PollInterval: time.Duration(cfg.Delivery.PollIntervalMs) * time.Millisecond,
+			LockTimeout:  time.Duration(cfg.Delivery.LockTimeout) * time.Second,
+			Retry: service.RetryPolicy{
+				MaxAttempts: cfg.Delivery.MaxAttempts,
+				BaseDelay:   time.Duration(cfg.Delivery.RetryBaseDelay) * time.Second,
+				MaxDelay:    time.Duration(cfg.Delivery.RetryMaxDelay) * time.Second,
+			},
+		})
+		deliveryWorkers.Start()
+		log.Printf("Delivery workers started (%d workers)", cfg.Delivery.Workers)
+	}
+
+	// Start scheduler (releases scheduled notifications into the outbox when due)
+	var scheduler *service.Scheduler
+	if notificationService != nil {
+		scheduler = service.NewScheduler(deliveryJobRepo, time.Duration(cfg.Delivery.SchedulerIntervalMs)*time.Millisecond)
+		scheduler.Start()
+		log.Println("Scheduler started")
+	}
+
+	// Start idempotency purger (deletes idempotency keys past their TTL)
+	var idempotencyPurger *service.IdempotencyPurger
+	if idempotencyRepo != nil {
+		idempotencyPurger = service.NewIdempotencyPurger(idempotencyRepo, time.Duration(cfg.Idempotency.PurgeIntervalMs)*time.Millisecond)
+		idempotencyPurger.Start()
+		log.Println("Idempotency purger started")
+	}
+
+	// Start workflow engine (runs the due steps of multi-step workflows)
+	var workflowEngine *service.WorkflowEngine
+	if notificationService != nil {
+		workflowEngine = service.NewWorkflowEngine(notificationService, workflowRunRepo, workflowRegistry, time.Duration(cfg.Delivery.WorkflowIntervalMs)*time.Millisecond)
+		workflowEngine.Start()
+		log.Println("Workflow engine started")
+	}
+
+	// WebSocket tickets are stored in the database, so any instance can redeem them
+	var ticketService *service.TicketService
+	if wsTicketRepo != nil {
+		ticketService = service.NewTicketService(wsTicketRepo, time.Duration(cfg.Auth.WSTicketTTL)*time.Second)
+	}
+
+	// Delivery events reported by email providers update notification status
+	var emailEventService *service.EmailEventService
+	if notificationRepo != nil {
+		emailEventService = service.NewEmailEventService(notificationRepo, notificationEventRepo, suppressionRepo)
+	}
+
+	// SendGrid signs its event webhook; without the verification key the webhook stays off
+	var sendGridWebhook *sendgrid.WebhookVerifier
+	if cfg.SendGrid.WebhookPublicKey != "" {
+		sendGridWebhook, err = sendgrid.NewWebhookVerifier(cfg.SendGrid.WebhookPublicKey, time.Duration(cfg.SendGrid.WebhookTolerance)*time.Second)
+		if err != nil {
+			log.Fatalf("Invalid SENDGRID_WEBHOOK_PUBLIC_KEY: %v", err)
+		}
+	}
+
 	// Create Gin router
 	router := gin.New()
 	router.Use(gin.Logger())
@@ -110,16 +282,16 @@ func main() {
 
 	// Configure CORS
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:5001", "https://prepmy.com", "https://prepmyapp.com"},
+		AllowOrigins:     cfg.Server.AllowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "X-Request-ID"},
-		ExposeHeaders:    []string{"Content-Length", "X-Request-ID"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "X-Request-ID", "Idempotency-Key", "Last-Event-ID"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	// Setup routes
-	setupRoutes(router, cfg, notificationService, deviceTokenRepo, preferencesRepo, wsHub)
+	setupRoutes(router, cfg, notificationService, workflowEngine, ticketService, emailEventService, sendGridWebhook, unsubscribeService, notificationRepo, deviceTokenRepo, preferencesRepo, suppressionRepo, idempotencyRepo, wsHub)
 
 	// Create HTTP server with timeouts
 	srv := &http.Server{
@@ -139,11 +311,11 @@ func main() {
 	}()
 
 	// Graceful shutdown
-	gracefulShutdown(srv, db)
+	gracefulShutdown(srv, db, wsHub, deliveryWorkers, scheduler, workflowEngine, idempotencyPurger)
 }
 
 // setupRoutes configures all API routes.
-func setupRoutes(router *gin.Engine, cfg *config.Config, notificationService *service.NotificationService, deviceTokenRepo *postgres.DeviceTokenRepository, preferencesRepo *postgres.PreferencesRepository, wsHub *websocket.Hub) {
+func setupRoutes(router *gin.Engine, cfg *config.Config, notificationService *service.NotificationService, workflowEngine *service.WorkflowEngine, ticketService *service.TicketService, emailEventService *service.EmailEventService, sendGridWebhook *sendgrid.WebhookVerifier, unsubscribeService *service.UnsubscribeService, notificationRepo *postgres.NotificationRepository, deviceTokenRepo *postgres.DeviceTokenRepository, preferencesRepo *postgres.PreferencesRepository, suppressionRepo *postgres.SuppressionRepository, idempotencyRepo *postgres.IdempotencyRepository, wsHub *websocket.Hub) {
 	// Root health check for Replit/load balancer
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
@@ -153,9 +325,28 @@ func setupRoutes(router *gin.Engine, cfg *config.Config, notificationService *se
 	healthHandler := handler.NewHealthHandler()
 	healthHandler.RegisterRoutes(&router.RouterGroup)
 
-	// WebSocket endpoint (JWT auth via query param)
-	if cfg.Auth.JWTSecret != "" {
-		wsHandler := handler.NewWebSocketHandler(wsHub, cfg.Auth.JWTSecret)
+	// Missed notifications are replayed to reconnecting realtime clients when the database is available
+	var history domain.NotificationRepository
+	if notificationRepo != nil {
+		history = notificationRepo
+	}
+
+	// Email provider webhooks (authenticated by the providers' signatures)
+	var emailEventHandler *handler.EmailEventHandler
+	if emailEventService != nil {
+		emailEventHandler = handler.NewEmailEventHandler(emailEventService, sendGridWebhook)
+		emailEventHandler.RegisterWebhookRoutes(&router.RouterGroup)
+	}
+
+	// One-click unsubscribe links from emails (authenticated by their signed token)
+	if unsubscribeService != nil {
+		unsubscribeHandler := handler.NewUnsubscribeHandler(unsubscribeService)
+		unsubscribeHandler.RegisterRoutes(&router.RouterGroup)
+	}
+
+	// WebSocket endpoint (ticket auth via query param; tickets are issued under /api/v1)
+	if cfg.Auth.JWTSecret != "" && ticketService != nil {
+		wsHandler := handler.NewWebSocketHandler(wsHub, ticketService, notificationService, history, cfg.Server.AllowedOrigins)
 		wsHandler.RegisterRoutes(router)
 	}
 
@@ -171,6 +362,18 @@ func setupRoutes(router *gin.Engine, cfg *config.Config, notificationService *se
 		notificationHandler.RegisterRoutes(v1)
 	}
 
+	// Register WebSocket ticket endpoint if tickets can be stored
+	if cfg.Auth.JWTSecret != "" && ticketService != nil {
+		ticketHandler := handler.NewTicketHandler(ticketService)
+		ticketHandler.RegisterRoutes(v1)
+	}
+
+	// Server-Sent Events for clients that can't open a WebSocket
+	if cfg.Auth.JWTSecret != "" {
+		streamHandler := handler.NewStreamHandler(wsHub, history)
+		streamHandler.RegisterRoutes(v1)
+	}
+
 	// Register device token endpoints if repository is available
 	if deviceTokenRepo != nil {
 		deviceTokenHandler := handler.NewDeviceTokenHandler(deviceTokenRepo)
@@ -200,8 +403,28 @@ func setupRoutes(router *gin.Engine, cfg *config.Config, notificationService *se
 
 	// Register internal endpoints if service is available
 	if notificationService != nil {
-		internalHandler := handler.NewInternalHandler(notificationService)
+		internalHandler := handler.NewInternalHandler(notificationService, idempotencyRepo, time.Duration(cfg.Idempotency.TTL)*time.Hour, time.Duration(cfg.Idempotency.LockTimeout)*time.Second)
 		internalHandler.RegisterRoutes(internal)
+
+		templateHandler := handler.NewTemplateHandler(notificationService)
+		templateHandler.RegisterRoutes(internal)
+	}
+
+	// Register delivery event history if the database is available
+	if emailEventHandler != nil {
+		emailEventHandler.RegisterRoutes(internal)
+	}
+
+	// Register suppression list endpoints if repository is available
+	if suppressionRepo != nil {
+		suppressionHandler := handler.NewSuppressionHandler(suppressionRepo)
+		suppressionHandler.RegisterRoutes(internal)
+	}
+
+	// Register workflow endpoints if the engine is running
+	if workflowEngine != nil {
+		workflowHandler := handler.NewWorkflowHandler(workflowEngine)
+		workflowHandler.RegisterRoutes(internal)
 	}
 
 	// Internal info endpoint
@@ -214,7 +437,7 @@ func setupRoutes(router *gin.Engine, cfg *config.Config, notificationService *se
 }
 
 // gracefulShutdown handles clean server shutdown on interrupt signals.
-func gracefulShutdown(srv *http.Server, db *database.DB) {
+func gracefulShutdown(srv *http.Server, db *database.DB, wsHub *websocket.Hub, deliveryWorkers *service.DeliveryWorkerPool, scheduler *service.Scheduler, workflowEngine *service.WorkflowEngine, idempotencyPurger *service.IdempotencyPurger) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
@@ -224,11 +447,50 @@ func gracefulShutdown(srv *http.Server, db *database.DB) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Disconnect realtime clients first: Shutdown doesn't close hijacked
+	// WebSockets and would wait out its timeout on open SSE streams. The
+	// broker stays open for the notifications emitted until the workers stop.
+	wsHub.Disconnect()
+
 	// Shutdown HTTP server
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
 
+	// Stop releasing scheduled notifications
+	if scheduler != nil {
+		if err := scheduler.Stop(ctx); err != nil {
+			log.Printf("Scheduler did not stop in time: %v", err)
+		}
+	}
+
+	if idempotencyPurger != nil {
+		if err := idempotencyPurger.Stop(ctx); err != nil {
+			log.Printf("Idempotency purger did not stop in time: %v", err)
+		}
+	}
+
+	// Stop running workflow steps (a step cut short is retried after its lease)
+	if workflowEngine != nil {
+		if err := workflowEngine.Stop(ctx); err != nil {
+			log.Printf("Workflow engine did not stop in time: %v", err)
+		}
+	}
+
+	// Let in-flight deliveries finish before the database goes away
+	if deliveryWorkers != nil {
+		if err := deliveryWorkers.Stop(ctx); err != nil {
+			log.Printf("Delivery workers did not stop in time: %v", err)
+		} else {
+			log.Println("Delivery workers stopped")
+		}
+	}
+
+	// Nothing emits realtime events any more; close the broker
+	if err := wsHub.Close(); err != nil {
+		log.Printf("Failed to close realtime broker: %v", err)
+	}
+
 	// Close database connection
 	if db != nil {
 		db.Close()
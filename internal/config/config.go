@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -11,18 +12,26 @@ import (
 // In Go, we use structs to group related data.
 // The `mapstructure` tags tell Viper how to map env vars to struct fields.
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	SendGrid SendGridConfig
-	Firebase FirebaseConfig
-	Auth     AuthConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	Realtime    RealtimeConfig
+	Email       EmailConfig
+	SendGrid    SendGridConfig
+	SMTP        SMTPConfig
+	Firebase    FirebaseConfig
+	Auth        AuthConfig
+	Delivery    DeliveryConfig
+	Idempotency IdempotencyConfig
+	Templates   TemplatesConfig
+	Unsubscribe UnsubscribeConfig
 }
 
 type ServerConfig struct {
-	Port         int    `mapstructure:"PORT"`
-	Environment  string `mapstructure:"ENVIRONMENT"`
-	AllowOrigins string `mapstructure:"ALLOW_ORIGINS"`
+	Port           int      `mapstructure:"PORT"`
+	Environment    string   `mapstructure:"ENVIRONMENT"`
+	AllowOrigins   string   `mapstructure:"ALLOW_ORIGINS"`
+	AllowedOrigins []string // Parsed from AllowOrigins; used for CORS and WebSocket origin checks
 }
 
 type DatabaseConfig struct {
@@ -36,10 +45,56 @@ type RedisConfig struct {
 	URL string `mapstructure:"REDIS_URL"`
 }
 
+type RealtimeConfig struct {
+	// Broker fanning in-app notifications out across instances: memory, redis or postgres.
+	// Empty picks redis when REDIS_URL is set and memory otherwise.
+	Broker string `mapstructure:"REALTIME_BROKER"`
+
+	// Sockets a user may hold open on one instance; the oldest is closed
+	// when another one connects. 0 means unlimited.
+	MaxConnectionsPerUser int `mapstructure:"WS_MAX_CONNECTIONS_PER_USER"`
+}
+
+type EmailConfig struct {
+	// Providers sending email (sendgrid, smtp), in failover order, e.g.
+	// "sendgrid,smtp". Providers joined with "+" share traffic by weight
+	// instead: "sendgrid:3+smtp:1". Empty uses whichever of SendGrid and SMTP
+	// is configured, SendGrid first.
+	Provider string       `mapstructure:"EMAIL_PROVIDER"`
+	Routes   []EmailRoute // Parsed from Provider
+
+	BreakerThreshold int `mapstructure:"EMAIL_BREAKER_THRESHOLD"` // Consecutive failures before a provider is skipped
+	BreakerCooldown  int `mapstructure:"EMAIL_BREAKER_COOLDOWN"`  // Seconds a failing provider is skipped for
+}
+
+// EmailRoute is one email provider and where it sits in the routing order.
+type EmailRoute struct {
+	Name     string
+	Priority int // Position in the failover order, from 0
+	Weight   int // Share of traffic among providers of the same priority
+}
+
 type SendGridConfig struct {
 	APIKey    string `mapstructure:"SENDGRID_API_KEY"`
 	FromEmail string `mapstructure:"SENDGRID_FROM_EMAIL"`
 	FromName  string `mapstructure:"SENDGRID_FROM_NAME"`
+
+	// Verification key from SendGrid's Signed Event Webhook settings (base64).
+	// Empty disables the event webhook.
+	WebhookPublicKey string `mapstructure:"SENDGRID_WEBHOOK_PUBLIC_KEY"`
+	WebhookTolerance int    `mapstructure:"SENDGRID_WEBHOOK_TOLERANCE"` // Seconds a signed request's timestamp may be off by
+}
+
+type SMTPConfig struct {
+	Host         string `mapstructure:"SMTP_HOST"`
+	Port         int    `mapstructure:"SMTP_PORT"`
+	Username     string `mapstructure:"SMTP_USERNAME"` // Empty skips authentication
+	Password     string `mapstructure:"SMTP_PASSWORD"`
+	Security     string `mapstructure:"SMTP_SECURITY"` // starttls, tls (implicit) or none
+	FromEmail    string `mapstructure:"SMTP_FROM_EMAIL"`
+	FromName     string `mapstructure:"SMTP_FROM_NAME"`
+	Timeout      int    `mapstructure:"SMTP_TIMEOUT"`        // Seconds per connection attempt and per message
+	MaxIdleConns int    `mapstructure:"SMTP_MAX_IDLE_CONNS"` // Connections kept open for reuse
 }
 
 type FirebaseConfig struct {
@@ -47,9 +102,45 @@ type FirebaseConfig struct {
 	CredentialsJSON string `mapstructure:"FIREBASE_CREDENTIALS_JSON"` // Alternative: JSON string for Replit Secrets
 }
 
+type DeliveryConfig struct {
+	Workers        int `mapstructure:"DELIVERY_WORKERS"`
+	BatchSize      int `mapstructure:"DELIVERY_BATCH_SIZE"`
+	PollIntervalMs int `mapstructure:"DELIVERY_POLL_INTERVAL_MS"`
+	LockTimeout    int `mapstructure:"DELIVERY_LOCK_TIMEOUT"` // Seconds before a claimed job is reclaimed
+	MaxAttempts    int `mapstructure:"DELIVERY_MAX_ATTEMPTS"`
+	RetryBaseDelay int `mapstructure:"DELIVERY_RETRY_BASE_DELAY"` // Seconds, doubled after each failed attempt
+	RetryMaxDelay  int `mapstructure:"DELIVERY_RETRY_MAX_DELAY"`  // Seconds
+
+	SchedulerIntervalMs int `mapstructure:"SCHEDULER_POLL_INTERVAL_MS"` // How often due scheduled notifications are released
+	WorkflowIntervalMs  int `mapstructure:"WORKFLOW_POLL_INTERVAL_MS"`  // How often due workflow steps are run
+}
+
+type IdempotencyConfig struct {
+	TTL             int `mapstructure:"IDEMPOTENCY_KEY_TTL"`           // Hours a notify response is replayed for a repeated key
+	LockTimeout     int `mapstructure:"IDEMPOTENCY_LOCK_TIMEOUT"`      // Seconds before an unfinished request's key can be taken over
+	PurgeIntervalMs int `mapstructure:"IDEMPOTENCY_PURGE_INTERVAL_MS"` // How often expired keys are deleted
+}
+
+type TemplatesConfig struct {
+	Dir string `mapstructure:"TEMPLATES_DIR"` // Optional directory overriding the embedded templates
+}
+
+// UnsubscribeConfig enables the one-click unsubscribe links in non-critical
+// emails. Both fields are needed; without them emails carry no link.
+type UnsubscribeConfig struct {
+	Secret  string `mapstructure:"UNSUBSCRIBE_SECRET"` // Signs the unsubscribe tokens
+	BaseURL string `mapstructure:"PUBLIC_BASE_URL"`    // Where this service is publicly reachable, e.g. https://notify.prepmyapp.com
+}
+
+// Enabled reports whether unsubscribe links are configured.
+func (c UnsubscribeConfig) Enabled() bool {
+	return c.Secret != "" && c.BaseURL != ""
+}
+
 type AuthConfig struct {
-	JWTSecret string   `mapstructure:"JWT_SECRET"`
-	APIKeys   []string // Parsed from comma-separated INTERNAL_API_KEYS
+	JWTSecret   string   `mapstructure:"JWT_SECRET"`
+	APIKeys     []string // Parsed from comma-separated INTERNAL_API_KEYS
+	WSTicketTTL int      `mapstructure:"WS_TICKET_TTL"` // Seconds a WebSocket ticket stays valid
 }
 
 // Load reads configuration from environment variables.
@@ -61,8 +152,39 @@ func Load() (*Config, error) {
 	viper.SetDefault("DB_MAX_OPEN_CONNS", 20)
 	viper.SetDefault("DB_MAX_IDLE_CONNS", 5)
 	viper.SetDefault("DB_CONN_MAX_LIFETIME", 300) // 5 minutes in seconds
-	viper.SetDefault("ALLOW_ORIGINS", "http://localhost:3000,http://localhost:5001")
+	viper.SetDefault("ALLOW_ORIGINS", "http://localhost:3000,http://localhost:5001,https://prepmy.com,https://prepmyapp.com")
+	viper.SetDefault("REALTIME_BROKER", "") // Registers the key so Unmarshal reads it from the environment
+	viper.SetDefault("WS_MAX_CONNECTIONS_PER_USER", 5)
+	viper.SetDefault("EMAIL_PROVIDER", "")
+	viper.SetDefault("EMAIL_BREAKER_THRESHOLD", 5)
+	viper.SetDefault("EMAIL_BREAKER_COOLDOWN", 30)
 	viper.SetDefault("SENDGRID_FROM_NAME", "PrepMyApp")
+	viper.SetDefault("SENDGRID_WEBHOOK_PUBLIC_KEY", "")
+	viper.SetDefault("SENDGRID_WEBHOOK_TOLERANCE", 300) // 5 minutes in seconds
+	viper.SetDefault("SMTP_HOST", "")
+	viper.SetDefault("SMTP_PORT", 587)
+	viper.SetDefault("SMTP_USERNAME", "")
+	viper.SetDefault("SMTP_PASSWORD", "")
+	viper.SetDefault("SMTP_SECURITY", "starttls")
+	viper.SetDefault("SMTP_FROM_EMAIL", "")
+	viper.SetDefault("SMTP_FROM_NAME", "PrepMyApp")
+	viper.SetDefault("SMTP_TIMEOUT", 10)
+	viper.SetDefault("SMTP_MAX_IDLE_CONNS", 2)
+	viper.SetDefault("DELIVERY_WORKERS", 4)
+	viper.SetDefault("DELIVERY_BATCH_SIZE", 10)
+	viper.SetDefault("DELIVERY_POLL_INTERVAL_MS", 1000)
+	viper.SetDefault("DELIVERY_LOCK_TIMEOUT", 300) // 5 minutes in seconds
+	viper.SetDefault("DELIVERY_MAX_ATTEMPTS", 5)
+	viper.SetDefault("DELIVERY_RETRY_BASE_DELAY", 30)
+	viper.SetDefault("DELIVERY_RETRY_MAX_DELAY", 3600) // 1 hour in seconds
+	viper.SetDefault("SCHEDULER_POLL_INTERVAL_MS", 5000)
+	viper.SetDefault("WORKFLOW_POLL_INTERVAL_MS", 5000)
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", 24)
+	viper.SetDefault("IDEMPOTENCY_LOCK_TIMEOUT", 60)
+	viper.SetDefault("IDEMPOTENCY_PURGE_INTERVAL_MS", 3600000) // 1 hour
+	viper.SetDefault("WS_TICKET_TTL", 30)
+	viper.SetDefault("UNSUBSCRIBE_SECRET", "")
+	viper.SetDefault("PUBLIC_BASE_URL", "")
 
 	// Read from .env file if it exists (for local development)
 	viper.SetConfigName(".env")
@@ -114,11 +236,28 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal redis config: %w", err)
 	}
 
+	// Unmarshal realtime config
+	if err := viper.Unmarshal(&cfg.Realtime); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal realtime config: %w", err)
+	}
+	cfg.Realtime.Broker = strings.ToLower(strings.TrimSpace(cfg.Realtime.Broker))
+
+	// Unmarshal email config
+	if err := viper.Unmarshal(&cfg.Email); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal email config: %w", err)
+	}
+
 	// Unmarshal sendgrid config
 	if err := viper.Unmarshal(&cfg.SendGrid); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal sendgrid config: %w", err)
 	}
 
+	// Unmarshal smtp config
+	if err := viper.Unmarshal(&cfg.SMTP); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal smtp config: %w", err)
+	}
+	cfg.SMTP.Security = strings.ToLower(strings.TrimSpace(cfg.SMTP.Security))
+
 	// Unmarshal firebase config
 	if err := viper.Unmarshal(&cfg.Firebase); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal firebase config: %w", err)
@@ -129,6 +268,27 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal auth config: %w", err)
 	}
 
+	// Unmarshal delivery worker config
+	if err := viper.Unmarshal(&cfg.Delivery); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal delivery config: %w", err)
+	}
+
+	// Unmarshal idempotency config
+	if err := viper.Unmarshal(&cfg.Idempotency); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal idempotency config: %w", err)
+	}
+
+	// Unmarshal templates config
+	if err := viper.Unmarshal(&cfg.Templates); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal templates config: %w", err)
+	}
+
+	// Unmarshal unsubscribe config
+	if err := viper.Unmarshal(&cfg.Unsubscribe); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal unsubscribe config: %w", err)
+	}
+	cfg.Unsubscribe.BaseURL = strings.TrimRight(strings.TrimSpace(cfg.Unsubscribe.BaseURL), "/")
+
 	// Read secrets directly from environment
 	// (Viper's Unmarshal doesn't properly read env vars for nested struct fields)
 	if cfg.Database.URL == "" {
@@ -149,6 +309,23 @@ func Load() (*Config, error) {
 	if cfg.SendGrid.FromName == "" {
 		cfg.SendGrid.FromName = viper.GetString("SENDGRID_FROM_NAME")
 	}
+
+	// Without EMAIL_PROVIDER, use whichever providers have credentials
+	if cfg.Email.Provider == "" {
+		var providers []string
+		if cfg.SendGrid.APIKey != "" {
+			providers = append(providers, "sendgrid")
+		}
+		if cfg.SMTP.Host != "" {
+			providers = append(providers, "smtp")
+		}
+		cfg.Email.Provider = strings.Join(providers, ",")
+	}
+	routes, err := parseEmailRoutes(cfg.Email.Provider)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Email.Routes = routes
 	if cfg.Firebase.CredentialsJSON == "" {
 		cfg.Firebase.CredentialsJSON = viper.GetString("FIREBASE_CREDENTIALS_JSON")
 	}
@@ -165,6 +342,13 @@ func Load() (*Config, error) {
 		}
 	}
 
+	// Parse comma-separated allowed origins
+	for _, origin := range strings.Split(cfg.Server.AllowOrigins, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			cfg.Server.AllowedOrigins = append(cfg.Server.AllowedOrigins, origin)
+		}
+	}
+
 	// Validate required configuration
 	if err := cfg.Validate(); err != nil {
 		return nil, err
@@ -173,13 +357,43 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// parseEmailRoutes parses EMAIL_PROVIDER: comma-separated priorities, each
+// one or more "name[:weight]" joined with "+".
+func parseEmailRoutes(s string) ([]EmailRoute, error) {
+	var routes []EmailRoute
+	priority := 0
+	for _, tier := range strings.Split(strings.ToLower(s), ",") {
+		if strings.TrimSpace(tier) == "" {
+			continue
+		}
+
+		for _, entry := range strings.Split(tier, "+") {
+			name, weight, hasWeight := strings.Cut(strings.TrimSpace(entry), ":")
+			route := EmailRoute{Name: strings.TrimSpace(name), Priority: priority, Weight: 1}
+			if route.Name == "" {
+				return nil, fmt.Errorf("invalid EMAIL_PROVIDER %q: empty provider name", s)
+			}
+			if hasWeight {
+				w, err := strconv.Atoi(strings.TrimSpace(weight))
+				if err != nil || w <= 0 {
+					return nil, fmt.Errorf("invalid weight %q for email provider %s: must be a positive integer", weight, route.Name)
+				}
+				route.Weight = w
+			}
+			routes = append(routes, route)
+		}
+		priority++
+	}
+	return routes, nil
+}
+
 // Validate checks that required configuration values are set.
 // In Go, methods are defined outside the struct, with a receiver.
 func (c *Config) Validate() error {
 	var missing []string
 
 	// In production, only DATABASE_URL is strictly required
-	// JWT_SECRET and SENDGRID_API_KEY are optional (service will work with limited functionality)
+	// JWT_SECRET and the email provider are optional (service will work with limited functionality)
 	if c.Server.Environment == "production" {
 		if c.Database.URL == "" {
 			missing = append(missing, "DATABASE_URL")
@@ -188,8 +402,11 @@ func (c *Config) Validate() error {
 		if c.Auth.JWTSecret == "" {
 			fmt.Println("WARNING: JWT_SECRET not set - JWT authentication will not work")
 		}
-		if c.SendGrid.APIKey == "" {
-			fmt.Println("WARNING: SENDGRID_API_KEY not set - email notifications will not work")
+		if len(c.Email.Routes) == 0 {
+			fmt.Println("WARNING: neither SENDGRID_API_KEY nor SMTP_HOST set - email notifications will not work")
+		}
+		if !c.Unsubscribe.Enabled() {
+			fmt.Println("WARNING: UNSUBSCRIBE_SECRET or PUBLIC_BASE_URL not set - emails will have no unsubscribe link")
 		}
 	}
 
@@ -197,6 +414,49 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", "))
 	}
 
+	if (c.Unsubscribe.Secret == "") != (c.Unsubscribe.BaseURL == "") {
+		return fmt.Errorf("UNSUBSCRIBE_SECRET and PUBLIC_BASE_URL must be set together")
+	}
+	if c.Unsubscribe.BaseURL != "" && !strings.HasPrefix(c.Unsubscribe.BaseURL, "https://") && !strings.HasPrefix(c.Unsubscribe.BaseURL, "http://") {
+		return fmt.Errorf("PUBLIC_BASE_URL must start with https:// or http://")
+	}
+
+	seen := make(map[string]bool)
+	for _, route := range c.Email.Routes {
+		if seen[route.Name] {
+			return fmt.Errorf("email provider %q listed twice in EMAIL_PROVIDER", route.Name)
+		}
+		seen[route.Name] = true
+
+		switch route.Name {
+		case "sendgrid":
+			if c.SendGrid.APIKey == "" {
+				return fmt.Errorf("email provider sendgrid requires SENDGRID_API_KEY")
+			}
+		case "smtp":
+			if c.SMTP.Host == "" || c.SMTP.FromEmail == "" {
+				return fmt.Errorf("email provider smtp requires SMTP_HOST and SMTP_FROM_EMAIL")
+			}
+			switch c.SMTP.Security {
+			case "starttls", "tls", "none":
+			default:
+				return fmt.Errorf("invalid SMTP_SECURITY %q: must be starttls, tls or none", c.SMTP.Security)
+			}
+		default:
+			return fmt.Errorf("invalid email provider %q in EMAIL_PROVIDER: must be sendgrid or smtp", route.Name)
+		}
+	}
+
+	// Origins are matched exactly against the browser's Origin header
+	if len(c.Server.AllowedOrigins) == 0 {
+		return fmt.Errorf("ALLOW_ORIGINS must list at least one origin")
+	}
+	for _, origin := range c.Server.AllowedOrigins {
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("invalid origin %q in ALLOW_ORIGINS: must start with http:// or https://", origin)
+		}
+	}
+
 	return nil
 }
 
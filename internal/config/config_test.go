@@ -0,0 +1,87 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEmailRoutes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []EmailRoute
+	}{
+		{"", nil},
+		{"sendgrid", []EmailRoute{{Name: "sendgrid", Priority: 0, Weight: 1}}},
+		{"sendgrid,smtp", []EmailRoute{
+			{Name: "sendgrid", Priority: 0, Weight: 1},
+			{Name: "smtp", Priority: 1, Weight: 1},
+		}},
+		{"sendgrid:3+smtp:1", []EmailRoute{
+			{Name: "sendgrid", Priority: 0, Weight: 3},
+			{Name: "smtp", Priority: 0, Weight: 1},
+		}},
+		{" SendGrid : 2 + smtp , ", []EmailRoute{
+			{Name: "sendgrid", Priority: 0, Weight: 2},
+			{Name: "smtp", Priority: 0, Weight: 1},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseEmailRoutes(tt.in)
+			if err != nil {
+				t.Fatalf("parseEmailRoutes(%q): %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseEmailRoutes(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEmailRoutesRejectsBadRoutes(t *testing.T) {
+	for _, in := range []string{
+		"sendgrid:0",
+		"sendgrid:-1",
+		"sendgrid:heavy",
+		"sendgrid:",
+		"sendgrid:1.5",
+		":3",
+		"sendgrid+",
+		"sendgrid++smtp",
+		"+smtp",
+	} {
+		t.Run(in, func(t *testing.T) {
+			if routes, err := parseEmailRoutes(in); err == nil {
+				t.Errorf("parseEmailRoutes(%q) = %+v, want an error", in, routes)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsBadEmailRoutes(t *testing.T) {
+	newConfig := func(routes []EmailRoute) *Config {
+		return &Config{
+			Server:   ServerConfig{AllowedOrigins: []string{"http://localhost:3000"}},
+			SendGrid: SendGridConfig{APIKey: "key"},
+			Email:    EmailConfig{Routes: routes},
+		}
+	}
+	if err := newConfig([]EmailRoute{{Name: "sendgrid", Weight: 1}}).Validate(); err != nil {
+		t.Fatalf("Validate rejected a valid config: %v", err)
+	}
+
+	tests := map[string][]EmailRoute{
+		"unknown provider": {{Name: "mailgun", Weight: 1}},
+		"listed twice":     {{Name: "sendgrid", Weight: 1}, {Name: "sendgrid", Priority: 1, Weight: 1}},
+		"no credentials":   {{Name: "smtp", Weight: 1}},
+	}
+
+	for name, routes := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := newConfig(routes).Validate(); err == nil {
+				t.Error("Validate succeeded, want an error")
+			}
+		})
+	}
+}
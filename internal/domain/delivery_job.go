@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeliveryJobStatus tracks the state of an outbox entry.
+type DeliveryJobStatus string
+
+const (
+	DeliveryJobStatusPending    DeliveryJobStatus = "pending"
+	DeliveryJobStatusProcessing DeliveryJobStatus = "processing"
+	DeliveryJobStatusDone       DeliveryJobStatus = "done"
+	// DeliveryJobStatusDeadLettered means the job ran out of attempts or hit a permanent error.
+	DeliveryJobStatusDeadLettered DeliveryJobStatus = "dead_lettered"
+	// DeliveryJobStatusScheduled jobs are invisible to workers until the scheduler releases them.
+	DeliveryJobStatusScheduled DeliveryJobStatus = "scheduled"
+	DeliveryJobStatusCancelled DeliveryJobStatus = "cancelled"
+)
+
+// DeliveryPayload carries everything a delivery worker needs that isn't
+// already stored on the notification row (recipient address, HTML body, ...).
+type DeliveryPayload struct {
+	Email    string `json:"email,omitempty"`
+	HtmlBody string `json:"html_body,omitempty"`
+
+	// UnsubscribeURL is the one-click unsubscribe link for non-critical emails
+	UnsubscribeURL string `json:"unsubscribe_url,omitempty"`
+
+	// Fallback lists the channels still to try, in order, if the current one
+	// is unavailable or fails for good.
+	Fallback []FallbackStep `json:"fallback,omitempty"`
+}
+
+// FallbackStep is the next channel of a fallback chain, with the copy
+// rendered for it (email subject and bodies differ from push copy).
+type FallbackStep struct {
+	Type     NotificationType `json:"type"`
+	Title    string           `json:"title"`
+	Body     string           `json:"body"`
+	HtmlBody string           `json:"html_body,omitempty"`
+}
+
+// DeliveryJob is an outbox entry for a notification awaiting delivery.
+// It is written in the same transaction as the notification, so a crash
+// can never leave a notification without a job to deliver it.
+type DeliveryJob struct {
+	ID             uuid.UUID         `json:"id"`
+	NotificationID uuid.UUID         `json:"notification_id"`
+	Payload        DeliveryPayload   `json:"payload"`
+	Status         DeliveryJobStatus `json:"status"`
+	Attempts       int               `json:"attempts"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at"`
+	LastError      string            `json:"last_error,omitempty"`
+	LockedBy       string            `json:"locked_by,omitempty"`
+	LockedAt       *time.Time        `json:"locked_at,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// NewDeliveryJob creates a pending delivery job for a notification.
+func NewDeliveryJob(notificationID uuid.UUID, payload DeliveryPayload) *DeliveryJob {
+	now := time.Now()
+	return &DeliveryJob{
+		ID:             uuid.New(),
+		NotificationID: notificationID,
+		Payload:        payload,
+		Status:         DeliveryJobStatusPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// NewScheduledDeliveryJob creates a delivery job that is held until sendAt.
+func NewScheduledDeliveryJob(notificationID uuid.UUID, payload DeliveryPayload, sendAt time.Time) *DeliveryJob {
+	job := NewDeliveryJob(notificationID, payload)
+	job.Status = DeliveryJobStatusScheduled
+	job.NextAttemptAt = sendAt
+	return job
+}
+
+// ErrChannelUnavailable means a channel can't reach the user at all, e.g.
+// push for a user without active device tokens. Providers wrap it in a
+// permanent error, so the notification moves on to its fallback channel.
+var ErrChannelUnavailable = errors.New("channel unavailable")
+
+// ErrLeaseLost means a worker tried to finish a job it no longer holds: its
+// lock timed out and Claim handed the job to another worker, which now owns
+// the job's state.
+var ErrLeaseLost = errors.New("delivery job lease lost")
+
+// DeliveryError wraps a provider error with whether retrying it might succeed.
+type DeliveryError struct {
+	Err       error
+	Retryable bool
+}
+
+func (e *DeliveryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// NewRetryableError marks err as transient (provider 5xx, rate limiting, timeouts).
+func NewRetryableError(err error) error {
+	return &DeliveryError{Err: err, Retryable: true}
+}
+
+// NewPermanentError marks err as one that will fail again however often it is retried.
+func NewPermanentError(err error) error {
+	return &DeliveryError{Err: err, Retryable: false}
+}
+
+// IsRetryable reports whether a failed delivery is worth retrying. Only
+// errors marked with NewRetryableError are: an error nobody classified is as
+// likely to fail again, and retrying it would only keep it out of the
+// dead-letter queue, where someone looks at it. A suppressed address never is.
+func IsRetryable(err error) bool {
+	var suppressed *ErrSuppressed
+	if errors.As(err, &suppressed) {
+		return false
+	}
+
+	var de *DeliveryError
+	if errors.As(err, &de) {
+		return de.Retryable
+	}
+	return false
+}
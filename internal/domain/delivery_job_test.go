@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsRetryable(t *testing.T) {
+	suppressed := &ErrSuppressed{Email: "user@example.com", Reason: SuppressionReasonBounce}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"retryable", NewRetryableError(errors.New("provider unavailable")), true},
+		{"wrapped retryable", fmt.Errorf("failed to send email: %w", NewRetryableError(errors.New("timeout"))), true},
+		{"permanent", NewPermanentError(errors.New("invalid recipient")), false},
+		{"wrapped permanent", fmt.Errorf("failed to send push: %w", NewPermanentError(errors.New("unregistered"))), false},
+		{"unclassified", errors.New("unexpected response"), false},
+		{"suppressed", suppressed, false},
+		{"suppressed marked retryable", NewRetryableError(fmt.Errorf("send: %w", suppressed)), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "github.com/google/uuid"
+
+// EmailMessage is a single email for an email provider to send.
+type EmailMessage struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string // Optional; without it the email is plain text
+
+	// NotificationID, if set, is attached to the email so that the events the
+	// provider reports about it later can be matched to the notification.
+	NotificationID uuid.UUID
+
+	// UnsubscribeURL, if set, is offered to mail clients as a one-click
+	// unsubscribe (RFC 8058) through the List-Unsubscribe headers.
+	UnsubscribeURL string
+}
+
+// Headers returns the extra headers msg must be sent with, if any.
+func (m *EmailMessage) Headers() map[string]string {
+	if m.UnsubscribeURL == "" {
+		return nil
+	}
+	return map[string]string{
+		"List-Unsubscribe":      "<" + m.UnsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+// EmailReceipt reports which provider accepted an email.
+type EmailReceipt struct {
+	Provider  string // e.g. "sendgrid" or "smtp"
+	MessageID string // The provider's ID for the message, if it returned one
+}
+
+// Metadata keys recording which provider delivered an email notification.
+const (
+	EmailProviderKey  = "email_provider"
+	EmailMessageIDKey = "email_message_id"
+)
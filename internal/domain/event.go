@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventVersion is the version of the realtime event envelope. It is bumped
+// when the envelope or a payload changes incompatibly.
+const EventVersion = 1
+
+// EventType names what a realtime event reports.
+type EventType string
+
+const (
+	// EventNotificationCreated carries a new in-app notification as payload.
+	EventNotificationCreated EventType = "notification.created"
+	// EventNotificationRead carries a NotificationReadPayload.
+	EventNotificationRead EventType = "notification.read"
+	// EventUnreadCountChanged carries an UnreadCountPayload.
+	EventUnreadCountChanged EventType = "unread_count.changed"
+	// EventNotificationDeleted carries a NotificationDeletedPayload.
+	EventNotificationDeleted EventType = "notification.deleted"
+	// EventCommandReply answers a command a client sent over the WebSocket.
+	EventCommandReply EventType = "command.reply"
+)
+
+// Event is the envelope every realtime message is sent in, e.g.
+// {"v": 1, "type": "unread_count.changed", "id": "...", "ts": "...", "payload": {"count": 3}}.
+type Event struct {
+	Version   int         `json:"v"`
+	Type      EventType   `json:"type"`
+	ID        uuid.UUID   `json:"id"` // Unique per event, for client-side deduplication
+	Timestamp time.Time   `json:"ts"`
+	Payload   interface{} `json:"payload"`
+}
+
+// NewEvent creates an event of the given type.
+func NewEvent(eventType EventType, payload interface{}) *Event {
+	return &Event{
+		Version:   EventVersion,
+		Type:      eventType,
+		ID:        uuid.New(),
+		Timestamp: time.Now(),
+		Payload:   payload,
+	}
+}
+
+// NotificationReadPayload reports that one notification, or all of the
+// user's notifications, were marked as read.
+type NotificationReadPayload struct {
+	NotificationID *uuid.UUID `json:"notification_id,omitempty"`
+	All            bool       `json:"all,omitempty"`
+	ReadAt         time.Time  `json:"read_at"`
+}
+
+// UnreadCountPayload reports the user's new unread count.
+type UnreadCountPayload struct {
+	Count int64 `json:"count"`
+}
+
+// NotificationDeletedPayload reports that a notification was deleted.
+type NotificationDeletedPayload struct {
+	NotificationID uuid.UUID `json:"notification_id"`
+}
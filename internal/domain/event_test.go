@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestEventEnvelope checks the wire format every realtime event is sent in.
+func TestEventEnvelope(t *testing.T) {
+	id := uuid.New()
+	event := NewEvent(EventNotificationDeleted, NotificationDeletedPayload{NotificationID: id})
+
+	if event.ID == uuid.Nil {
+		t.Error("event has no ID")
+	}
+	if other := NewEvent(EventNotificationDeleted, nil); other.ID == event.ID {
+		t.Error("two events share an ID")
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(envelope) != 5 {
+		t.Errorf("envelope has %d fields, want v, type, id, ts and payload: %s", len(envelope), data)
+	}
+
+	var decoded struct {
+		Version   int       `json:"v"`
+		Type      EventType `json:"type"`
+		ID        uuid.UUID `json:"id"`
+		Timestamp time.Time `json:"ts"`
+		Payload   struct {
+			NotificationID uuid.UUID `json:"notification_id"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if decoded.Version != EventVersion {
+		t.Errorf("v = %d, want %d", decoded.Version, EventVersion)
+	}
+	if decoded.Type != EventNotificationDeleted {
+		t.Errorf("type = %q, want %q", decoded.Type, EventNotificationDeleted)
+	}
+	if decoded.ID != event.ID {
+		t.Errorf("id = %s, want %s", decoded.ID, event.ID)
+	}
+	if !decoded.Timestamp.Equal(event.Timestamp) {
+		t.Errorf("ts = %s, want %s", decoded.Timestamp, event.Timestamp)
+	}
+	if decoded.Payload.NotificationID != id {
+		t.Errorf("payload notification_id = %s, want %s", decoded.Payload.NotificationID, id)
+	}
+}
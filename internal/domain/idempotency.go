@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// IdempotencyRecord remembers the response to a request made with an
+// Idempotency-Key, so that a retried request can be answered without
+// sending the notification again.
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string // Fingerprint of the request body the key was first used with
+	StatusCode  int    // Zero while the original request is still in progress
+	Response    []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// IsComplete returns true if the original request has finished and its
+// response was stored.
+func (r *IdempotencyRecord) IsComplete() bool {
+	return r.StatusCode != 0
+}
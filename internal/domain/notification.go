@@ -25,23 +25,45 @@ const (
 	NotificationStatusSent      NotificationStatus = "sent"
 	NotificationStatusDelivered NotificationStatus = "delivered"
 	NotificationStatusFailed    NotificationStatus = "failed"
+	// NotificationStatusDeadLettered is terminal: delivery gave up and needs a manual requeue.
+	NotificationStatusDeadLettered NotificationStatus = "dead_lettered"
+	// NotificationStatusScheduled waits for ScheduledAt before it is queued for delivery.
+	NotificationStatusScheduled NotificationStatus = "scheduled"
+	// NotificationStatusCancelled is a scheduled notification that was cancelled before it went out.
+	NotificationStatusCancelled NotificationStatus = "cancelled"
+	// NotificationStatusBounced is an email the provider accepted but that never
+	// reached the recipient: it bounced or the provider dropped it.
+	NotificationStatusBounced NotificationStatus = "bounced"
+	// NotificationStatusSuppressed is an email that was never sent because its
+	// address is on the suppression list.
+	NotificationStatusSuppressed NotificationStatus = "suppressed"
 )
 
+// DeferReasonQuietHours marks a notification held back until the user's quiet hours end.
+const DeferReasonQuietHours = "quiet_hours"
+
 // Notification is the core domain entity.
 // It represents a single notification to be delivered to a user.
 type Notification struct {
-	ID        uuid.UUID              `json:"id"`
-	UserID    uuid.UUID              `json:"user_id"`
-	Type      NotificationType       `json:"type"`
-	Channel   string                 `json:"channel"` // e.g., "otp", "alert", "marketing"
-	Title     string                 `json:"title"`
-	Body      string                 `json:"body"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
-	Status    NotificationStatus     `json:"status"`
-	ReadAt    *time.Time             `json:"read_at,omitempty"`
-	SentAt    *time.Time             `json:"sent_at,omitempty"`
-	CreatedAt time.Time              `json:"created_at"`
-	UpdatedAt time.Time              `json:"updated_at"`
+	ID             uuid.UUID              `json:"id"`
+	UserID         uuid.UUID              `json:"user_id"`
+	Type           NotificationType       `json:"type"`
+	Channel        string                 `json:"channel"` // e.g., "otp", "alert", "marketing"
+	Title          string                 `json:"title"`
+	Body           string                 `json:"body"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	Status         NotificationStatus     `json:"status"`
+	IdempotencyKey string                 `json:"idempotency_key,omitempty"` // Key it was created under, scoped to the endpoint and channel
+	ScheduledAt    *time.Time             `json:"scheduled_at,omitempty"`    // When a scheduled notification is due
+	DeferReason    string                 `json:"defer_reason,omitempty"`    // Why delivery was pushed back, e.g. "quiet_hours"
+	ReadAt         *time.Time             `json:"read_at,omitempty"`
+	SentAt         *time.Time             `json:"sent_at,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+
+	// IdempotencyExpiresAt is when IdempotencyKey stops deduplicating, so the
+	// key can be reused; nil never expires
+	IdempotencyExpiresAt *time.Time `json:"-"`
 }
 
 // NewNotification creates a new notification with sensible defaults.
@@ -62,6 +84,75 @@ func NewNotification(userID uuid.UUID, notifType NotificationType, channel, titl
 	}
 }
 
+// Schedule holds the notification back until sendAt.
+func (n *Notification) Schedule(sendAt time.Time) {
+	n.Status = NotificationStatusScheduled
+	n.ScheduledAt = &sendAt
+	n.UpdatedAt = time.Now()
+}
+
+// ChannelPathKey is the metadata key recording the channels a notification
+// with a fallback chain went through, e.g.
+// [{"channel": "push", "error": "no active device tokens"}, {"channel": "in_app"}].
+const ChannelPathKey = "channel_path"
+
+// FailureReasonKey records in a notification's metadata why it was finished
+// without being delivered, e.g. a push to a user without any devices.
+const FailureReasonKey = "failure_reason"
+
+// RecordChannel appends channel to the notification's channel path.
+// reason is why the channel was passed over ("" for the one now in use).
+func (n *Notification) RecordChannel(channel NotificationType, reason string) {
+	entry := map[string]interface{}{"channel": string(channel)}
+	if reason != "" {
+		entry["error"] = reason
+	}
+
+	if n.Metadata == nil {
+		n.Metadata = make(map[string]interface{})
+	}
+	path, _ := n.Metadata[ChannelPathKey].([]interface{})
+	n.Metadata[ChannelPathKey] = append(path, entry)
+}
+
+// FallBack moves the notification to the next channel of its fallback chain.
+// reason is why the current channel failed; it is recorded on the path.
+func (n *Notification) FallBack(step FallbackStep, reason string) {
+	if path, ok := n.Metadata[ChannelPathKey].([]interface{}); ok && len(path) > 0 {
+		if last, ok := path[len(path)-1].(map[string]interface{}); ok {
+			last["error"] = reason
+		}
+	}
+	n.RecordChannel(step.Type, "")
+
+	n.Type = step.Type
+	n.Title = step.Title
+	n.Body = step.Body
+	n.Status = NotificationStatusPending
+	n.UpdatedAt = time.Now()
+}
+
+// Defer holds the notification back until until, recording why.
+func (n *Notification) Defer(until time.Time, reason string) {
+	n.Schedule(until)
+	n.DeferReason = reason
+}
+
+// IsScheduled returns true if the notification is still waiting for its send time.
+func (n *Notification) IsScheduled() bool {
+	return n.Status == NotificationStatusScheduled
+}
+
+// DueAt returns when the notification became deliverable: its send time if
+// it was scheduled or deferred, otherwise when it was created. In-app
+// notifications are replayed to reconnecting clients in this order.
+func (n *Notification) DueAt() time.Time {
+	if n.ScheduledAt != nil {
+		return *n.ScheduledAt
+	}
+	return n.CreatedAt
+}
+
 // MarkAsSent updates the notification status to sent.
 func (n *Notification) MarkAsSent() {
 	now := time.Now()
@@ -127,6 +218,8 @@ type NotificationPreferences struct {
 	ChannelSettings map[string]bool `json:"channel_settings,omitempty"` // Per-channel preferences
 	QuietHoursStart *time.Time      `json:"quiet_hours_start,omitempty"`
 	QuietHoursEnd   *time.Time      `json:"quiet_hours_end,omitempty"`
+	Timezone        string          `json:"timezone"`         // IANA zone the quiet hours are in, e.g. "Europe/Berlin"
+	Locale          string          `json:"locale,omitempty"` // BCP 47 tag for templated copy, e.g. "de-AT"
 	CreatedAt       time.Time       `json:"created_at"`
 	UpdatedAt       time.Time       `json:"updated_at"`
 }
@@ -139,6 +232,7 @@ func NewDefaultPreferences(userID uuid.UUID) *NotificationPreferences {
 		EmailEnabled:    true,
 		PushEnabled:     true,
 		ChannelSettings: make(map[string]bool),
+		Timezone:        "UTC",
 		CreatedAt:       now,
 		UpdatedAt:       now,
 	}
@@ -154,13 +248,32 @@ func (p *NotificationPreferences) IsChannelEnabled(channel string) bool {
 	return true
 }
 
+// Location returns the user's time zone, falling back to UTC if it is unset or unknown.
+func (p *NotificationPreferences) Location() *time.Location {
+	if p.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
 // IsInQuietHours checks if current time is within quiet hours.
 func (p *NotificationPreferences) IsInQuietHours() bool {
+	return p.IsInQuietHoursAt(time.Now())
+}
+
+// IsInQuietHoursAt checks if the given time is within quiet hours.
+// Quiet hours are wall-clock times in the user's time zone, so now is
+// converted to that zone before comparing.
+func (p *NotificationPreferences) IsInQuietHoursAt(now time.Time) bool {
 	if p.QuietHoursStart == nil || p.QuietHoursEnd == nil {
 		return false
 	}
 
-	now := time.Now()
+	now = now.In(p.Location())
 	currentTime := now.Hour()*60 + now.Minute()
 	startTime := p.QuietHoursStart.Hour()*60 + p.QuietHoursStart.Minute()
 	endTime := p.QuietHoursEnd.Hour()*60 + p.QuietHoursEnd.Minute()
@@ -172,3 +285,40 @@ func (p *NotificationPreferences) IsInQuietHours() bool {
 
 	return currentTime >= startTime && currentTime < endTime
 }
+
+// QuietHoursEndAfter returns the first time after now at which quiet hours end
+// in the user's time zone. Used to defer notifications that arrive during quiet hours.
+func (p *NotificationPreferences) QuietHoursEndAfter(now time.Time) time.Time {
+	if p.QuietHoursEnd == nil {
+		return now
+	}
+
+	local := now.In(p.Location())
+	hour, minute := p.QuietHoursEnd.Hour(), p.QuietHoursEnd.Minute()
+
+	end := wallClock(local, 0, hour, minute)
+
+	// Overnight quiet hours (e.g., 22:00 - 07:00) seen from 23:00 end tomorrow
+	if !end.After(local) {
+		end = wallClock(local, 1, hour, minute)
+	}
+
+	return end
+}
+
+// wallClock returns hour:minute on the day days after day, in day's zone.
+// If a DST jump skips that wall-clock time, it returns the moment of the jump,
+// the first instant at which the clock reads later than hour:minute.
+func wallClock(day time.Time, days, hour, minute int) time.Time {
+	t := time.Date(day.Year(), day.Month(), day.Day()+days, hour, minute, 0, 0, day.Location())
+	if t.Hour() == hour && t.Minute() == minute {
+		return t
+	}
+
+	// time.Date picked an instant on one side of the jump; its zone boundary is the jump
+	start, end := t.ZoneBounds()
+	if t.Hour()*60+t.Minute() < hour*60+minute {
+		return end
+	}
+	return start
+}
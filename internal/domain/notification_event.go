@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationEventType is something a provider reported about a sent
+// notification after accepting it.
+type NotificationEventType string
+
+const (
+	NotificationEventDelivered   NotificationEventType = "delivered"   // Accepted by the recipient's server
+	NotificationEventBounced     NotificationEventType = "bounced"     // Rejected by the recipient's server for good
+	NotificationEventBlocked     NotificationEventType = "blocked"     // Refused by the recipient's server for now
+	NotificationEventDropped     NotificationEventType = "dropped"     // Not sent by the provider at all
+	NotificationEventOpened      NotificationEventType = "opened"      // Opened by the recipient
+	NotificationEventClicked     NotificationEventType = "clicked"     // A link in it was clicked
+	NotificationEventSpamReport  NotificationEventType = "spam_report" // Marked as spam by the recipient
+	NotificationEventUnsubscribe NotificationEventType = "unsubscribe" // The recipient unsubscribed from all email
+)
+
+// NotificationEvent is one entry in a notification's delivery history, as
+// reported by its provider (e.g. through SendGrid's event webhook).
+type NotificationEvent struct {
+	ID              uuid.UUID              `json:"id"`
+	NotificationID  uuid.UUID              `json:"notification_id"`
+	Provider        string                 `json:"provider"`
+	Type            NotificationEventType  `json:"type"`
+	ProviderEventID string                 `json:"provider_event_id,omitempty"` // Deduplicates redelivered webhooks
+	Recipient       string                 `json:"recipient,omitempty"`
+	Reason          string                 `json:"reason,omitempty"` // Why a bounce or drop happened
+	Data            map[string]interface{} `json:"data,omitempty"`   // The provider's raw event
+	OccurredAt      time.Time              `json:"occurred_at"`
+	CreatedAt       time.Time              `json:"created_at"`
+}
+
+// NewNotificationEvent creates a new event for a notification.
+func NewNotificationEvent(notificationID uuid.UUID, provider string, eventType NotificationEventType, occurredAt time.Time) *NotificationEvent {
+	return &NotificationEvent{
+		ID:             uuid.New(),
+		NotificationID: notificationID,
+		Provider:       provider,
+		Type:           eventType,
+		OccurredAt:     occurredAt,
+		CreatedAt:      time.Now(),
+	}
+}
@@ -0,0 +1,257 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+// clock builds a quiet hours boundary the way PreferencesHandler stores it
+// (time.Parse("15:04", ...) - only the hour and minute matter).
+func clock(hour, minute int) *time.Time {
+	t := time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+	return &t
+}
+
+func utc(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestIsInQuietHoursAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		start    *time.Time
+		end      *time.Time
+		now      time.Time
+		want     bool
+	}{
+		{
+			name:     "no quiet hours",
+			timezone: "UTC",
+			now:      utc(2026, time.June, 15, 3, 0),
+			want:     false,
+		},
+		{
+			name:     "same-day window, inside",
+			timezone: "Europe/Berlin",
+			start:    clock(13, 0),
+			end:      clock(15, 0),
+			now:      utc(2026, time.June, 15, 12, 30), // 14:30 CEST
+			want:     true,
+		},
+		{
+			name:     "same-day window, end is exclusive",
+			timezone: "Europe/Berlin",
+			start:    clock(13, 0),
+			end:      clock(15, 0),
+			now:      utc(2026, time.June, 15, 13, 0), // 15:00 CEST
+			want:     false,
+		},
+		{
+			name:     "overnight window, before midnight",
+			timezone: "America/New_York",
+			start:    clock(22, 0),
+			end:      clock(7, 0),
+			now:      utc(2026, time.June, 16, 3, 30), // 23:30 EDT on the 15th
+			want:     true,
+		},
+		{
+			name:     "overnight window, after midnight",
+			timezone: "America/New_York",
+			start:    clock(22, 0),
+			end:      clock(7, 0),
+			now:      utc(2026, time.June, 16, 10, 59), // 06:59 EDT
+			want:     true,
+		},
+		{
+			name:     "overnight window, after end",
+			timezone: "America/New_York",
+			start:    clock(22, 0),
+			end:      clock(7, 0),
+			now:      utc(2026, time.June, 16, 11, 0), // 07:00 EDT
+			want:     false,
+		},
+		{
+			name:     "overnight window, before start",
+			timezone: "America/New_York",
+			start:    clock(22, 0),
+			end:      clock(7, 0),
+			now:      utc(2026, time.June, 16, 1, 59), // 21:59 EDT on the 15th
+			want:     false,
+		},
+		{
+			name:     "evaluated in the user's zone, not UTC",
+			timezone: "Asia/Tokyo",
+			start:    clock(22, 0),
+			end:      clock(7, 0),
+			now:      utc(2026, time.June, 15, 3, 0), // 12:00 JST, but inside the window in UTC
+			want:     false,
+		},
+		{
+			name:     "unknown zone falls back to UTC",
+			timezone: "Mars/Olympus_Mons",
+			start:    clock(22, 0),
+			end:      clock(7, 0),
+			now:      utc(2026, time.June, 15, 3, 0),
+			want:     true,
+		},
+		{
+			name:     "spring forward, overnight window still active after the jump",
+			timezone: "America/New_York",
+			start:    clock(22, 0),
+			end:      clock(7, 0),
+			now:      utc(2026, time.March, 8, 10, 30), // 06:30 EDT, clocks jumped 02:00 -> 03:00
+			want:     true,
+		},
+		{
+			name:     "spring forward, overnight window over at 07:00 EDT",
+			timezone: "America/New_York",
+			start:    clock(22, 0),
+			end:      clock(7, 0),
+			now:      utc(2026, time.March, 8, 11, 0), // 07:00 EDT (would be 06:00 EST)
+			want:     false,
+		},
+		{
+			name:     "spring forward, window ending inside the skipped hour",
+			timezone: "America/New_York",
+			start:    clock(1, 0),
+			end:      clock(2, 30),
+			now:      utc(2026, time.March, 8, 6, 59), // 01:59 EST
+			want:     true,
+		},
+		{
+			name:     "spring forward, skipped hour counts as over",
+			timezone: "America/New_York",
+			start:    clock(1, 0),
+			end:      clock(2, 30),
+			now:      utc(2026, time.March, 8, 7, 0), // 03:00 EDT
+			want:     false,
+		},
+		{
+			name:     "fall back, first 01:15",
+			timezone: "America/New_York",
+			start:    clock(1, 0),
+			end:      clock(1, 30),
+			now:      utc(2026, time.November, 1, 5, 15), // 01:15 EDT
+			want:     true,
+		},
+		{
+			name:     "fall back, repeated 01:15",
+			timezone: "America/New_York",
+			start:    clock(1, 0),
+			end:      clock(1, 30),
+			now:      utc(2026, time.November, 1, 6, 15), // 01:15 EST
+			want:     true,
+		},
+		{
+			name:     "fall back, repeated hour after the window",
+			timezone: "America/New_York",
+			start:    clock(1, 0),
+			end:      clock(1, 30),
+			now:      utc(2026, time.November, 1, 6, 45), // 01:45 EST
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := &NotificationPreferences{
+				QuietHoursStart: tt.start,
+				QuietHoursEnd:   tt.end,
+				Timezone:        tt.timezone,
+			}
+
+			if got := prefs.IsInQuietHoursAt(tt.now); got != tt.want {
+				t.Errorf("IsInQuietHoursAt(%s) = %v, want %v", tt.now.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuietHoursEndAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		end      *time.Time
+		now      time.Time
+		want     time.Time
+	}{
+		{
+			name:     "same-day window ends later today",
+			timezone: "Europe/Berlin",
+			end:      clock(15, 0),
+			now:      utc(2026, time.June, 15, 12, 0), // 14:00 CEST
+			want:     utc(2026, time.June, 15, 13, 0), // 15:00 CEST
+		},
+		{
+			name:     "overnight window seen before midnight ends tomorrow",
+			timezone: "America/New_York",
+			end:      clock(7, 0),
+			now:      utc(2026, time.June, 16, 3, 0),  // 23:00 EDT on the 15th
+			want:     utc(2026, time.June, 16, 11, 0), // 07:00 EDT on the 16th
+		},
+		{
+			name:     "overnight window seen after midnight ends today",
+			timezone: "America/New_York",
+			end:      clock(7, 0),
+			now:      utc(2026, time.June, 16, 8, 0),  // 04:00 EDT
+			want:     utc(2026, time.June, 16, 11, 0), // 07:00 EDT
+		},
+		{
+			name:     "spring forward shortens the night by an hour",
+			timezone: "America/New_York",
+			end:      clock(7, 0),
+			now:      utc(2026, time.March, 8, 4, 0),  // 23:00 EST on the 7th
+			want:     utc(2026, time.March, 8, 11, 0), // 07:00 EDT, 7 hours later
+		},
+		{
+			name:     "fall back lengthens the night by an hour",
+			timezone: "America/New_York",
+			end:      clock(7, 0),
+			now:      utc(2026, time.November, 1, 3, 0),  // 23:00 EDT on Oct 31st
+			want:     utc(2026, time.November, 1, 12, 0), // 07:00 EST, 9 hours later
+		},
+		{
+			name:     "end inside the skipped hour resolves to the jump",
+			timezone: "America/New_York",
+			end:      clock(2, 30),
+			now:      utc(2026, time.March, 8, 6, 30), // 01:30 EST
+			want:     utc(2026, time.March, 8, 7, 0),  // 03:00 EDT
+		},
+		{
+			name:     "end inside the skipped hour, seen the evening before",
+			timezone: "America/New_York",
+			end:      clock(2, 30),
+			now:      utc(2026, time.March, 8, 3, 0), // 22:00 EST on the 7th
+			want:     utc(2026, time.March, 8, 7, 0), // 03:00 EDT
+		},
+		{
+			name:     "european spring forward",
+			timezone: "Europe/Berlin",
+			end:      clock(6, 0),
+			now:      utc(2026, time.March, 28, 22, 30), // 23:30 CET on the 28th
+			want:     utc(2026, time.March, 29, 4, 0),   // 06:00 CEST
+		},
+		{
+			name:     "no end time returns now",
+			timezone: "UTC",
+			now:      utc(2026, time.June, 15, 3, 0),
+			want:     utc(2026, time.June, 15, 3, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := &NotificationPreferences{
+				QuietHoursStart: clock(22, 0),
+				QuietHoursEnd:   tt.end,
+				Timezone:        tt.timezone,
+			}
+
+			if got := prefs.QuietHoursEndAfter(tt.now); !got.Equal(tt.want) {
+				t.Errorf("QuietHoursEndAfter(%s) = %s, want %s",
+					tt.now.Format(time.RFC3339), got.UTC().Format(time.RFC3339), tt.want.Format(time.RFC3339))
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -23,10 +24,19 @@ type NotificationRepository interface {
 	// GetByID retrieves a notification by its ID.
 	GetByID(ctx context.Context, id uuid.UUID) (*Notification, error)
 
+	// GetByIdempotencyKey retrieves the notification created for a user
+	// under an idempotency key.
+	GetByIdempotencyKey(ctx context.Context, key string, userID uuid.UUID) (*Notification, error)
+
 	// GetByUserID retrieves notifications for a specific user with pagination.
 	// Returns the notifications and total count for pagination.
 	GetByUserID(ctx context.Context, userID uuid.UUID, opts ListOptions) ([]*Notification, int64, error)
 
+	// GetInAppSince retrieves up to limit of a user's in-app notifications that
+	// came due after the cursor (dueAt, afterID), oldest first. afterID breaks
+	// ties between notifications due at the same instant; uuid.Nil means none.
+	GetInAppSince(ctx context.Context, userID uuid.UUID, dueAt time.Time, afterID uuid.UUID, limit int) ([]*Notification, error)
+
 	// UpdateStatus updates the status of a notification.
 	UpdateStatus(ctx context.Context, id uuid.UUID, status NotificationStatus) error
 
@@ -36,12 +46,71 @@ type NotificationRepository interface {
 	// MarkAllAsRead marks all notifications for a user as read.
 	MarkAllAsRead(ctx context.Context, userID uuid.UUID) error
 
+	// MarkDelivered records that a client acknowledged receiving the notification.
+	MarkDelivered(ctx context.Context, id uuid.UUID) error
+
+	// UpdateMetadata merges values into the notification's metadata,
+	// overwriting keys it already has.
+	UpdateMetadata(ctx context.Context, id uuid.UUID, values map[string]interface{}) error
+
 	// GetUnreadCount returns the count of unread notifications for a user.
 	GetUnreadCount(ctx context.Context, userID uuid.UUID) (int64, error)
 
-	// DeleteOlderThan removes notifications older than the specified duration.
+	// DeleteOlderThan removes notifications older than the specified duration
+	// and returns them, so their users' clients can be told.
 	// Useful for cleanup jobs.
-	DeleteOlderThan(ctx context.Context, days int) (int64, error)
+	DeleteOlderThan(ctx context.Context, days int) ([]*Notification, error)
+}
+
+// DeliveryJobRepository defines the interface for the delivery outbox.
+type DeliveryJobRepository interface {
+	// Enqueue saves a notification together with its delivery job in a single transaction.
+	// Returns ErrDuplicate if the user already has a notification under the
+	// same idempotency key that hasn't expired.
+	Enqueue(ctx context.Context, notification *Notification, job *DeliveryJob) error
+
+	// Claim locks up to limit pending jobs for the given worker.
+	// Jobs locked longer than lockTimeout are assumed abandoned and reclaimed.
+	Claim(ctx context.Context, workerID string, limit int, lockTimeout time.Duration) ([]*DeliveryJob, error)
+
+	// Complete, Release, Retry, DeadLetter and Fallback only change a job that
+	// workerID still holds. They return ErrLeaseLost once the job's lock has
+	// timed out and another worker claimed it.
+
+	// Complete marks a job as done.
+	Complete(ctx context.Context, id uuid.UUID, workerID string) error
+
+	// Release returns a claimed job to the queue without completing it.
+	Release(ctx context.Context, id uuid.UUID, workerID string) error
+
+	// Retry records a failed attempt and schedules the next one.
+	Retry(ctx context.Context, id uuid.UUID, workerID string, nextAttemptAt time.Time, lastError string) error
+
+	// DeadLetter records a final failed attempt and stops retrying the job.
+	DeadLetter(ctx context.Context, id uuid.UUID, workerID string, lastError string) error
+
+	// ListDeadLettered retrieves dead-lettered jobs with pagination.
+	ListDeadLettered(ctx context.Context, opts ListOptions) ([]*DeliveryJob, int64, error)
+
+	// Requeue resets the dead-lettered job of a notification so it is delivered again.
+	Requeue(ctx context.Context, notificationID uuid.UUID) error
+
+	// ReleaseScheduled moves up to limit scheduled jobs that are due by now
+	// into the pending queue, and returns how many were released.
+	ReleaseScheduled(ctx context.Context, now time.Time, limit int) (int64, error)
+
+	// Cancel cancels a scheduled notification and its job.
+	// Returns ErrNotFound if the notification doesn't exist, and ErrConflict
+	// if it isn't scheduled (any more), e.g. it was sent or cancelled.
+	Cancel(ctx context.Context, notificationID uuid.UUID) error
+
+	// Reschedule moves a scheduled notification to a new send time.
+	// Returns the same errors as Cancel.
+	Reschedule(ctx context.Context, notificationID uuid.UUID, sendAt time.Time) error
+
+	// Fallback saves a notification that was moved to the next channel of its
+	// fallback chain and puts its job back in the queue with a fresh attempt count.
+	Fallback(ctx context.Context, notification *Notification, job *DeliveryJob, workerID string, lastError string) error
 }
 
 // DeviceTokenRepository defines the interface for device token persistence.
@@ -71,6 +140,87 @@ type PreferencesRepository interface {
 	Upsert(ctx context.Context, prefs *NotificationPreferences) error
 }
 
+// IdempotencyRepository defines the interface for idempotency key persistence.
+type IdempotencyRepository interface {
+	// Reserve claims a key for a new request. It returns nil if the key was
+	// free (or its previous record had expired), otherwise the existing record.
+	// A reservation for the same request still in progress after lockTimeout
+	// is assumed abandoned and taken over.
+	Reserve(ctx context.Context, record *IdempotencyRecord, lockTimeout time.Duration) (*IdempotencyRecord, error)
+
+	// Complete stores the response for a reserved key.
+	Complete(ctx context.Context, key string, statusCode int, response []byte) error
+
+	// Release drops a reservation so the request can be retried.
+	Release(ctx context.Context, key string) error
+
+	// PurgeExpired deletes up to limit records that expired before now and
+	// returns how many were deleted.
+	PurgeExpired(ctx context.Context, now time.Time, limit int) (int64, error)
+}
+
+// WebSocketTicketRepository defines the interface for the single-use tickets
+// that authenticate WebSocket connections. Tickets are stored by hash only.
+type WebSocketTicketRepository interface {
+	// Create stores a ticket for a user until expiresAt.
+	Create(ctx context.Context, ticketHash string, userID uuid.UUID, expiresAt time.Time) error
+
+	// Consume deletes a ticket and returns the user it was issued to.
+	// Returns ErrNotFound if the ticket is unknown, already used or expired.
+	Consume(ctx context.Context, ticketHash string) (uuid.UUID, error)
+}
+
+// NotificationEventRepository defines the interface for the delivery history
+// providers report about sent notifications.
+type NotificationEventRepository interface {
+	// Create saves an event. Returns ErrDuplicate if an event with the same
+	// provider and provider event ID was already saved.
+	Create(ctx context.Context, event *NotificationEvent) error
+
+	// GetByNotificationID retrieves a notification's events, oldest first.
+	GetByNotificationID(ctx context.Context, notificationID uuid.UUID) ([]*NotificationEvent, error)
+}
+
+// SuppressionRepository defines the interface for the email addresses that
+// must not be emailed. Addresses are stored normalized (see NormalizeEmail).
+type SuppressionRepository interface {
+	// Add suppresses an address. Adding an address that is already suppressed
+	// keeps its original entry.
+	Add(ctx context.Context, suppression *Suppression) error
+
+	// Get retrieves the suppression for an address.
+	// Returns ErrNotFound if the address isn't suppressed.
+	Get(ctx context.Context, email string) (*Suppression, error)
+
+	// List retrieves suppressed addresses, most recent first.
+	List(ctx context.Context, opts ListOptions) ([]*Suppression, int64, error)
+
+	// Remove lifts an address's suppression.
+	// Returns ErrNotFound if the address isn't suppressed.
+	Remove(ctx context.Context, email string) error
+}
+
+// WorkflowRunRepository defines the interface for workflow run persistence.
+type WorkflowRunRepository interface {
+	// Create saves a new workflow run.
+	Create(ctx context.Context, run *WorkflowRun) error
+
+	// GetByID retrieves a workflow run by its ID.
+	GetByID(ctx context.Context, id uuid.UUID) (*WorkflowRun, error)
+
+	// ClaimDue locks up to limit running workflow runs whose next step is due
+	// by pushing their next_run_at lease into the future. A run whose engine
+	// dies mid-step becomes due again once the lease expires.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*WorkflowRun, error)
+
+	// Update saves a run's progress (step, status, next run and notifications).
+	Update(ctx context.Context, run *WorkflowRun) error
+
+	// Cancel stops a running workflow run.
+	// Returns ErrNotFound if the run doesn't exist or isn't running any more.
+	Cancel(ctx context.Context, id uuid.UUID) error
+}
+
 // ErrNotFound is returned when a requested entity doesn't exist.
 // In Go, errors are values - we define custom errors as variables.
 type ErrNotFound struct {
@@ -86,3 +236,48 @@ func (e *ErrNotFound) Error() string {
 func NewErrNotFound(entity, id string) *ErrNotFound {
 	return &ErrNotFound{Entity: entity, ID: id}
 }
+
+// ErrDuplicate is returned when an entity with the same unique key already exists.
+type ErrDuplicate struct {
+	Entity string
+	Key    string
+}
+
+func (e *ErrDuplicate) Error() string {
+	return e.Entity + " already exists: " + e.Key
+}
+
+// NewErrDuplicate creates a new duplicate error.
+func NewErrDuplicate(entity, key string) *ErrDuplicate {
+	return &ErrDuplicate{Entity: entity, Key: key}
+}
+
+// ErrConflict is returned when an entity exists but its state doesn't allow
+// the change, e.g. cancelling a notification that was already sent.
+type ErrConflict struct {
+	Message string
+}
+
+func (e *ErrConflict) Error() string {
+	return e.Message
+}
+
+// NewErrConflict creates a new conflict error.
+func NewErrConflict(message string) *ErrConflict {
+	return &ErrConflict{Message: message}
+}
+
+// ErrValidation is returned when input is well-formed but not acceptable,
+// e.g. a workflow delay override for a step that doesn't exist.
+type ErrValidation struct {
+	Message string
+}
+
+func (e *ErrValidation) Error() string {
+	return e.Message
+}
+
+// NewErrValidation creates a new validation error.
+func NewErrValidation(message string) *ErrValidation {
+	return &ErrValidation{Message: message}
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// SuppressionReason records why an address is no longer emailed.
+type SuppressionReason string
+
+const (
+	SuppressionReasonBounce      SuppressionReason = "bounce"      // Hard bounce: the address doesn't exist
+	SuppressionReasonSpamReport  SuppressionReason = "spam_report" // The recipient marked an email as spam
+	SuppressionReasonUnsubscribe SuppressionReason = "unsubscribe" // The recipient unsubscribed from all email
+	SuppressionReasonManual      SuppressionReason = "manual"      // Added through the internal API
+)
+
+// Suppression is an email address that must not be emailed again. Sending to
+// bounced or complaining addresses hurts the sender reputation of every email.
+type Suppression struct {
+	Email     string            `json:"email"`
+	Reason    SuppressionReason `json:"reason"`
+	Source    string            `json:"source"`            // Who added it, e.g. "sendgrid" or "api"
+	Details   string            `json:"details,omitempty"` // e.g. the bounce message
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// NewSuppression creates a suppression for an address.
+func NewSuppression(email string, reason SuppressionReason, source string) *Suppression {
+	return &Suppression{
+		Email:     NormalizeEmail(email),
+		Reason:    reason,
+		Source:    source,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NormalizeEmail returns the form addresses are stored and looked up in.
+// Domains are case-insensitive and virtually no mailbox treats its local
+// part differently, so the whole address is lowercased.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ErrSuppressed is returned instead of sending an email to a suppressed address.
+type ErrSuppressed struct {
+	Email  string
+	Reason SuppressionReason
+}
+
+func (e *ErrSuppressed) Error() string {
+	return "email address is suppressed (" + string(e.Reason) + "): " + e.Email
+}
+
+// NewErrSuppressed creates a new suppressed error.
+func NewErrSuppressed(s *Suppression) *ErrSuppressed {
+	return &ErrSuppressed{Email: s.Email, Reason: s.Reason}
+}
@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkflowCondition decides whether a workflow step runs, based on the
+// notifications sent by the earlier steps of the same run.
+type WorkflowCondition string
+
+const (
+	// WorkflowConditionAlways runs the step unconditionally (the default).
+	WorkflowConditionAlways WorkflowCondition = "always"
+	// WorkflowConditionUnread runs the step if none of the earlier notifications was read.
+	WorkflowConditionUnread WorkflowCondition = "unread"
+	// WorkflowConditionNotDelivered runs the step if none of the earlier notifications was sent.
+	WorkflowConditionNotDelivered WorkflowCondition = "not_delivered"
+	// WorkflowConditionFailed runs the step if all of the earlier notifications failed.
+	WorkflowConditionFailed WorkflowCondition = "failed"
+)
+
+// Workflow is a named, multi-step notification sequence, e.g. "push now,
+// email if still unread after 30 minutes".
+type Workflow struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Template    string         `json:"template,omitempty"` // Default template for steps without their own
+	Steps       []WorkflowStep `json:"steps"`
+}
+
+// WorkflowStep is one step of a workflow.
+type WorkflowStep struct {
+	Name      string             `json:"name"`
+	Channels  []NotificationType `json:"channels"`
+	Template  string             `json:"template,omitempty"`
+	Delay     string             `json:"delay,omitempty"` // Go duration after the previous step, e.g. "30m"
+	Condition WorkflowCondition  `json:"condition,omitempty"`
+
+	// Fallback sends Channels as a fallback chain instead of to every channel
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// Met reports whether the condition holds for the notifications sent so far.
+func (c WorkflowCondition) Met(sent []*Notification) bool {
+	switch c {
+	case WorkflowConditionUnread:
+		for _, n := range sent {
+			if n.IsRead() {
+				return false
+			}
+		}
+		return true
+
+	case WorkflowConditionNotDelivered:
+		for _, n := range sent {
+			if n.Status == NotificationStatusSent || n.Status == NotificationStatusDelivered {
+				return false
+			}
+		}
+		return true
+
+	case WorkflowConditionFailed:
+		for _, n := range sent {
+			switch n.Status {
+			case NotificationStatusFailed, NotificationStatusDeadLettered, NotificationStatusBounced, NotificationStatusSuppressed:
+			default:
+				return false
+			}
+		}
+		return len(sent) > 0
+
+	default:
+		return true
+	}
+}
+
+// Engaged reports whether the user has read any of the notifications, which
+// ends the workflow: there is no point escalating once they have seen it.
+func Engaged(sent []*Notification) bool {
+	for _, n := range sent {
+		if n.IsRead() {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkflowRunStatus tracks the state of a workflow run.
+type WorkflowRunStatus string
+
+const (
+	WorkflowRunStatusRunning   WorkflowRunStatus = "running"
+	WorkflowRunStatusCompleted WorkflowRunStatus = "completed"
+	WorkflowRunStatusCancelled WorkflowRunStatus = "cancelled"
+	WorkflowRunStatusFailed    WorkflowRunStatus = "failed"
+)
+
+// WorkflowInput is what a workflow was started with; every step is sent with it.
+type WorkflowInput struct {
+	Email    string                 `json:"email,omitempty"`
+	Title    string                 `json:"title,omitempty"`
+	Body     string                 `json:"body,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	Locale   string                 `json:"locale,omitempty"`
+	Critical bool                   `json:"critical,omitempty"`
+
+	// Delays overrides step delays by step name, e.g. {"email": "10m"}
+	Delays map[string]string `json:"delays,omitempty"`
+}
+
+// WorkflowRun is one execution of a workflow for a user. Runs are stored so
+// a restart never loses a pending step.
+type WorkflowRun struct {
+	ID              uuid.UUID         `json:"id"`
+	Workflow        string            `json:"workflow"`
+	UserID          uuid.UUID         `json:"user_id"`
+	Input           WorkflowInput     `json:"input"`
+	Status          WorkflowRunStatus `json:"status"`
+	Step            int               `json:"step"` // Index of the next step to run
+	NextRunAt       time.Time         `json:"next_run_at"`
+	NotificationIDs []uuid.UUID       `json:"notification_ids"`
+	Outcome         string            `json:"outcome,omitempty"` // Why the run ended, or its last error
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// NewWorkflowRun creates a running workflow run whose first step is due at firstRunAt.
+func NewWorkflowRun(workflow string, userID uuid.UUID, input WorkflowInput, firstRunAt time.Time) *WorkflowRun {
+	now := time.Now()
+	return &WorkflowRun{
+		ID:              uuid.New(),
+		Workflow:        workflow,
+		UserID:          userID,
+		Input:           input,
+		Status:          WorkflowRunStatusRunning,
+		NextRunAt:       firstRunAt,
+		NotificationIDs: []uuid.UUID{},
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+}
+
+// Finish ends the run with status, recording why.
+func (r *WorkflowRun) Finish(status WorkflowRunStatus, outcome string) {
+	r.Status = status
+	r.Outcome = outcome
+	r.UpdatedAt = time.Now()
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+// withStatuses builds unread notifications in the given statuses.
+func withStatuses(statuses ...NotificationStatus) []*Notification {
+	var sent []*Notification
+	for _, status := range statuses {
+		sent = append(sent, &Notification{Status: status})
+	}
+	return sent
+}
+
+// readNotification builds a notification the user has read.
+func readNotification() *Notification {
+	now := time.Now()
+	return &Notification{Status: NotificationStatusSent, ReadAt: &now}
+}
+
+func TestWorkflowConditionMet(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition WorkflowCondition
+		sent      []*Notification
+		want      bool
+	}{
+		{"empty condition", "", []*Notification{readNotification()}, true},
+		{"always", WorkflowConditionAlways, []*Notification{readNotification()}, true},
+		{"unknown condition", WorkflowCondition("sometimes"), nil, true},
+
+		{"unread, nothing sent", WorkflowConditionUnread, nil, true},
+		{"unread, none read", WorkflowConditionUnread, withStatuses(NotificationStatusSent, NotificationStatusPending), true},
+		{"unread, one read", WorkflowConditionUnread, append(withStatuses(NotificationStatusSent), readNotification()), false},
+
+		{"not delivered, nothing sent", WorkflowConditionNotDelivered, nil, true},
+		{"not delivered, still pending", WorkflowConditionNotDelivered, withStatuses(NotificationStatusPending, NotificationStatusFailed), true},
+		{"not delivered, one sent", WorkflowConditionNotDelivered, withStatuses(NotificationStatusFailed, NotificationStatusSent), false},
+		{"not delivered, one delivered", WorkflowConditionNotDelivered, withStatuses(NotificationStatusDelivered), false},
+
+		{"failed, nothing sent", WorkflowConditionFailed, nil, false},
+		{"failed, all failed", WorkflowConditionFailed, withStatuses(NotificationStatusFailed, NotificationStatusDeadLettered, NotificationStatusBounced, NotificationStatusSuppressed), true},
+		{"failed, one still pending", WorkflowConditionFailed, withStatuses(NotificationStatusFailed, NotificationStatusPending), false},
+		{"failed, one sent", WorkflowConditionFailed, withStatuses(NotificationStatusDeadLettered, NotificationStatusSent), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.condition.Met(tt.sent); got != tt.want {
+				t.Errorf("%q.Met() = %v, want %v", tt.condition, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngaged(t *testing.T) {
+	tests := []struct {
+		name string
+		sent []*Notification
+		want bool
+	}{
+		{"nothing sent", nil, false},
+		{"none read", withStatuses(NotificationStatusSent, NotificationStatusDelivered), false},
+		{"one read", append(withStatuses(NotificationStatusFailed), readNotification()), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Engaged(tt.sent); got != tt.want {
+				t.Errorf("Engaged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
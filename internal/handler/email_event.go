@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/infrastructure/sendgrid"
+	"github.com/prepmyapp/notification/internal/service"
+)
+
+// maxWebhookBody caps an event webhook request. SendGrid batches up to a few
+// thousand events per request.
+const maxWebhookBody = 8 << 20
+
+// EmailEventHandler receives delivery events from email providers and lets
+// internal callers read a notification's event history.
+type EmailEventHandler struct {
+	events   *service.EmailEventService
+	sendGrid *sendgrid.WebhookVerifier // nil disables the SendGrid webhook
+}
+
+// NewEmailEventHandler creates a new email event handler. sendGrid verifies
+// SendGrid's webhook signatures; without it the webhook is not registered.
+func NewEmailEventHandler(events *service.EmailEventService, sendGrid *sendgrid.WebhookVerifier) *EmailEventHandler {
+	return &EmailEventHandler{events: events, sendGrid: sendGrid}
+}
+
+// SendGridWebhook ingests a batch of SendGrid events. Requests without a valid
+// signature are rejected. If any event can't be recorded the response is 500,
+// so SendGrid redelivers the batch; events recorded the first time are
+// deduplicated.
+func (h *EmailEventHandler) SendGridWebhook(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		return
+	}
+
+	// The signature covers the raw body, so verify before parsing anything
+	if !h.sendGrid.Verify(body, c.GetHeader(sendgrid.SignatureHeader), c.GetHeader(sendgrid.TimestampHeader)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	events, err := sendgrid.ParseEvents(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	failed := 0
+	for _, event := range events {
+		if err := h.events.Record(c.Request.Context(), event); err != nil {
+			if _, ok := err.(*domain.ErrNotFound); ok {
+				continue // Notification was deleted since
+			}
+			log.Printf("[EmailEvents] failed to record %s event for notification %s: %v", event.Type, event.NotificationID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recorded": len(events)})
+}
+
+// History returns the delivery events reported about a notification.
+func (h *EmailEventHandler) History(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification ID"})
+		return
+	}
+
+	events, err := h.events.History(c.Request.Context(), id)
+	if err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch notification events"})
+		return
+	}
+
+	if events == nil {
+		events = []*domain.NotificationEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// RegisterRoutes registers the event history route on the internal API.
+func (h *EmailEventHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/notifications/:id/events", h.History)
+}
+
+// RegisterWebhookRoutes registers the provider webhooks. They authenticate
+// requests by signature, so they go on a group without other auth.
+func (h *EmailEventHandler) RegisterWebhookRoutes(rg *gin.RouterGroup) {
+	if h.sendGrid != nil {
+		rg.POST("/webhooks/sendgrid", h.SendGridWebhook)
+	}
+}
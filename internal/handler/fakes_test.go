@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// The fakes embed the repository interfaces, so calling a method a test
+// doesn't expect panics instead of silently succeeding.
+
+// fakeNotificationRepo keeps notifications in memory. With err set, every
+// lookup fails with it.
+type fakeNotificationRepo struct {
+	domain.NotificationRepository
+
+	mu            sync.Mutex
+	err           error
+	notifications map[uuid.UUID]*domain.Notification
+}
+
+func newFakeNotificationRepo(notifications ...*domain.Notification) *fakeNotificationRepo {
+	r := &fakeNotificationRepo{notifications: make(map[uuid.UUID]*domain.Notification)}
+	for _, n := range notifications {
+		r.notifications[n.ID] = n
+	}
+	return r
+}
+
+func (r *fakeNotificationRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	n, ok := r.notifications[id]
+	if !ok {
+		return nil, domain.NewErrNotFound("notification", id.String())
+	}
+	copied := *n
+	return &copied, nil
+}
+
+// MarkAsRead fails like the PostgreSQL repository for unknown and already
+// read notifications.
+func (r *fakeNotificationRepo) MarkAsRead(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.notifications[id]
+	if !ok || n.ReadAt != nil {
+		return domain.NewErrNotFound("notification", id.String())
+	}
+	now := time.Now()
+	n.ReadAt = &now
+	return nil
+}
+
+func (r *fakeNotificationRepo) MarkAllAsRead(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, n := range r.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			n.ReadAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeNotificationRepo) MarkDelivered(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.notifications[id]
+	if !ok {
+		return domain.NewErrNotFound("notification", id.String())
+	}
+	n.Status = domain.NotificationStatusDelivered
+	return nil
+}
+
+// get returns a copy of a stored notification.
+func (r *fakeNotificationRepo) get(id uuid.UUID) domain.Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.notifications[id]
+}
+
+// GetInAppSince returns the user's in-app notifications after the cursor in
+// the order the query sorts them: by due time, then ID.
+func (r *fakeNotificationRepo) GetInAppSince(ctx context.Context, userID uuid.UUID, dueAt time.Time, afterID uuid.UUID, limit int) ([]*domain.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	after := func(n *domain.Notification) bool {
+		if !n.DueAt().Equal(dueAt) {
+			return n.DueAt().After(dueAt)
+		}
+		return n.ID.String() > afterID.String()
+	}
+
+	var notifications []*domain.Notification
+	for _, n := range r.notifications {
+		if n.UserID == userID && n.Type == domain.NotificationTypeInApp && after(n) {
+			copied := *n
+			notifications = append(notifications, &copied)
+		}
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		a, b := notifications[i], notifications[j]
+		if !a.DueAt().Equal(b.DueAt()) {
+			return a.DueAt().Before(b.DueAt())
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+	}
+	return notifications, nil
+}
+
+// fakePreferencesRepo keeps preferences per user and, like the PostgreSQL
+// repository, returns the defaults for users without any.
+type fakePreferencesRepo struct {
+	domain.PreferencesRepository
+
+	mu    sync.Mutex
+	prefs map[uuid.UUID]*domain.NotificationPreferences
+}
+
+func (r *fakePreferencesRepo) Get(ctx context.Context, userID uuid.UUID) (*domain.NotificationPreferences, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prefs, ok := r.prefs[userID]
+	if !ok {
+		return domain.NewDefaultPreferences(userID), nil
+	}
+	copied := *prefs
+	return &copied, nil
+}
+
+func (r *fakePreferencesRepo) Upsert(ctx context.Context, prefs *domain.NotificationPreferences) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.prefs == nil {
+		r.prefs = make(map[uuid.UUID]*domain.NotificationPreferences)
+	}
+	r.prefs[prefs.UserID] = prefs
+	return nil
+}
+
+// fakeJobRepo tracks the status of scheduled notifications and, like the
+// PostgreSQL repository, only cancels or reschedules ones still scheduled.
+type fakeJobRepo struct {
+	domain.DeliveryJobRepository
+
+	mu            sync.Mutex
+	notifications map[uuid.UUID]*domain.Notification
+}
+
+func newFakeJobRepo(notifications ...*domain.Notification) *fakeJobRepo {
+	r := &fakeJobRepo{notifications: make(map[uuid.UUID]*domain.Notification)}
+	for _, n := range notifications {
+		r.notifications[n.ID] = n
+	}
+	return r
+}
+
+func (r *fakeJobRepo) Cancel(ctx context.Context, notificationID uuid.UUID) error {
+	return r.updateScheduled(notificationID, func(n *domain.Notification) {
+		n.Status = domain.NotificationStatusCancelled
+	})
+}
+
+func (r *fakeJobRepo) Reschedule(ctx context.Context, notificationID uuid.UUID, sendAt time.Time) error {
+	return r.updateScheduled(notificationID, func(n *domain.Notification) {
+		n.ScheduledAt = &sendAt
+	})
+}
+
+func (r *fakeJobRepo) updateScheduled(id uuid.UUID, update func(n *domain.Notification)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.notifications[id]
+	if !ok {
+		return domain.NewErrNotFound("notification", id.String())
+	}
+	if !n.IsScheduled() {
+		return domain.NewErrConflict("notification " + id.String() + " is " + string(n.Status) + ", not scheduled")
+	}
+	update(n)
+	return nil
+}
+
+// get returns a copy of a stored notification.
+func (r *fakeJobRepo) get(id uuid.UUID) domain.Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.notifications[id]
+}
+
+// fakeEmailSender records the emails it sends, failing each with err.
+type fakeEmailSender struct {
+	mu   sync.Mutex
+	err  error
+	sent []*domain.EmailMessage
+}
+
+func (s *fakeEmailSender) SendEmail(ctx context.Context, msg *domain.EmailMessage) (*domain.EmailReceipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &domain.EmailReceipt{Provider: "fake"}, nil
+}
+
+func (s *fakeEmailSender) SendTemplate(ctx context.Context, to, templateID string, data map[string]interface{}) error {
+	panic("unexpected SendTemplate")
+}
+
+// emails returns the emails sent so far.
+func (s *fakeEmailSender) emails() []*domain.EmailMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*domain.EmailMessage(nil), s.sent...)
+}
@@ -1,24 +1,44 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
 	"github.com/prepmyapp/notification/internal/domain"
 	"github.com/prepmyapp/notification/internal/service"
+	"github.com/prepmyapp/notification/internal/templates"
 )
 
+// IdempotencyKeyHeader is the request header callers use to make notify requests safe to retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 // InternalHandler handles internal API requests from other services.
 type InternalHandler struct {
-	service *service.NotificationService
+	service         *service.NotificationService
+	idempotencyRepo domain.IdempotencyRepository
+	idempotencyTTL  time.Duration
+	idempotencyLock time.Duration
 }
 
 // NewInternalHandler creates a new internal API handler.
-func NewInternalHandler(svc *service.NotificationService) *InternalHandler {
-	return &InternalHandler{service: svc}
+// idempotencyTTL is how long a stored response is replayed for a repeated key;
+// idempotencyLock is how long a repeat waits for an unfinished original
+// before taking its key over.
+func NewInternalHandler(svc *service.NotificationService, idempotencyRepo domain.IdempotencyRepository, idempotencyTTL, idempotencyLock time.Duration) *InternalHandler {
+	return &InternalHandler{
+		service:         svc,
+		idempotencyRepo: idempotencyRepo,
+		idempotencyTTL:  idempotencyTTL,
+		idempotencyLock: idempotencyLock,
+	}
 }
 
 // NotifyRequest represents a request to send a notification.
@@ -27,17 +47,37 @@ type NotifyRequest struct {
 	Email    string                 `json:"email"`
 	Channels []string               `json:"channels" binding:"required"` // ["email", "push", "in_app"] or ["all"]
 	Template string                 `json:"template"`
+	Channel  string                 `json:"channel"` // e.g. "marketing"; defaults to the template name
 	Title    string                 `json:"title" binding:"required"`
 	Body     string                 `json:"body" binding:"required"`
 	HtmlBody string                 `json:"html_body"`
 	Data     map[string]interface{} `json:"data"`
+
+	// IdempotencyKey can be sent here or as the Idempotency-Key header (the header wins)
+	IdempotencyKey string `json:"idempotency_key"`
+
+	// Optional scheduling: an absolute send time, or a delay from now such as "2h" (not both)
+	SendAt *time.Time `json:"send_at"`
+	Delay  string     `json:"delay"`
+
+	// Critical notifications (OTP, password reset) are sent even during quiet hours,
+	// to channels the user turned off, and without an unsubscribe link
+	Critical bool `json:"critical"`
+
+	// Locale for the template (e.g. "de-AT"); defaults to the user's preferred locale
+	Locale string `json:"locale"`
+
+	// Fallback treats channels as an ordered chain, e.g. ["push", "in_app", "email"]:
+	// the notification is delivered once, on the first channel that works
+	Fallback bool `json:"fallback"`
 }
 
 // NotifyResponse represents the response from a notify request.
 type NotifyResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success         bool     `json:"success"`
+	Message         string   `json:"message,omitempty"`
+	NotificationIDs []string `json:"notification_ids,omitempty"`
+	Error           string   `json:"error,omitempty"`
 }
 
 // Notify sends a notification through the specified channels.
@@ -76,30 +116,75 @@ func (h *InternalHandler) Notify(c *gin.Context) {
 		return
 	}
 
+	sendAt, err := resolveSendAt(req.SendAt, req.Delay)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NotifyResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	locale, err := parseLocale(req.Locale)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, NotifyResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	key := idempotencyKey(c, req.IdempotencyKey)
+	req.IdempotencyKey = "" // Not part of the request fingerprint
+
 	// Build send request
 	sendReq := service.SendRequest{
 		UserID:   userID,
 		Email:    req.Email,
 		Channels: channels,
 		Template: req.Template,
+		Channel:  req.Channel,
 		Title:    req.Title,
 		Body:     req.Body,
 		HtmlBody: req.HtmlBody,
 		Data:     req.Data,
+		SendAt:   sendAt,
+		Critical: req.Critical,
+		Locale:   locale,
+		Fallback: req.Fallback,
 	}
 
-	// Send notification
-	if err := h.service.Send(c.Request.Context(), sendReq); err != nil {
-		c.JSON(http.StatusInternalServerError, NotifyResponse{
-			Success: false,
-			Error:   err.Error(),
-		})
-		return
-	}
+	h.idempotent(c, "notify", key, req, func(storedKey string, expiresAt time.Time) (int, interface{}) {
+		sendReq.IdempotencyKey, sendReq.IdempotencyExpiresAt = storedKey, expiresAt
+
+		// Queue notification for delivery
+		result, err := h.service.Send(c.Request.Context(), sendReq)
+		var missing *templates.ErrMissingVariables
+		if errors.As(err, &missing) {
+			return http.StatusUnprocessableEntity, NotifyResponse{
+				Success: false,
+				Error:   missing.Error(),
+			}
+		}
+		if errors.Is(err, domain.ErrChannelUnavailable) {
+			return http.StatusUnprocessableEntity, NotifyResponse{
+				Success: false,
+				Error:   err.Error(),
+			}
+		}
+		if err != nil {
+			return http.StatusInternalServerError, NotifyResponse{
+				Success:         false,
+				NotificationIDs: notificationIDs(result),
+				Error:           err.Error(),
+			}
+		}
 
-	c.JSON(http.StatusOK, NotifyResponse{
-		Success: true,
-		Message: "notification sent successfully",
+		return http.StatusAccepted, NotifyResponse{
+			Success:         true,
+			Message:         notifyMessage(result),
+			NotificationIDs: notificationIDs(result),
+		}
 	})
 }
 
@@ -109,9 +194,28 @@ type BulkNotifyRequest struct {
 	Emails   map[string]string      `json:"emails"` // userID -> email mapping
 	Channels []string               `json:"channels" binding:"required"`
 	Template string                 `json:"template"`
+	Channel  string                 `json:"channel"` // e.g. "marketing"; defaults to the template name
 	Title    string                 `json:"title" binding:"required"`
 	Body     string                 `json:"body" binding:"required"`
 	Data     map[string]interface{} `json:"data"`
+
+	// IdempotencyKey can be sent here or as the Idempotency-Key header (the header wins)
+	IdempotencyKey string `json:"idempotency_key"`
+
+	// Optional scheduling: an absolute send time, or a delay from now such as "2h" (not both)
+	SendAt *time.Time `json:"send_at"`
+	Delay  string     `json:"delay"`
+
+	// Critical notifications (OTP, password reset) are sent even during quiet hours,
+	// to channels the user turned off, and without an unsubscribe link
+	Critical bool `json:"critical"`
+
+	// Locale for the template (e.g. "de-AT"); defaults to the user's preferred locale
+	Locale string `json:"locale"`
+
+	// Fallback treats channels as an ordered chain, e.g. ["push", "in_app", "email"]:
+	// the notification is delivered once, on the first channel that works
+	Fallback bool `json:"fallback"`
 }
 
 // BulkNotifyResponse represents the response from a bulk notify request.
@@ -135,48 +239,191 @@ func (h *InternalHandler) NotifyBulk(c *gin.Context) {
 		return
 	}
 
-	var (
-		success int
-		failed  int
-		errors  []string
-	)
+	sendAt, err := resolveSendAt(req.SendAt, req.Delay)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	for _, userIDStr := range req.UserIDs {
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			failed++
-			errors = append(errors, "invalid user_id: "+userIDStr)
-			continue
+	locale, err := parseLocale(req.Locale)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := idempotencyKey(c, req.IdempotencyKey)
+	req.IdempotencyKey = "" // Not part of the request fingerprint
+
+	h.idempotent(c, "notify_bulk", key, req, func(storedKey string, expiresAt time.Time) (int, interface{}) {
+		var (
+			success int
+			failed  int
+			errors  []string
+		)
+
+		for _, userIDStr := range req.UserIDs {
+			userID, err := uuid.Parse(userIDStr)
+			if err != nil {
+				failed++
+				errors = append(errors, "invalid user_id: "+userIDStr)
+				continue
+			}
+
+			email := ""
+			if req.Emails != nil {
+				email = req.Emails[userIDStr]
+			}
+
+			// The key is scoped per user by the notifications unique index,
+			// so one key covers every recipient of the batch
+			sendReq := service.SendRequest{
+				UserID:               userID,
+				Email:                email,
+				Channels:             channels,
+				Template:             req.Template,
+				Channel:              req.Channel,
+				Title:                req.Title,
+				Body:                 req.Body,
+				Data:                 req.Data,
+				IdempotencyKey:       storedKey,
+				IdempotencyExpiresAt: expiresAt,
+				SendAt:               sendAt,
+				Critical:             req.Critical,
+				Locale:               locale,
+				Fallback:             req.Fallback,
+			}
+
+			if _, err := h.service.Send(c.Request.Context(), sendReq); err != nil {
+				failed++
+				errors = append(errors, userIDStr+": "+err.Error())
+			} else {
+				success++
+			}
 		}
 
-		email := ""
-		if req.Emails != nil {
-			email = req.Emails[userIDStr]
+		return http.StatusOK, BulkNotifyResponse{
+			Success: success,
+			Failed:  failed,
+			Errors:  errors,
 		}
+	})
+}
 
-		sendReq := service.SendRequest{
-			UserID:   userID,
-			Email:    email,
-			Channels: channels,
-			Template: req.Template,
-			Title:    req.Title,
-			Body:     req.Body,
-			Data:     req.Data,
+// resolveSendAt turns the optional send_at / delay fields into a send time.
+// It returns nil for immediate delivery.
+func resolveSendAt(sendAt *time.Time, delay string) (*time.Time, error) {
+	if sendAt != nil && delay != "" {
+		return nil, errors.New("send_at and delay are mutually exclusive")
+	}
+
+	if delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return nil, errors.New("invalid delay: use a duration such as \"90s\", \"30m\" or \"2h\"")
 		}
+		if d < 0 {
+			return nil, errors.New("delay must not be negative")
+		}
+		t := time.Now().Add(d)
+		return &t, nil
+	}
+
+	return sendAt, nil
+}
+
+// idempotencyKey returns the caller's idempotency key, preferring the header over the body field.
+func idempotencyKey(c *gin.Context, bodyKey string) string {
+	if key := c.GetHeader(IdempotencyKeyHeader); key != "" {
+		return key
+	}
+	return bodyKey
+}
+
+// idempotent runs handle at most once per idempotency key and replays its
+// stored response for repeats within the TTL. Without a key (or without a
+// repository) handle just runs.
+//
+// The reservation is taken before handle runs, so a concurrent duplicate gets
+// 409 Conflict instead of racing it. A 5xx response releases the key again
+// so the caller can retry; notifications that were already queued are then
+// matched by the idempotency_key column instead of being created twice.
+// handle gets the key scoped to the endpoint, and when it expires, to store
+// on the notifications it creates ("" and zero without a key).
+func (h *InternalHandler) idempotent(c *gin.Context, scope, key string, req interface{}, handle func(storedKey string, expiresAt time.Time) (int, interface{})) {
+	if key == "" || h.idempotencyRepo == nil {
+		status, body := handle("", time.Time{})
+		c.JSON(status, body)
+		return
+	}
+
+	requestHash, err := hashRequest(req)
+	if err != nil {
+		log.Printf("[Internal] ERROR: failed to hash request: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process idempotency key"})
+		return
+	}
 
-		if err := h.service.Send(c.Request.Context(), sendReq); err != nil {
-			failed++
-			errors = append(errors, userIDStr+": "+err.Error())
-		} else {
-			success++
+	ctx := c.Request.Context()
+	storedKey := scope + ":" + key
+	now := time.Now()
+
+	expiresAt := now.Add(h.idempotencyTTL)
+
+	existing, err := h.idempotencyRepo.Reserve(ctx, &domain.IdempotencyRecord{
+		Key:         storedKey,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   expiresAt,
+	}, h.idempotencyLock)
+	if err != nil {
+		log.Printf("[Internal] ERROR: failed to reserve idempotency key %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process idempotency key"})
+		return
+	}
+
+	if existing != nil {
+		switch {
+		case existing.RequestHash != requestHash:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key was already used for a different request"})
+		case !existing.IsComplete():
+			c.JSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is still in progress"})
+		default:
+			log.Printf("[Internal] Replaying response for idempotency key %s", key)
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.Response)
 		}
+		return
 	}
 
-	c.JSON(http.StatusOK, BulkNotifyResponse{
-		Success: success,
-		Failed:  failed,
-		Errors:  errors,
-	})
+	status, body := handle(storedKey, expiresAt)
+
+	response, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("[Internal] ERROR: failed to marshal response: %v", err)
+		_ = h.idempotencyRepo.Release(ctx, storedKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response"})
+		return
+	}
+
+	if status >= http.StatusInternalServerError {
+		if err := h.idempotencyRepo.Release(ctx, storedKey); err != nil {
+			log.Printf("[Internal] ERROR: failed to release idempotency key %s: %v", key, err)
+		}
+	} else if err := h.idempotencyRepo.Complete(ctx, storedKey, status, response); err != nil {
+		log.Printf("[Internal] ERROR: failed to store response for idempotency key %s: %v", key, err)
+	}
+
+	c.Data(status, "application/json; charset=utf-8", response)
+}
+
+// hashRequest fingerprints a request body so a reused key with a different payload can be rejected.
+func hashRequest(req interface{}) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
 }
 
 // parseChannels converts channel strings to NotificationType.
@@ -203,8 +450,183 @@ func (h *InternalHandler) parseChannels(channels []string) []domain.Notification
 	return result
 }
 
+// DeadLetterListRequest represents pagination parameters for dead letters.
+type DeadLetterListRequest struct {
+	Page  int `form:"page,default=1"`
+	Limit int `form:"limit,default=20"`
+}
+
+// DeadLetterListResponse represents a paginated list of dead-lettered notifications.
+type DeadLetterListResponse struct {
+	Items []*service.DeadLetter `json:"items"`
+	Total int64                 `json:"total"`
+	Page  int                   `json:"page"`
+	Limit int                   `json:"limit"`
+}
+
+// ListDeadLetters returns notifications whose delivery was given up on.
+func (h *InternalHandler) ListDeadLetters(c *gin.Context) {
+	var req DeadLetterListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Ensure reasonable limits
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+
+	items, total, err := h.service.ListDeadLetters(c.Request.Context(), domain.ListOptions{
+		Limit:  req.Limit,
+		Offset: (req.Page - 1) * req.Limit,
+	})
+	if err != nil {
+		log.Printf("[Internal] ERROR: failed to list dead letters: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch dead letters"})
+		return
+	}
+
+	c.JSON(http.StatusOK, DeadLetterListResponse{
+		Items: items,
+		Total: total,
+		Page:  req.Page,
+		Limit: req.Limit,
+	})
+}
+
+// RequeueDeadLetter puts a dead-lettered notification back in the delivery queue.
+func (h *InternalHandler) RequeueDeadLetter(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification ID"})
+		return
+	}
+
+	if err := h.service.RequeueDeadLetter(c.Request.Context(), id); err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "dead-lettered notification not found"})
+			return
+		}
+		log.Printf("[Internal] ERROR: failed to requeue notification %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to requeue notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "notification requeued for delivery"})
+}
+
+// ScheduleRequest represents a new send time for a scheduled notification.
+type ScheduleRequest struct {
+	SendAt *time.Time `json:"send_at"`
+	Delay  string     `json:"delay"`
+}
+
+// CancelScheduled cancels a notification that hasn't reached its send time yet.
+func (h *InternalHandler) CancelScheduled(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification ID"})
+		return
+	}
+
+	if err := h.service.CancelScheduled(c.Request.Context(), id); err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		}
+		if conflict, ok := err.(*domain.ErrConflict); ok {
+			c.JSON(http.StatusConflict, gin.H{"error": conflict.Error()})
+			return
+		}
+		log.Printf("[Internal] ERROR: failed to cancel notification %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "scheduled notification cancelled"})
+}
+
+// Reschedule moves a notification that hasn't reached its send time yet.
+func (h *InternalHandler) Reschedule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification ID"})
+		return
+	}
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sendAt, err := resolveSendAt(req.SendAt, req.Delay)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if sendAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "send_at or delay is required"})
+		return
+	}
+
+	if err := h.service.Reschedule(c.Request.Context(), id, *sendAt); err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		}
+		if conflict, ok := err.(*domain.ErrConflict); ok {
+			c.JSON(http.StatusConflict, gin.H{"error": conflict.Error()})
+			return
+		}
+		log.Printf("[Internal] ERROR: failed to reschedule notification %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reschedule notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "notification rescheduled",
+		"send_at": sendAt,
+	})
+}
+
+// notifyMessage describes what happened to the notifications queued by a send.
+func notifyMessage(result *service.SendResult) string {
+	if result != nil && len(result.Notifications) > 0 {
+		n := result.Notifications[0]
+		if n.IsScheduled() && n.ScheduledAt != nil {
+			if n.DeferReason == domain.DeferReasonQuietHours {
+				return "notification deferred until quiet hours end at " + n.ScheduledAt.Format(time.RFC3339)
+			}
+			return "notification scheduled for " + n.ScheduledAt.Format(time.RFC3339)
+		}
+	}
+	return "notification queued for delivery"
+}
+
+// notificationIDs extracts the IDs of the notifications queued by a send.
+func notificationIDs(result *service.SendResult) []string {
+	if result == nil {
+		return nil
+	}
+
+	ids := make([]string, 0, len(result.Notifications))
+	for _, n := range result.Notifications {
+		ids = append(ids, n.ID.String())
+	}
+	return ids
+}
+
 // RegisterRoutes registers internal API routes.
 func (h *InternalHandler) RegisterRoutes(rg *gin.RouterGroup) {
 	rg.POST("/notify", h.Notify)
 	rg.POST("/notify/bulk", h.NotifyBulk)
+	rg.GET("/dead-letters", h.ListDeadLetters)
+	rg.POST("/dead-letters/:id/requeue", h.RequeueDeadLetter)
+	rg.POST("/notifications/:id/cancel", h.CancelScheduled)
+	rg.PUT("/notifications/:id/schedule", h.Reschedule)
 }
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/service"
+)
+
+// newScheduleTestRouter serves the internal routes with scheduled
+// notifications kept in jobs.
+func newScheduleTestRouter(jobs *fakeJobRepo) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	svc := service.NewNotificationService(nil, jobs, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := gin.New()
+	NewInternalHandler(svc, nil, time.Hour, time.Minute).RegisterRoutes(router.Group("/internal/v1"))
+	return router
+}
+
+func TestCancelScheduled(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     domain.NotificationStatus
+		id         func(n *domain.Notification) string
+		wantStatus int
+		wantState  domain.NotificationStatus
+	}{
+		{"scheduled", domain.NotificationStatusScheduled, notificationID, http.StatusOK, domain.NotificationStatusCancelled},
+		{"already sent", domain.NotificationStatusSent, notificationID, http.StatusConflict, domain.NotificationStatusSent},
+		{"already cancelled", domain.NotificationStatusCancelled, notificationID, http.StatusConflict, domain.NotificationStatusCancelled},
+		{"unknown", domain.NotificationStatusScheduled, unknownID, http.StatusNotFound, domain.NotificationStatusScheduled},
+		{"invalid ID", domain.NotificationStatusScheduled, invalidID, http.StatusBadRequest, domain.NotificationStatusScheduled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := scheduledNotification(tt.status, time.Now().Add(time.Hour))
+			jobs := newFakeJobRepo(n)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/internal/v1/notifications/"+tt.id(n)+"/cancel", nil)
+			newScheduleTestRouter(jobs).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := jobs.get(n.ID).Status; got != tt.wantState {
+				t.Errorf("notification status = %s, want %s", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	sendAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	newSendAt := sendAt.Add(24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		status     domain.NotificationStatus
+		id         func(n *domain.Notification) string
+		body       string
+		wantStatus int
+		wantSendAt time.Time
+	}{
+		{"send_at", domain.NotificationStatusScheduled, notificationID, `{"send_at": "` + newSendAt.Format(time.RFC3339) + `"}`, http.StatusOK, newSendAt},
+		{"already sent", domain.NotificationStatusSent, notificationID, `{"delay": "2h"}`, http.StatusConflict, sendAt},
+		{"already cancelled", domain.NotificationStatusCancelled, notificationID, `{"delay": "2h"}`, http.StatusConflict, sendAt},
+		{"unknown", domain.NotificationStatusScheduled, unknownID, `{"delay": "2h"}`, http.StatusNotFound, sendAt},
+		{"no send time", domain.NotificationStatusScheduled, notificationID, `{}`, http.StatusBadRequest, sendAt},
+		{"invalid delay", domain.NotificationStatusScheduled, notificationID, `{"delay": "soon"}`, http.StatusBadRequest, sendAt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := scheduledNotification(tt.status, sendAt)
+			jobs := newFakeJobRepo(n)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/internal/v1/notifications/"+tt.id(n)+"/schedule", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			newScheduleTestRouter(jobs).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := *jobs.get(n.ID).ScheduledAt; !got.Equal(tt.wantSendAt) {
+				t.Errorf("scheduled_at = %s, want %s", got, tt.wantSendAt)
+			}
+		})
+	}
+}
+
+// scheduledNotification returns a notification that was scheduled for
+// sendAt and has since moved to status.
+func scheduledNotification(status domain.NotificationStatus, sendAt time.Time) *domain.Notification {
+	n := domain.NewNotification(uuid.New(), domain.NotificationTypeEmail, "reminder", "Interview tomorrow", "Good luck!")
+	n.Schedule(sendAt)
+	n.Status = status
+	return n
+}
+
+func notificationID(n *domain.Notification) string { return n.ID.String() }
+func unknownID(*domain.Notification) string        { return uuid.NewString() }
+func invalidID(*domain.Notification) string        { return "not-a-uuid" }
@@ -149,7 +149,7 @@ func (h *NotificationHandler) MarkAsRead(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.MarkAsRead(c.Request.Context(), id); err != nil {
+	if err := h.service.MarkAsRead(c.Request.Context(), userID, id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark notification as read"})
 		return
 	}
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"golang.org/x/text/language"
 
 	"github.com/prepmyapp/notification/internal/domain"
 	"github.com/prepmyapp/notification/internal/handler/middleware"
@@ -27,6 +29,8 @@ type PreferencesResponse struct {
 	PushEnabled     bool            `json:"push_enabled"`
 	ChannelSettings map[string]bool `json:"channel_settings"`
 	QuietHours      *QuietHours     `json:"quiet_hours,omitempty"`
+	Timezone        string          `json:"timezone"`
+	Locale          string          `json:"locale,omitempty"`
 }
 
 // QuietHours represents the quiet hours period.
@@ -41,6 +45,8 @@ type UpdatePreferencesRequest struct {
 	PushEnabled     *bool           `json:"push_enabled,omitempty"`
 	ChannelSettings map[string]bool `json:"channel_settings,omitempty"`
 	QuietHours      *QuietHours     `json:"quiet_hours,omitempty"`
+	Timezone        *string         `json:"timezone,omitempty"` // IANA name, e.g. "America/New_York"
+	Locale          *string         `json:"locale,omitempty"`   // BCP 47 tag, e.g. "de-AT"; "" clears it
 }
 
 // Get retrieves the current user's notification preferences.
@@ -61,6 +67,8 @@ func (h *PreferencesHandler) Get(c *gin.Context) {
 		EmailEnabled:    prefs.EmailEnabled,
 		PushEnabled:     prefs.PushEnabled,
 		ChannelSettings: prefs.ChannelSettings,
+		Timezone:        prefs.Timezone,
+		Locale:          prefs.Locale,
 	}
 
 	if prefs.QuietHoursStart != nil && prefs.QuietHoursEnd != nil {
@@ -110,6 +118,27 @@ func (h *PreferencesHandler) Update(c *gin.Context) {
 		}
 	}
 
+	// Quiet hours are evaluated in this zone
+	if req.Timezone != nil {
+		loc, err := time.LoadLocation(*req.Timezone)
+		// LoadLocation maps "" to UTC and "Local" to the server's zone; neither is what the user meant
+		if err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone, use an IANA name such as \"Europe/Berlin\""})
+			return
+		}
+		prefs.Timezone = loc.String()
+	}
+
+	// Templated notifications are rendered in this locale
+	if req.Locale != nil {
+		locale, err := parseLocale(*req.Locale)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		prefs.Locale = locale
+	}
+
 	// Handle quiet hours
 	if req.QuietHours != nil {
 		if req.QuietHours.Start != "" && req.QuietHours.End != "" {
@@ -143,6 +172,8 @@ func (h *PreferencesHandler) Update(c *gin.Context) {
 		EmailEnabled:    prefs.EmailEnabled,
 		PushEnabled:     prefs.PushEnabled,
 		ChannelSettings: prefs.ChannelSettings,
+		Timezone:        prefs.Timezone,
+		Locale:          prefs.Locale,
 	}
 
 	if prefs.QuietHoursStart != nil && prefs.QuietHoursEnd != nil {
@@ -155,6 +186,19 @@ func (h *PreferencesHandler) Update(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// parseLocale validates a BCP 47 language tag and returns its canonical form
+// ("de_at" becomes "de-AT"). An empty string is returned unchanged.
+func parseLocale(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	tag, err := language.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid locale %q, use a language tag such as \"de\" or \"de-AT\"", s)
+	}
+	return tag.String(), nil
+}
+
 // RegisterRoutes registers preferences routes on a router group.
 func (h *PreferencesHandler) RegisterRoutes(rg *gin.RouterGroup) {
 	prefs := rg.Group("/preferences")
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	ws "github.com/prepmyapp/notification/internal/infrastructure/websocket"
+)
+
+// Maximum number of missed notifications replayed on reconnect;
+// clients that were away longer reload the list over REST
+const maxReplay = 500
+
+// How far back the replay reaches when the since notification is unknown,
+// e.g. because retention cleanup deleted it. Rejecting the cursor instead
+// would fail every reconnect of a client that keeps sending it.
+const unknownSinceLookback = 24 * time.Hour
+
+// replayCursor marks the last notification a reconnecting client has seen.
+type replayCursor struct {
+	dueAt   time.Time
+	afterID uuid.UUID
+}
+
+// errInvalidSince is returned for a since cursor in neither accepted format.
+var errInvalidSince = errors.New("since must be a notification ID or an RFC 3339 timestamp")
+
+// validateSince checks the format of since without looking anything up, so a
+// malformed cursor is rejected before the request spends anything, such as
+// a single-use WebSocket ticket.
+func validateSince(since string) error {
+	if _, err := uuid.Parse(since); err == nil {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339Nano, since); err != nil {
+		return errInvalidSince
+	}
+	return nil
+}
+
+// parseReplayCursor resolves since, the ID of the last notification the
+// client saw or an RFC 3339 timestamp, into a replay cursor. An ID that is
+// unknown or belongs to another user replays the last unknownSinceLookback.
+func parseReplayCursor(ctx context.Context, notifications domain.NotificationRepository, userID uuid.UUID, since string) (*replayCursor, error) {
+	if id, err := uuid.Parse(since); err == nil {
+		n, err := notifications.GetByID(ctx, id)
+		if _, notFound := err.(*domain.ErrNotFound); err != nil && !notFound {
+			return nil, fmt.Errorf("failed to load since notification: %w", err)
+		}
+		if err != nil || n.UserID != userID {
+			log.Printf("[Replay] unknown since notification %s for user %s, replaying the last %s", id, userID, unknownSinceLookback)
+			return &replayCursor{dueAt: time.Now().UTC().Add(-unknownSinceLookback)}, nil
+		}
+		return &replayCursor{dueAt: n.DueAt(), afterID: n.ID}, nil
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, since)
+	if err != nil {
+		return nil, errInvalidSince
+	}
+	return &replayCursor{dueAt: ts.UTC()}, nil
+}
+
+// loadMissed returns the user's in-app notifications newer than cursor, oldest first.
+func loadMissed(ctx context.Context, notifications domain.NotificationRepository, userID uuid.UUID, cursor *replayCursor) ([]*domain.Notification, error) {
+	missed, err := notifications.GetInAppSince(ctx, userID, cursor.dueAt, cursor.afterID, maxReplay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load missed notifications: %w", err)
+	}
+	if len(missed) == maxReplay {
+		log.Printf("[Replay] replay for user %s truncated at %d notifications", userID, maxReplay)
+	}
+	return missed, nil
+}
+
+// skip reports whether a live event is dropped instead of sent to client: a
+// notification.created event for a notification on a channel the client did
+// not subscribe to, or a live copy of one that was already replayed. Each
+// notification is broadcast once, so a replayed ID is forgotten once its
+// copy is dropped.
+func skip(message []byte, client *ws.Client, replayed map[uuid.UUID]bool) bool {
+	var event struct {
+		Type    domain.EventType `json:"type"`
+		Payload struct {
+			ID      uuid.UUID `json:"id"`
+			Channel string    `json:"channel"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(message, &event); err != nil || event.Type != domain.EventNotificationCreated {
+		return false
+	}
+
+	if replayed[event.Payload.ID] {
+		delete(replayed, event.Payload.ID)
+		return true
+	}
+	return !client.WantsChannel(event.Payload.Channel)
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	ws "github.com/prepmyapp/notification/internal/infrastructure/websocket"
+	"github.com/prepmyapp/notification/internal/service"
+)
+
+// inAppAt returns an in-app notification for userID that came due at dueAt.
+func inAppAt(userID uuid.UUID, channel string, dueAt time.Time) *domain.Notification {
+	n := domain.NewNotification(userID, domain.NotificationTypeInApp, channel, "Hello", "Body")
+	n.CreatedAt = dueAt
+	return n
+}
+
+func TestParseReplayCursor(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Second)
+	own := inAppAt(userID, "", now.Add(-time.Hour))
+	scheduled := inAppAt(userID, "", now.Add(-2*time.Hour))
+	sendAt := now.Add(-time.Minute)
+	scheduled.ScheduledAt = &sendAt
+	foreign := inAppAt(uuid.New(), "", now.Add(-time.Hour))
+
+	tests := []struct {
+		name         string
+		since        string
+		repoErr      error
+		wantDueAt    time.Time
+		wantAfterID  uuid.UUID
+		wantLookback bool
+		wantErr      bool
+	}{
+		{name: "timestamp", since: now.Format(time.RFC3339), wantDueAt: now},
+		{name: "own notification", since: own.ID.String(), wantDueAt: own.CreatedAt, wantAfterID: own.ID},
+		{name: "scheduled notification resumes from its send time", since: scheduled.ID.String(), wantDueAt: sendAt, wantAfterID: scheduled.ID},
+		{name: "unknown notification", since: uuid.NewString(), wantLookback: true},
+		{name: "another user's notification", since: foreign.ID.String(), wantLookback: true},
+		{name: "repository failure", since: own.ID.String(), repoErr: errors.New("connection refused"), wantErr: true},
+		{name: "malformed", since: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeNotificationRepo(own, scheduled, foreign)
+			repo.err = tt.repoErr
+
+			cursor, err := parseReplayCursor(context.Background(), repo, userID, tt.since)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("cursor = %+v, want an error", cursor)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseReplayCursor: %v", err)
+			}
+
+			if tt.wantLookback {
+				want := time.Now().Add(-unknownSinceLookback)
+				if d := cursor.dueAt.Sub(want); d < -time.Minute || d > time.Minute {
+					t.Errorf("dueAt = %s, want about %s", cursor.dueAt, want)
+				}
+			} else if !cursor.dueAt.Equal(tt.wantDueAt) {
+				t.Errorf("dueAt = %s, want %s", cursor.dueAt, tt.wantDueAt)
+			}
+			if cursor.afterID != tt.wantAfterID {
+				t.Errorf("afterID = %s, want %s", cursor.afterID, tt.wantAfterID)
+			}
+		})
+	}
+}
+
+func TestSkip(t *testing.T) {
+	replayedID := uuid.New()
+
+	created := func(id uuid.UUID, channel string) []byte {
+		n := &domain.Notification{ID: id, Channel: channel}
+		data, err := json.Marshal(domain.NewEvent(domain.EventNotificationCreated, n))
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		return data
+	}
+	countChanged, err := json.Marshal(domain.NewEvent(domain.EventUnreadCountChanged, domain.UnreadCountPayload{Count: 1}))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		channels []string
+		message  []byte
+		want     bool
+	}{
+		{"new notification", nil, created(uuid.New(), "alert"), false},
+		{"replayed notification", nil, created(replayedID, "alert"), true},
+		{"subscribed channel", []string{"alert"}, created(uuid.New(), "alert"), false},
+		{"unsubscribed channel", []string{"otp"}, created(uuid.New(), "alert"), true},
+		{"other event types ignore subscriptions", []string{"otp"}, countChanged, false},
+		{"undecodable message", nil, []byte("not json"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &ws.Client{}
+			client.Subscribe(tt.channels)
+			replayed := map[uuid.UUID]bool{replayedID: true}
+
+			if got := skip(tt.message, client, replayed); got != tt.want {
+				t.Errorf("skip = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("replayed notification is dropped once", func(t *testing.T) {
+		client := &ws.Client{}
+		replayed := map[uuid.UUID]bool{replayedID: true}
+		message := created(replayedID, "")
+
+		if !skip(message, client, replayed) {
+			t.Fatal("first copy was not skipped")
+		}
+		if skip(message, client, replayed) {
+			t.Error("second copy was skipped, want the ID forgotten after the first")
+		}
+	})
+}
+
+// TestReplayOnConnect checks that a reconnecting client first gets what it
+// missed, oldest first, and then live events without the copies of
+// notifications the replay already wrote.
+func TestReplayOnConnect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	userID := uuid.New()
+	now := time.Now().UTC()
+	seen := inAppAt(userID, "", now.Add(-3*time.Minute))
+	second := inAppAt(userID, "", now.Add(-time.Minute))
+	first := inAppAt(userID, "", now.Add(-2*time.Minute))
+	other := inAppAt(uuid.New(), "", now.Add(-time.Minute))
+	repo := newFakeNotificationRepo(seen, second, first, other)
+
+	hub := newTestHub(t)
+	h := NewWebSocketHandler(hub, service.NewTicketService(&fakeTicketRepo{userID: userID}, time.Minute), nil, repo, nil)
+	conn := connect(t, hub, h, userID, url.Values{"since": {seen.ID.String()}})
+
+	// The broadcast of second raced the replay; then a new one arrives
+	live := inAppAt(userID, "", now)
+	for _, n := range []*domain.Notification{second, live} {
+		if err := hub.Notify(context.Background(), userID, n); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+
+	for _, want := range []*domain.Notification{first, second, live} {
+		event := readEvent(t, conn)
+		if got := eventType(t, event); got != domain.EventNotificationCreated {
+			t.Fatalf("type = %q, want %q", got, domain.EventNotificationCreated)
+		}
+		var payload domain.Notification
+		if err := json.Unmarshal(event["payload"], &payload); err != nil {
+			t.Fatalf("payload: %v", err)
+		}
+		if payload.ID != want.ID {
+			t.Errorf("got notification %s, want %s", payload.ID, want.ID)
+		}
+	}
+}
+
+// TestReplayFromUnknownSince checks that a since notification the server no
+// longer has, e.g. after retention cleanup, still lets the client connect,
+// with a replay of the recent notifications only.
+func TestReplayFromUnknownSince(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	userID := uuid.New()
+	now := time.Now().UTC()
+	old := inAppAt(userID, "", now.Add(-2*unknownSinceLookback))
+	recent := inAppAt(userID, "", now.Add(-time.Hour))
+
+	hub := newTestHub(t)
+	h := NewWebSocketHandler(hub, service.NewTicketService(&fakeTicketRepo{userID: userID}, time.Minute), nil, newFakeNotificationRepo(old, recent), nil)
+	conn := connect(t, hub, h, userID, url.Values{"since": {uuid.NewString()}})
+
+	var payload domain.Notification
+	if err := json.Unmarshal(readEvent(t, conn)["payload"], &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if payload.ID != recent.ID {
+		t.Errorf("replayed %s first, want the recent notification %s", payload.ID, recent.ID)
+	}
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/handler/middleware"
+	ws "github.com/prepmyapp/notification/internal/infrastructure/websocket"
+)
+
+const (
+	// Comment lines sent with this period keep proxies from closing an idle stream
+	sseHeartbeat = 25 * time.Second
+
+	// How long browsers wait before reconnecting a dropped stream
+	sseRetry = 5 * time.Second
+)
+
+// StreamHandler serves realtime events as Server-Sent Events, for networks
+// that block WebSocket upgrades. Streams receive the same events as sockets,
+// through the same hub.
+type StreamHandler struct {
+	hub           *ws.Hub
+	notifications domain.NotificationRepository // nil disables resume
+	heartbeat     time.Duration
+}
+
+// NewStreamHandler creates a new SSE handler. notifications is used to resume
+// dropped streams and may be nil.
+func NewStreamHandler(hub *ws.Hub, notifications domain.NotificationRepository) *StreamHandler {
+	return &StreamHandler{hub: hub, notifications: notifications, heartbeat: sseHeartbeat}
+}
+
+// Stream streams the authenticated user's events until the client disconnects.
+//
+// Each event is written as
+//
+//	id: <notification id>      (notification.created only)
+//	event: <event type>
+//	data: <event envelope>
+//
+// so a browser's EventSource resumes from the last notification it received
+// by sending Last-Event-ID when it reconnects. The first connection can pass
+// the same cursor as ?since=.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == uuid.Nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	since := c.GetHeader("Last-Event-ID")
+	if since == "" {
+		since = c.Query("since")
+	}
+	var cursor *replayCursor
+	if since != "" && h.notifications != nil {
+		var err error
+		cursor, err = parseReplayCursor(ctx, h.notifications, userID, since)
+		if err != nil {
+			if errors.Is(err, errInvalidSince) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("[Stream] ERROR: failed to resolve replay cursor: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve since"})
+			return
+		}
+	}
+
+	// Register before replaying, like the WebSocket handler: live events
+	// queue up in client.Send meanwhile
+	client := &ws.Client{
+		ID:     uuid.New(),
+		UserID: userID,
+		Send:   make(chan []byte, 256),
+	}
+	h.hub.Register(client)
+	defer h.hub.Unregister(client)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx response buffering
+	c.Status(http.StatusOK)
+
+	// The server's WriteTimeout would end the stream, so every write gets its own deadline
+	rc := http.NewResponseController(c.Writer)
+	write := func(format string, args ...interface{}) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(c.Writer, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if err := write("retry: %d\n\n", sseRetry.Milliseconds()); err != nil {
+		return
+	}
+
+	var replayed map[uuid.UUID]bool
+	if cursor != nil {
+		missed, err := loadMissed(ctx, h.notifications, userID, cursor)
+		if err != nil {
+			log.Printf("[Stream] replay for user %s failed: %v", userID, err)
+			return // The client reconnects with the same Last-Event-ID
+		}
+
+		replayed = make(map[uuid.UUID]bool, len(missed))
+		for _, n := range missed {
+			data, err := json.Marshal(domain.NewEvent(domain.EventNotificationCreated, n))
+			if err != nil {
+				log.Printf("[Stream] failed to marshal notification %s: %v", n.ID, err)
+				return
+			}
+			if err := writeSSE(write, data); err != nil {
+				return
+			}
+			replayed[n.ID] = true
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Client disconnected
+			return
+
+		case message, ok := <-client.Send:
+			if !ok {
+				// Hub dropped the client
+				return
+			}
+			if skip(message, client, replayed) {
+				continue
+			}
+			if err := writeSSE(write, message); err != nil {
+				return
+			}
+
+		case <-heartbeat.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeSSE writes an encoded event envelope as a Server-Sent Event.
+func writeSSE(write func(format string, args ...interface{}) error, data []byte) error {
+	var event struct {
+		Type    domain.EventType `json:"type"`
+		Payload struct {
+			ID uuid.UUID `json:"id"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return fmt.Errorf("invalid event: %w", err)
+	}
+
+	// Only notifications advance the resume cursor
+	if event.Type == domain.EventNotificationCreated {
+		return write("id: %s\nevent: %s\ndata: %s\n\n", event.Payload.ID, event.Type, data)
+	}
+	return write("event: %s\ndata: %s\n\n", event.Type, data)
+}
+
+// RegisterRoutes registers the SSE route.
+func (h *StreamHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/notifications/stream", h.Stream)
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// openStream starts h behind a test server and opens userID's stream with
+// the given Last-Event-ID, if any. Cancelling the returned function
+// disconnects the client.
+func openStream(t *testing.T, h *StreamHandler, userID uuid.UUID, lastEventID string) (*http.Response, *bufio.Reader, context.CancelFunc) {
+	t.Helper()
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("userID", userID) })
+	h.RegisterRoutes(router.Group("/api/v1"))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/notifications/stream", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp, bufio.NewReader(resp.Body), cancel
+}
+
+// readBlock reads the stream up to the next blank line, which ends an event
+// or comment.
+func readBlock(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+
+	var block strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v (read %q)", err, block.String())
+		}
+		if line == "\n" {
+			return block.String()
+		}
+		block.WriteString(line)
+	}
+}
+
+func TestWriteSSE(t *testing.T) {
+	n := &domain.Notification{ID: uuid.New()}
+	created, _ := json.Marshal(domain.NewEvent(domain.EventNotificationCreated, n))
+	count, _ := json.Marshal(domain.NewEvent(domain.EventUnreadCountChanged, domain.UnreadCountPayload{Count: 2}))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "notification carries its ID",
+			data: created,
+			want: fmt.Sprintf("id: %s\nevent: notification.created\ndata: %s\n\n", n.ID, created),
+		},
+		{
+			name: "other events leave the cursor alone",
+			data: count,
+			want: fmt.Sprintf("event: unread_count.changed\ndata: %s\n\n", count),
+		},
+		{name: "invalid event", data: []byte("{"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			write := func(format string, args ...interface{}) error {
+				_, err := fmt.Fprintf(&out, format, args...)
+				return err
+			}
+
+			err := writeSSE(write, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeSSE error = %v, want error: %v", err, tt.wantErr)
+			}
+			if out.String() != tt.want {
+				t.Errorf("wrote %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	userID := uuid.New()
+	now := time.Now().UTC()
+	seen := inAppAt(userID, "", now.Add(-2*time.Minute))
+	missed := inAppAt(userID, "", now.Add(-time.Minute))
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		repoErr     error
+		wantStatus  int
+		wantFirst   *domain.Notification // First notification replayed
+	}{
+		{name: "known notification", lastEventID: seen.ID.String(), wantStatus: http.StatusOK, wantFirst: missed},
+		{name: "unknown notification replays the lookback", lastEventID: uuid.NewString(), wantStatus: http.StatusOK, wantFirst: seen},
+		{name: "malformed", lastEventID: "yesterday", wantStatus: http.StatusBadRequest},
+		{name: "repository failure", lastEventID: seen.ID.String(), repoErr: errors.New("connection refused"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeNotificationRepo(seen, missed)
+			repo.err = tt.repoErr
+			h := NewStreamHandler(newTestHub(t), repo)
+
+			resp, body, _ := openStream(t, h, userID, tt.lastEventID)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantFirst == nil {
+				return
+			}
+
+			if got, want := readBlock(t, body), fmt.Sprintf("retry: %d\n", sseRetry.Milliseconds()); got != want {
+				t.Errorf("first block = %q, want %q", got, want)
+			}
+			if got := readBlock(t, body); !strings.HasPrefix(got, "id: "+tt.wantFirst.ID.String()+"\n") {
+				t.Errorf("replayed %q, want notification %s", got, tt.wantFirst.ID)
+			}
+		})
+	}
+}
+
+func TestStreamHeartbeat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	h := NewStreamHandler(newTestHub(t), nil)
+	h.heartbeat = 10 * time.Millisecond
+
+	_, body, _ := openStream(t, h, uuid.New(), "")
+	readBlock(t, body) // retry
+	if got := readBlock(t, body); got != ": heartbeat\n" {
+		t.Errorf("got %q, want a heartbeat comment", got)
+	}
+}
+
+// TestStreamUnregistersOnDisconnect checks that a client going away removes
+// its stream from the hub.
+func TestStreamUnregistersOnDisconnect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	userID := uuid.New()
+	hub := newTestHub(t)
+	_, body, disconnect := openStream(t, NewStreamHandler(hub, nil), userID, "")
+	readBlock(t, body) // retry, written after registering
+
+	if !hub.IsUserConnected(userID) {
+		t.Fatal("stream not registered with the hub")
+	}
+
+	disconnect()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.IsUserConnected(userID) {
+		if time.Now().After(deadline) {
+			t.Fatal("stream still registered after the client disconnected")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// suppressionSourceAPI marks suppressions added through the internal API.
+const suppressionSourceAPI = "api"
+
+// SuppressionHandler manages the email suppression list over the internal API.
+type SuppressionHandler struct {
+	repo domain.SuppressionRepository
+}
+
+// NewSuppressionHandler creates a new suppression handler.
+func NewSuppressionHandler(repo domain.SuppressionRepository) *SuppressionHandler {
+	return &SuppressionHandler{repo: repo}
+}
+
+// SuppressionListRequest represents pagination parameters for suppressions.
+type SuppressionListRequest struct {
+	Page  int `form:"page,default=1"`
+	Limit int `form:"limit,default=20"`
+}
+
+// SuppressionListResponse represents a paginated list of suppressed addresses.
+type SuppressionListResponse struct {
+	Items []*domain.Suppression `json:"items"`
+	Total int64                 `json:"total"`
+	Page  int                   `json:"page"`
+	Limit int                   `json:"limit"`
+}
+
+// AddSuppressionRequest represents a request to suppress an address.
+type AddSuppressionRequest struct {
+	Email   string `json:"email" binding:"required,email"`
+	Reason  string `json:"reason" binding:"omitempty,oneof=bounce spam_report unsubscribe manual"` // Default: manual
+	Details string `json:"details"`
+}
+
+// List returns suppressed addresses, most recent first.
+func (h *SuppressionHandler) List(c *gin.Context) {
+	var req SuppressionListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Ensure reasonable limits
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+
+	items, total, err := h.repo.List(c.Request.Context(), domain.ListOptions{
+		Limit:  req.Limit,
+		Offset: (req.Page - 1) * req.Limit,
+	})
+	if err != nil {
+		log.Printf("[Suppression] ERROR: failed to list suppressions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch suppressions"})
+		return
+	}
+
+	if items == nil {
+		items = []*domain.Suppression{}
+	}
+	c.JSON(http.StatusOK, SuppressionListResponse{
+		Items: items,
+		Total: total,
+		Page:  req.Page,
+		Limit: req.Limit,
+	})
+}
+
+// Get returns the suppression for an address.
+func (h *SuppressionHandler) Get(c *gin.Context) {
+	suppression, err := h.repo.Get(c.Request.Context(), c.Param("email"))
+	if err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "email address is not suppressed"})
+			return
+		}
+		log.Printf("[Suppression] ERROR: failed to get suppression: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch suppression"})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppression)
+}
+
+// Add suppresses an address. If it already is, the existing entry is kept
+// and returned.
+func (h *SuppressionHandler) Add(c *gin.Context) {
+	var req AddSuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reason := domain.SuppressionReasonManual
+	if req.Reason != "" {
+		reason = domain.SuppressionReason(req.Reason)
+	}
+
+	suppression := domain.NewSuppression(req.Email, reason, suppressionSourceAPI)
+	suppression.Details = req.Details
+
+	ctx := c.Request.Context()
+	if err := h.repo.Add(ctx, suppression); err != nil {
+		log.Printf("[Suppression] ERROR: failed to add suppression: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add suppression"})
+		return
+	}
+
+	stored, err := h.repo.Get(ctx, suppression.Email)
+	if err != nil {
+		log.Printf("[Suppression] ERROR: failed to get suppression: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch suppression"})
+		return
+	}
+
+	log.Printf("[Suppression] Suppressed %s (%s)", stored.Email, stored.Reason)
+	c.JSON(http.StatusOK, stored)
+}
+
+// Remove lifts an address's suppression so it is emailed again.
+func (h *SuppressionHandler) Remove(c *gin.Context) {
+	email := c.Param("email")
+	if err := h.repo.Remove(c.Request.Context(), email); err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "email address is not suppressed"})
+			return
+		}
+		log.Printf("[Suppression] ERROR: failed to remove suppression: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove suppression"})
+		return
+	}
+
+	log.Printf("[Suppression] Removed suppression for %s", domain.NormalizeEmail(email))
+	c.JSON(http.StatusOK, gin.H{"message": "suppression removed"})
+}
+
+// RegisterRoutes registers the suppression routes on the internal API.
+func (h *SuppressionHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	suppressions := rg.Group("/suppressions")
+	suppressions.GET("", h.List)
+	suppressions.POST("", h.Add)
+	suppressions.GET("/:email", h.Get)
+	suppressions.DELETE("/:email", h.Remove)
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/service"
+	"github.com/prepmyapp/notification/internal/templates"
+)
+
+// TemplateHandler lets internal callers preview templates and send test emails.
+type TemplateHandler struct {
+	service *service.NotificationService
+}
+
+// NewTemplateHandler creates a new template handler.
+func NewTemplateHandler(svc *service.NotificationService) *TemplateHandler {
+	return &TemplateHandler{service: svc}
+}
+
+// PreviewRequest is the data a template is rendered with.
+type PreviewRequest struct {
+	Data   map[string]interface{} `json:"data"`
+	Locale string                 `json:"locale"` // Defaults to the template's default locale
+}
+
+// TestSendRequest is a preview that is also emailed to Email.
+type TestSendRequest struct {
+	Email  string                 `json:"email" binding:"required,email"`
+	Data   map[string]interface{} `json:"data"`
+	Locale string                 `json:"locale"`
+}
+
+// PreviewResponse is a rendered template.
+type PreviewResponse struct {
+	Template  string `json:"template"`
+	Locale    string `json:"locale"` // The translation that was used after fallback
+	Subject   string `json:"subject"`
+	Text      string `json:"text"`
+	HTML      string `json:"html"`
+	PushTitle string `json:"push_title,omitempty"`
+	PushBody  string `json:"push_body,omitempty"`
+}
+
+// Preview renders a template with the supplied data and returns it without sending.
+func (h *TemplateHandler) Preview(c *gin.Context) {
+	name := c.Param("name")
+
+	var req PreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	locale, err := parseLocale(req.Locale)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rendered, err := h.service.PreviewTemplate(name, locale, req.Data)
+	if err != nil {
+		h.renderError(c, name, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, previewResponse(name, rendered))
+}
+
+// TestSend renders a template and emails it to the given address.
+// Nothing is stored, so test sends never show up in anyone's notifications.
+func (h *TemplateHandler) TestSend(c *gin.Context) {
+	name := c.Param("name")
+
+	var req TestSendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	locale, err := parseLocale(req.Locale)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rendered, err := h.service.SendTestEmail(c.Request.Context(), name, locale, req.Email, req.Data)
+	if err != nil {
+		h.renderError(c, name, err)
+		return
+	}
+
+	log.Printf("[Templates] Test email for %s sent to %s", name, req.Email)
+	c.JSON(http.StatusOK, previewResponse(name, rendered))
+}
+
+// renderError maps template errors to responses: unknown templates are 404s
+// and missing variables are validation errors listing what is missing.
+func (h *TemplateHandler) renderError(c *gin.Context, name string, err error) {
+	if _, ok := err.(*domain.ErrNotFound); ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+
+	var missing *templates.ErrMissingVariables
+	if errors.As(err, &missing) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   missing.Error(),
+			"missing": missing.Variables,
+		})
+		return
+	}
+
+	log.Printf("[Templates] ERROR: failed to render %s: %v", name, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// previewResponse converts a rendered template to its response.
+func previewResponse(name string, rendered *templates.Rendered) PreviewResponse {
+	return PreviewResponse{
+		Template:  name,
+		Locale:    rendered.Locale,
+		Subject:   rendered.Subject,
+		Text:      rendered.Text,
+		HTML:      rendered.HTML,
+		PushTitle: rendered.PushTitle,
+		PushBody:  rendered.PushBody,
+	}
+}
+
+// RegisterRoutes registers template routes.
+func (h *TemplateHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.POST("/templates/:name/preview", h.Preview)
+	rg.POST("/templates/:name/test", h.TestSend)
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prepmyapp/notification/internal/service"
+	"github.com/prepmyapp/notification/internal/templates"
+)
+
+// newTemplateTestRouter serves the template routes with the embedded
+// templates, emailing test sends through sender.
+func newTemplateTestRouter(t *testing.T, sender *fakeEmailSender) *gin.Engine {
+	t.Helper()
+	registry, err := templates.Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	svc := service.NewNotificationService(nil, nil, nil, nil, nil, sender, nil, nil, registry, nil)
+	router := gin.New()
+	NewTemplateHandler(svc).RegisterRoutes(router.Group("/internal/v1"))
+	return router
+}
+
+// postTemplate posts body to a template endpoint and decodes the response.
+func postTemplate(t *testing.T, router *gin.Engine, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response %q: %v", rec.Body, err)
+	}
+	return rec.Code, resp
+}
+
+func TestPreviewTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		body        string
+		wantStatus  int
+		wantLocale  string
+		wantSubject string
+		wantMissing []interface{}
+	}{
+		{
+			name:        "default locale",
+			template:    "otp_verification",
+			body:        `{"data": {"otp": "123456"}}`,
+			wantStatus:  http.StatusOK,
+			wantLocale:  "en",
+			wantSubject: "Your PrepMyApp verification code",
+		},
+		{
+			name:        "regional locale falls back to its language",
+			template:    "otp_verification",
+			body:        `{"data": {"otp": "123456"}, "locale": "de-AT"}`,
+			wantStatus:  http.StatusOK,
+			wantLocale:  "de",
+			wantSubject: "Dein PrepMyApp-Bestätigungscode",
+		},
+		{
+			name:        "missing variable",
+			template:    "otp_verification",
+			body:        `{"data": {}}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantMissing: []interface{}{"otp"},
+		},
+		{
+			name:       "unknown template",
+			template:   "no_such_template",
+			body:       `{"data": {}}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid locale",
+			template:   "otp_verification",
+			body:       `{"data": {"otp": "123456"}, "locale": "not a locale"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "malformed body",
+			template:   "otp_verification",
+			body:       `{"data":`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTemplateTestRouter(t, &fakeEmailSender{})
+			status, resp := postTemplate(t, router, "/internal/v1/templates/"+tt.template+"/preview", tt.body)
+
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %v)", status, tt.wantStatus, resp)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if resp["locale"] != tt.wantLocale || resp["subject"] != tt.wantSubject {
+					t.Errorf("locale, subject = %v, %v; want %s, %s", resp["locale"], resp["subject"], tt.wantLocale, tt.wantSubject)
+				}
+				if !strings.Contains(resp["text"].(string), "123456") || !strings.Contains(resp["html"].(string), "123456") {
+					t.Errorf("rendered text and HTML should contain the code: %v", resp)
+				}
+			}
+			if tt.wantMissing != nil && !reflect.DeepEqual(resp["missing"], tt.wantMissing) {
+				t.Errorf("missing = %v, want %v", resp["missing"], tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestTestSendTemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		body       string
+		senderErr  error
+		wantStatus int
+		wantSent   bool
+	}{
+		{"sends the rendered template", "otp_verification", `{"email": "qa@example.com", "data": {"otp": "123456"}}`, nil, http.StatusOK, true},
+		{"missing variable", "otp_verification", `{"email": "qa@example.com", "data": {}}`, nil, http.StatusUnprocessableEntity, false},
+		{"unknown template", "no_such_template", `{"email": "qa@example.com", "data": {}}`, nil, http.StatusNotFound, false},
+		{"missing email", "otp_verification", `{"data": {"otp": "123456"}}`, nil, http.StatusBadRequest, false},
+		{"invalid email", "otp_verification", `{"email": "qa", "data": {"otp": "123456"}}`, nil, http.StatusBadRequest, false},
+		{"provider error", "otp_verification", `{"email": "qa@example.com", "data": {"otp": "123456"}}`, errors.New("sendgrid: 503"), http.StatusInternalServerError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &fakeEmailSender{err: tt.senderErr}
+			router := newTemplateTestRouter(t, sender)
+			status, resp := postTemplate(t, router, "/internal/v1/templates/"+tt.template+"/test", tt.body)
+
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %v)", status, tt.wantStatus, resp)
+			}
+
+			sent := sender.emails()
+			if !tt.wantSent {
+				if len(sent) != 0 {
+					t.Errorf("sent %d email(s), want none", len(sent))
+				}
+				return
+			}
+			if len(sent) != 1 {
+				t.Fatalf("sent %d email(s), want 1", len(sent))
+			}
+			msg := sent[0]
+			if msg.To != "qa@example.com" || msg.Subject != "[TEST] Your PrepMyApp verification code" {
+				t.Errorf("sent to %q with subject %q", msg.To, msg.Subject)
+			}
+			if !strings.Contains(msg.Text, "123456") || !strings.Contains(msg.HTML, "123456") {
+				t.Errorf("email body should contain the code: %+v", msg)
+			}
+		})
+	}
+}
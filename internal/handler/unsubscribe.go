@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/service"
+)
+
+// unsubscribePage is the page behind an email's unsubscribe link. The GET
+// only asks for confirmation: link scanners and mail clients open links
+// (GET) on their own, so only the form's POST unsubscribes.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Unsubscribe</title>
+<style>body{font-family:sans-serif;max-width:32rem;margin:4rem auto;padding:0 1rem;color:#222}button{font-size:1rem;padding:.5rem 1.25rem}</style>
+</head>
+<body>
+{{if .Error}}
+<h1>Link not valid</h1>
+<p>{{.Error}}</p>
+{{else if .Done}}
+<h1>You're unsubscribed</h1>
+<p>You won't receive {{.Channel}} notifications from PrepMyApp any more. You can turn them back on in your notification settings.</p>
+{{else}}
+<h1>Unsubscribe</h1>
+<p>Stop receiving {{.Channel}} notifications from PrepMyApp?</p>
+<form method="post"><button type="submit">Unsubscribe</button></form>
+{{end}}
+</body>
+</html>
+`))
+
+// unsubscribeView is the data the unsubscribe page is rendered with.
+type unsubscribeView struct {
+	Channel string
+	Done    bool
+	Error   string
+}
+
+// UnsubscribeHandler serves the unsubscribe links in emails. It needs no
+// auth: the signed token in the URL says who unsubscribes from what.
+type UnsubscribeHandler struct {
+	service *service.UnsubscribeService
+}
+
+// NewUnsubscribeHandler creates a new unsubscribe handler.
+func NewUnsubscribeHandler(svc *service.UnsubscribeService) *UnsubscribeHandler {
+	return &UnsubscribeHandler{service: svc}
+}
+
+// Confirm shows the confirmation page for an unsubscribe link.
+func (h *UnsubscribeHandler) Confirm(c *gin.Context) {
+	_, channel, err := h.service.Parse(c.Param("token"))
+	if err != nil {
+		h.render(c, http.StatusBadRequest, unsubscribeView{Error: "This unsubscribe link is invalid or incomplete."})
+		return
+	}
+
+	h.render(c, http.StatusOK, unsubscribeView{Channel: channel})
+}
+
+// Unsubscribe turns off the link's notification channel for its user. Mail
+// clients POST here directly for one-click unsubscribe (RFC 8058), with the
+// body "List-Unsubscribe=One-Click"; browsers get here from the
+// confirmation page.
+func (h *UnsubscribeHandler) Unsubscribe(c *gin.Context) {
+	channel, err := h.service.Unsubscribe(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		if _, ok := err.(*domain.ErrValidation); ok {
+			h.render(c, http.StatusBadRequest, unsubscribeView{Error: "This unsubscribe link is invalid or incomplete."})
+			return
+		}
+		log.Printf("[Unsubscribe] ERROR: failed to unsubscribe: %v", err)
+		h.render(c, http.StatusInternalServerError, unsubscribeView{Error: "Something went wrong. Please try again later."})
+		return
+	}
+
+	h.render(c, http.StatusOK, unsubscribeView{Channel: channel, Done: true})
+}
+
+// render writes the unsubscribe page.
+func (h *UnsubscribeHandler) render(c *gin.Context, status int, view unsubscribeView) {
+	var buf bytes.Buffer
+	if err := unsubscribePage.Execute(&buf, view); err != nil {
+		log.Printf("[Unsubscribe] ERROR: failed to render page: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// RegisterRoutes registers the public unsubscribe routes.
+func (h *UnsubscribeHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/unsubscribe/:token", h.Confirm)
+	rg.POST("/unsubscribe/:token", h.Unsubscribe)
+}
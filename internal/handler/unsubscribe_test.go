@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/service"
+)
+
+func TestUnsubscribeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	userID := uuid.New()
+	signer := service.NewUnsubscribeService(nil, "secret", "")
+	token := signer.Token(userID, "marketing")
+
+	tests := []struct {
+		name             string
+		method           string
+		token            string
+		body             string
+		wantStatus       int
+		wantUnsubscribed bool
+	}{
+		// RFC 8058: mail clients POST this form body to the List-Unsubscribe URL
+		{"one-click", http.MethodPost, token, "List-Unsubscribe=One-Click", http.StatusOK, true},
+		{"confirmation form", http.MethodPost, token, "", http.StatusOK, true},
+		{"opening the link only asks", http.MethodGet, token, "", http.StatusOK, false},
+		{"invalid token", http.MethodPost, token + "x", "List-Unsubscribe=One-Click", http.StatusBadRequest, false},
+		{"invalid token page", http.MethodGet, "nonsense", "", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := &fakePreferencesRepo{}
+			h := NewUnsubscribeHandler(service.NewUnsubscribeService(prefs, "secret", ""))
+			router := gin.New()
+			h.RegisterRoutes(router.Group(""))
+
+			req := httptest.NewRequest(tt.method, "/unsubscribe/"+tt.token, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+				t.Errorf("Content-Type = %q, want an HTML page", ct)
+			}
+
+			current, _ := prefs.Get(context.Background(), userID)
+			if unsubscribed := !current.IsChannelEnabled("marketing"); unsubscribed != tt.wantUnsubscribed {
+				t.Errorf("unsubscribed = %v, want %v", unsubscribed, tt.wantUnsubscribed)
+			}
+		})
+	}
+}
@@ -1,28 +1,23 @@
 package handler
 
 import (
-	"errors"
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
+	"github.com/prepmyapp/notification/internal/domain"
 	ws "github.com/prepmyapp/notification/internal/infrastructure/websocket"
+	"github.com/prepmyapp/notification/internal/service"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// In production, implement proper origin checking
-		return true
-	},
-}
-
 const (
 	// Time allowed to write a message to the peer
 	writeWait = 10 * time.Second
@@ -39,36 +34,99 @@ const (
 
 // WebSocketHandler handles WebSocket connections.
 type WebSocketHandler struct {
-	hub       *ws.Hub
-	jwtSecret string
+	hub           *ws.Hub
+	service       *service.NotificationService  // nil disables commands other than subscribe
+	notifications domain.NotificationRepository // nil disables replay
+	tickets       *service.TicketService
+	upgrader      websocket.Upgrader
 }
 
-// NewWebSocketHandler creates a new WebSocket handler.
-func NewWebSocketHandler(hub *ws.Hub, jwtSecret string) *WebSocketHandler {
+// NewWebSocketHandler creates a new WebSocket handler. Connections are
+// authenticated with tickets from POST /api/v1/ws-ticket and browsers may
+// only connect from allowedOrigins. svc carries out the commands clients send
+// and notifications is used to replay missed notifications to reconnecting
+// clients; both may be nil.
+func NewWebSocketHandler(hub *ws.Hub, tickets *service.TicketService, svc *service.NotificationService, notifications domain.NotificationRepository, allowedOrigins []string) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub:       hub,
-		jwtSecret: jwtSecret,
+		hub:           hub,
+		service:       svc,
+		notifications: notifications,
+		tickets:       tickets,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     checkOrigin(allowedOrigins),
+		},
+	}
+}
+
+// checkOrigin returns an origin check accepting the given origins, e.g.
+// "https://prepmyapp.com". Requests without an Origin header come from
+// native apps rather than browsers and are accepted; cross-site requests
+// from browsers always carry one.
+func checkOrigin(allowedOrigins []string) func(r *http.Request) bool {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[strings.ToLower(origin)] = true
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if allowed[strings.ToLower(origin)] {
+			return true
+		}
+		log.Printf("[WebSocket] rejected connection from origin %q", origin)
+		return false
 	}
 }
 
 // HandleConnection handles incoming WebSocket connection requests.
 func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
-	// Get token from query param
-	token := c.Query("token")
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
+	// Get the ticket from the query param. Browsers can't set headers on a
+	// WebSocket handshake, so the credential has to be in the URL; a ticket
+	// is single-use and short-lived, unlike the user's JWT.
+	ticket := c.Query("ticket")
+	if ticket == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing ticket"})
 		return
 	}
 
-	// Validate token and extract user ID
-	userID, err := h.validateToken(token)
+	// Check the replay cursor's format first: redeeming uses the ticket up,
+	// and a client retrying after a 400 shouldn't need a new one
+	since := c.Query("since")
+	if since != "" && h.notifications != nil {
+		if err := validateSince(since); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Redeem the ticket for the user it was issued to
+	userID, err := h.tickets.Redeem(c.Request.Context(), ticket)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		if _, ok := err.(*domain.ErrNotFound); !ok {
+			log.Printf("[WebSocket] ERROR: failed to redeem ticket: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired ticket"})
 		return
 	}
 
+	// Resolve the replay cursor before upgrading, so a failure is a plain error response
+	var cursor *replayCursor
+	if since != "" && h.notifications != nil {
+		cursor, err = parseReplayCursor(c.Request.Context(), h.notifications, userID, since)
+		if err != nil {
+			log.Printf("[WebSocket] ERROR: failed to resolve replay cursor: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve since"})
+			return
+		}
+	}
+
 	// Upgrade HTTP connection to WebSocket
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade connection: %v", err)
 		return
@@ -82,41 +140,56 @@ func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
 		Send:   make(chan []byte, 256),
 	}
 
-	// Register client with hub
+	// Register client with hub. From here on live notifications queue up in
+	// client.Send, which nothing drains until the replay is written.
 	h.hub.Register(client)
-
-	// Start goroutines for reading and writing
-	go h.writePump(client)
 	go h.readPump(client)
-}
 
-// validateToken validates a JWT token and returns the user ID.
-func (h *WebSocketHandler) validateToken(tokenString string) (uuid.UUID, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
+	// Replay what was missed. Anything broadcast before the client registered
+	// was stored before it, so the query sees it; anything broadcast since is
+	// queued. Notifications in both are only written once.
+	var replayed map[uuid.UUID]bool
+	if cursor != nil {
+		replayed, err = h.replay(c.Request.Context(), client, cursor)
+		if err != nil {
+			log.Printf("[WebSocket] replay for user %s failed: %v", userID, err)
+			// Closing the socket makes readPump unregister the client;
+			// the client reconnects with the same cursor
+			_ = client.Conn.Close()
+			return
 		}
-		return []byte(h.jwtSecret), nil
-	})
-
-	if err != nil || !token.Valid {
-		return uuid.Nil, errors.New("invalid token")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return uuid.Nil, errors.New("invalid claims")
+	go h.writePump(client, replayed)
+}
+
+// replay writes the user's in-app notifications newer than cursor straight
+// to the socket, as notification.created events, and returns the IDs it wrote.
+func (h *WebSocketHandler) replay(ctx context.Context, client *ws.Client, cursor *replayCursor) (map[uuid.UUID]bool, error) {
+	missed, err := loadMissed(ctx, h.notifications, client.UserID, cursor)
+	if err != nil {
+		return nil, err
 	}
 
-	sub, ok := claims["sub"].(string)
-	if !ok {
-		return uuid.Nil, errors.New("missing sub claim")
+	replayed := make(map[uuid.UUID]bool, len(missed))
+	for _, n := range missed {
+		data, err := json.Marshal(domain.NewEvent(domain.EventNotificationCreated, n))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal notification %s: %w", n.ID, err)
+		}
+		if err := client.Conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+			return nil, err
+		}
+		if err := client.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return nil, err
+		}
+		replayed[n.ID] = true
 	}
 
-	return uuid.Parse(sub)
+	return replayed, nil
 }
 
-// readPump pumps messages from the WebSocket connection to the hub.
+// readPump reads commands from the WebSocket connection.
 func (h *WebSocketHandler) readPump(client *ws.Client) {
 	defer func() {
 		h.hub.Unregister(client)
@@ -134,20 +207,22 @@ func (h *WebSocketHandler) readPump(client *ws.Client) {
 	})
 
 	for {
-		_, _, err := client.Conn.ReadMessage()
+		_, message, err := client.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
-		// We don't process incoming messages for now
-		// This could be extended to handle client-side actions
+
+		// Incoming messages are commands, carried out in the order they arrive
+		h.handleCommand(client, message)
 	}
 }
 
-// writePump pumps messages from the hub to the WebSocket connection.
-func (h *WebSocketHandler) writePump(client *ws.Client) {
+// writePump pumps events from the hub to the WebSocket connection, one
+// event per frame.
+func (h *WebSocketHandler) writePump(client *ws.Client, replayed map[uuid.UUID]bool) {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
@@ -165,32 +240,16 @@ func (h *WebSocketHandler) writePump(client *ws.Client) {
 			}
 			if !ok {
 				// Hub closed the channel
-				if err := client.Conn.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
+				if err := client.Conn.WriteMessage(websocket.CloseMessage, client.CloseMessage()); err != nil {
 					log.Printf("failed to write close message: %v", err)
 				}
 				return
 			}
-
-			w, err := client.Conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			if _, err := w.Write(message); err != nil {
-				log.Printf("failed to write message: %v", err)
-			}
-
-			// Drain queued messages
-			n := len(client.Send)
-			for i := 0; i < n; i++ {
-				if _, err := w.Write([]byte{'\n'}); err != nil {
-					log.Printf("failed to write newline: %v", err)
-				}
-				if _, err := w.Write(<-client.Send); err != nil {
-					log.Printf("failed to write queued message: %v", err)
-				}
+			if skip(message, client, replayed) {
+				continue
 			}
 
-			if err := w.Close(); err != nil {
+			if err := client.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
 
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	ws "github.com/prepmyapp/notification/internal/infrastructure/websocket"
+)
+
+// Commands a client can send over the WebSocket.
+const (
+	commandMarkRead    = "mark_read"
+	commandMarkAllRead = "mark_all_read"
+	commandAck         = "ack"
+	commandSubscribe   = "subscribe"
+)
+
+// Time allowed to carry out a single command
+const commandTimeout = 10 * time.Second
+
+// Command is a message a client sends over the WebSocket, e.g.
+// {"request_id": "42", "command": "mark_read", "id": "<notification id>"}.
+type Command struct {
+	RequestID string   `json:"request_id"` // Echoed in the reply
+	Command   string   `json:"command"`
+	ID        string   `json:"id,omitempty"`       // Notification ID for mark_read and ack
+	Channels  []string `json:"channels,omitempty"` // For subscribe
+}
+
+// CommandReply answers a Command. It is sent as a command.reply event.
+type CommandReply struct {
+	RequestID string      `json:"request_id"`
+	OK        bool        `json:"ok"`
+	Error     string      `json:"error,omitempty"`
+	Result    interface{} `json:"result,omitempty"`
+}
+
+// handleCommand carries out a command sent by client and queues the reply.
+func (h *WebSocketHandler) handleCommand(client *ws.Client, message []byte) {
+	var cmd Command
+	if err := json.Unmarshal(message, &cmd); err != nil {
+		h.reply(client, CommandReply{Error: "invalid command"})
+		return
+	}
+
+	// The HTTP request's context ends with the upgrade, so each command gets its own
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	reply := h.runCommand(ctx, client, cmd)
+	reply.RequestID = cmd.RequestID
+	h.reply(client, reply)
+}
+
+// runCommand routes cmd to the notification service.
+func (h *WebSocketHandler) runCommand(ctx context.Context, client *ws.Client, cmd Command) CommandReply {
+	if cmd.Command == commandSubscribe {
+		client.Subscribe(cmd.Channels)
+		return CommandReply{OK: true, Result: gin.H{"channels": cmd.Channels}}
+	}
+
+	if h.service == nil {
+		return CommandReply{Error: "commands unavailable"}
+	}
+
+	switch cmd.Command {
+	case commandMarkRead:
+		id, reply := h.ownedNotification(ctx, client.UserID, cmd.ID)
+		if id == uuid.Nil {
+			return reply
+		}
+		if err := h.service.MarkAsRead(ctx, client.UserID, id); err != nil {
+			if _, ok := err.(*domain.ErrNotFound); ok {
+				return CommandReply{Error: "notification not found or already read"}
+			}
+			log.Printf("[WebSocket] mark_read %s for user %s failed: %v", id, client.UserID, err)
+			return CommandReply{Error: "failed to mark notification as read"}
+		}
+		return CommandReply{OK: true}
+
+	case commandMarkAllRead:
+		if err := h.service.MarkAllAsRead(ctx, client.UserID); err != nil {
+			log.Printf("[WebSocket] mark_all_read for user %s failed: %v", client.UserID, err)
+			return CommandReply{Error: "failed to mark notifications as read"}
+		}
+		return CommandReply{OK: true}
+
+	case commandAck:
+		id, reply := h.ownedNotification(ctx, client.UserID, cmd.ID)
+		if id == uuid.Nil {
+			return reply
+		}
+		if err := h.service.AcknowledgeDelivery(ctx, id); err != nil {
+			log.Printf("[WebSocket] ack %s for user %s failed: %v", id, client.UserID, err)
+			return CommandReply{Error: "failed to acknowledge notification"}
+		}
+		return CommandReply{OK: true}
+
+	default:
+		return CommandReply{Error: "unknown command"}
+	}
+}
+
+// ownedNotification parses a notification ID from a command and verifies the
+// user owns it, like the REST handlers do. It returns uuid.Nil and the error
+// reply if not.
+func (h *WebSocketHandler) ownedNotification(ctx context.Context, userID uuid.UUID, rawID string) (uuid.UUID, CommandReply) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return uuid.Nil, CommandReply{Error: "invalid notification ID"}
+	}
+
+	notification, err := h.service.GetNotification(ctx, id)
+	if err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			return uuid.Nil, CommandReply{Error: "notification not found"}
+		}
+		return uuid.Nil, CommandReply{Error: "failed to fetch notification"}
+	}
+
+	if notification.UserID != userID {
+		return uuid.Nil, CommandReply{Error: "notification not found"}
+	}
+
+	return id, CommandReply{}
+}
+
+// reply queues a command reply for client.
+func (h *WebSocketHandler) reply(client *ws.Client, reply CommandReply) {
+	data, err := json.Marshal(domain.NewEvent(domain.EventCommandReply, reply))
+	if err != nil {
+		log.Printf("[WebSocket] failed to marshal command reply: %v", err)
+		return
+	}
+
+	h.hub.Reply(client, data)
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	ws "github.com/prepmyapp/notification/internal/infrastructure/websocket"
+	"github.com/prepmyapp/notification/internal/service"
+)
+
+// newCommandHandler returns a WebSocket handler whose commands run against repo.
+func newCommandHandler(hub *ws.Hub, tickets *fakeTicketRepo, repo *fakeNotificationRepo) *WebSocketHandler {
+	svc := service.NewNotificationService(repo, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	return NewWebSocketHandler(hub, service.NewTicketService(tickets, time.Minute), svc, nil, nil)
+}
+
+func TestRunCommand(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		cmd       func(own, foreign *domain.Notification) Command
+		wantOK    bool
+		wantError string
+		check     func(t *testing.T, repo *fakeNotificationRepo, own, foreign *domain.Notification)
+	}{
+		{
+			name: "mark_read",
+			cmd: func(own, _ *domain.Notification) Command {
+				return Command{Command: commandMarkRead, ID: own.ID.String()}
+			},
+			wantOK: true,
+			check: func(t *testing.T, repo *fakeNotificationRepo, own, _ *domain.Notification) {
+				if repo.get(own.ID).ReadAt == nil {
+					t.Error("notification not marked as read")
+				}
+			},
+		},
+		{
+			name: "mark_read on another user's notification",
+			cmd: func(_, foreign *domain.Notification) Command {
+				return Command{Command: commandMarkRead, ID: foreign.ID.String()}
+			},
+			wantError: "notification not found",
+			check: func(t *testing.T, repo *fakeNotificationRepo, _, foreign *domain.Notification) {
+				if repo.get(foreign.ID).ReadAt != nil {
+					t.Error("another user's notification was marked as read")
+				}
+			},
+		},
+		{
+			name: "mark_read twice",
+			cmd: func(own, _ *domain.Notification) Command {
+				own.ReadAt = &now
+				return Command{Command: commandMarkRead, ID: own.ID.String()}
+			},
+			wantError: "notification not found or already read",
+		},
+		{
+			name:      "mark_read with an invalid ID",
+			cmd:       func(_, _ *domain.Notification) Command { return Command{Command: commandMarkRead, ID: "42"} },
+			wantError: "invalid notification ID",
+		},
+		{
+			name: "mark_read on an unknown notification",
+			cmd: func(_, _ *domain.Notification) Command {
+				return Command{Command: commandMarkRead, ID: uuid.NewString()}
+			},
+			wantError: "notification not found",
+		},
+		{
+			name:   "mark_all_read",
+			cmd:    func(_, _ *domain.Notification) Command { return Command{Command: commandMarkAllRead} },
+			wantOK: true,
+			check: func(t *testing.T, repo *fakeNotificationRepo, own, foreign *domain.Notification) {
+				if repo.get(own.ID).ReadAt == nil {
+					t.Error("own notification not marked as read")
+				}
+				if repo.get(foreign.ID).ReadAt != nil {
+					t.Error("another user's notification was marked as read")
+				}
+			},
+		},
+		{
+			name:   "ack",
+			cmd:    func(own, _ *domain.Notification) Command { return Command{Command: commandAck, ID: own.ID.String()} },
+			wantOK: true,
+			check: func(t *testing.T, repo *fakeNotificationRepo, own, _ *domain.Notification) {
+				if status := repo.get(own.ID).Status; status != domain.NotificationStatusDelivered {
+					t.Errorf("status = %s, want %s", status, domain.NotificationStatusDelivered)
+				}
+			},
+		},
+		{
+			name: "ack on another user's notification",
+			cmd: func(_, foreign *domain.Notification) Command {
+				return Command{Command: commandAck, ID: foreign.ID.String()}
+			},
+			wantError: "notification not found",
+			check: func(t *testing.T, repo *fakeNotificationRepo, _, foreign *domain.Notification) {
+				if status := repo.get(foreign.ID).Status; status == domain.NotificationStatusDelivered {
+					t.Error("another user's notification was acknowledged")
+				}
+			},
+		},
+		{
+			name:      "unknown command",
+			cmd:       func(_, _ *domain.Notification) Command { return Command{Command: "delete"} },
+			wantError: "unknown command",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			own := inAppAt(userID, "", now)
+			foreign := inAppAt(uuid.New(), "", now)
+			cmd := tt.cmd(own, foreign)
+			repo := newFakeNotificationRepo(own, foreign)
+			h := newCommandHandler(ws.NewHub(nil, 0), &fakeTicketRepo{}, repo)
+
+			reply := h.runCommand(context.Background(), &ws.Client{UserID: userID}, cmd)
+			if reply.OK != tt.wantOK || reply.Error != tt.wantError {
+				t.Errorf("reply = %+v, want ok %v, error %q", reply, tt.wantOK, tt.wantError)
+			}
+			if tt.check != nil {
+				tt.check(t, repo, own, foreign)
+			}
+		})
+	}
+}
+
+func TestRunCommandSubscribe(t *testing.T) {
+	client := &ws.Client{UserID: uuid.New()}
+	h := NewWebSocketHandler(ws.NewHub(nil, 0), nil, nil, nil, nil) // Subscribing needs no service
+
+	reply := h.runCommand(context.Background(), client, Command{Command: commandSubscribe, Channels: []string{"alert"}})
+	if !reply.OK {
+		t.Fatalf("reply = %+v, want ok", reply)
+	}
+	if !client.WantsChannel("alert") || client.WantsChannel("marketing") {
+		t.Error("client not limited to the alert channel")
+	}
+
+	if reply := h.runCommand(context.Background(), client, Command{Command: commandMarkAllRead}); reply.Error != "commands unavailable" {
+		t.Errorf("mark_all_read without a service = %+v, want commands unavailable", reply)
+	}
+}
+
+// TestCommandReplies checks that commands sent over the socket are answered
+// with command.reply events echoing their request IDs, in order.
+func TestCommandReplies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	userID := uuid.New()
+	own := inAppAt(userID, "", time.Now())
+	foreign := inAppAt(uuid.New(), "", time.Now())
+
+	hub := newTestHub(t)
+	h := newCommandHandler(hub, &fakeTicketRepo{userID: userID}, newFakeNotificationRepo(own, foreign))
+	conn := connect(t, hub, h, userID, url.Values{})
+
+	sent := []struct {
+		message string
+		want    CommandReply
+	}{
+		{`{"request_id": "1", "command": "mark_read", "id": "` + own.ID.String() + `"}`, CommandReply{RequestID: "1", OK: true}},
+		{`{"request_id": "2", "command": "ack", "id": "` + foreign.ID.String() + `"}`, CommandReply{RequestID: "2", Error: "notification not found"}},
+		{`{"request_id": "3", "command": "subscribe", "channels": ["alert"]}`, CommandReply{RequestID: "3", OK: true}},
+		{`not json`, CommandReply{Error: "invalid command"}},
+	}
+	for _, s := range sent {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(s.message)); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+
+	for _, s := range sent {
+		event := readEvent(t, conn)
+		if got := eventType(t, event); got != domain.EventCommandReply {
+			t.Fatalf("type = %q, want %q", got, domain.EventCommandReply)
+		}
+		var reply CommandReply
+		if err := json.Unmarshal(event["payload"], &reply); err != nil {
+			t.Fatalf("payload: %v", err)
+		}
+		if reply.RequestID != s.want.RequestID || reply.OK != s.want.OK || reply.Error != s.want.Error {
+			t.Errorf("reply to %s = %+v, want %+v", s.message, reply, s.want)
+		}
+	}
+}
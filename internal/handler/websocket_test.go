@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	ws "github.com/prepmyapp/notification/internal/infrastructure/websocket"
+	"github.com/prepmyapp/notification/internal/service"
+)
+
+// fakeTicketRepo counts the tickets consumed. Every ticket belongs to userID,
+// or is unknown when userID is unset.
+type fakeTicketRepo struct {
+	domain.WebSocketTicketRepository
+
+	userID   uuid.UUID
+	consumed int
+}
+
+func (r *fakeTicketRepo) Consume(ctx context.Context, ticketHash string) (uuid.UUID, error) {
+	r.consumed++
+	if r.userID == uuid.Nil {
+		return uuid.Nil, domain.NewErrNotFound("websocket_ticket", ticketHash)
+	}
+	return r.userID, nil
+}
+
+// connect starts h behind a test server, connects as userID with the given
+// query and waits until the hub has registered the client.
+func connect(t *testing.T, hub *ws.Hub, h *WebSocketHandler, userID uuid.UUID, query url.Values) *websocket.Conn {
+	t.Helper()
+
+	router := gin.New()
+	h.RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	query.Set("ticket", "some-ticket")
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/notifications?" + query.Encode()
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v (response %v)", err, resp)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !hub.IsUserConnected(userID) {
+		if time.Now().After(deadline) {
+			t.Fatal("client never registered with the hub")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return conn
+}
+
+// newTestHub starts a single-instance hub, closed when the test ends.
+func newTestHub(t *testing.T) *ws.Hub {
+	t.Helper()
+	hub := ws.NewHub(nil, 0)
+	go hub.Run()
+	t.Cleanup(func() { _ = hub.Close() })
+	return hub
+}
+
+// readEvent reads the next frame from conn, which must hold exactly one event.
+func readEvent(t *testing.T, conn *websocket.Conn) map[string]json.RawMessage {
+	t.Helper()
+
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("SetReadDeadline: %v", err)
+	}
+	msgType, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if msgType != websocket.TextMessage {
+		t.Fatalf("frame type = %d, want text", msgType)
+	}
+
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("frame %q is not a single event: %v", data, err)
+	}
+	return event
+}
+
+// eventType returns the type of an event read with readEvent.
+func eventType(t *testing.T, event map[string]json.RawMessage) domain.EventType {
+	t.Helper()
+	var eventType domain.EventType
+	if err := json.Unmarshal(event["type"], &eventType); err != nil {
+		t.Fatalf("event type %s: %v", event["type"], err)
+	}
+	return eventType
+}
+
+// TestHandleConnectionChecksSinceBeforeRedeeming checks that a malformed
+// replay cursor is rejected without using up the ticket.
+func TestHandleConnectionChecksSinceBeforeRedeeming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		since        string
+		wantStatus   int
+		wantConsumed int
+	}{
+		{"malformed cursor", "yesterday", http.StatusBadRequest, 0},
+		{"timestamp cursor", time.Now().UTC().Format(time.RFC3339), http.StatusUnauthorized, 1},
+		{"notification ID cursor", uuid.NewString(), http.StatusUnauthorized, 1},
+		{"no cursor", "", http.StatusUnauthorized, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tickets := &fakeTicketRepo{}
+			// A notifications repository enables replay; it is never called here
+			var notifications domain.NotificationRepository = struct{ domain.NotificationRepository }{}
+			h := NewWebSocketHandler(ws.NewHub(nil, 0), service.NewTicketService(tickets, time.Minute), nil, notifications, nil)
+
+			router := gin.New()
+			router.GET("/ws", h.HandleConnection)
+
+			query := url.Values{"ticket": {"some-ticket"}}
+			if tt.since != "" {
+				query.Set("since", tt.since)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws?"+query.Encode(), nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tickets.consumed != tt.wantConsumed {
+				t.Errorf("ticket redeemed %d times, want %d", tickets.consumed, tt.wantConsumed)
+			}
+		})
+	}
+}
+
+// TestWritePumpSendsOneEventPerFrame checks that events emitted back to back
+// reach the client as separate frames, in order, each a complete envelope.
+func TestWritePumpSendsOneEventPerFrame(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	userID := uuid.New()
+	hub := newTestHub(t)
+	h := NewWebSocketHandler(hub, service.NewTicketService(&fakeTicketRepo{userID: userID}, time.Minute), nil, nil, nil)
+	conn := connect(t, hub, h, userID, url.Values{})
+
+	id := uuid.New()
+	sent := []*domain.Event{
+		domain.NewEvent(domain.EventNotificationRead, domain.NotificationReadPayload{NotificationID: &id, ReadAt: time.Now()}),
+		domain.NewEvent(domain.EventUnreadCountChanged, domain.UnreadCountPayload{Count: 2}),
+		domain.NewEvent(domain.EventNotificationDeleted, domain.NotificationDeletedPayload{NotificationID: id}),
+	}
+	for _, event := range sent {
+		if err := hub.Emit(context.Background(), userID, event); err != nil {
+			t.Fatalf("Emit: %v", err)
+		}
+	}
+
+	for _, want := range sent {
+		event := readEvent(t, conn)
+		if got := eventType(t, event); got != want.Type {
+			t.Errorf("type = %q, want %q", got, want.Type)
+		}
+		var eventID uuid.UUID
+		if err := json.Unmarshal(event["id"], &eventID); err != nil || eventID != want.ID {
+			t.Errorf("id = %s, want %s", event["id"], want.ID)
+		}
+		if string(event["v"]) != "1" {
+			t.Errorf("v = %s, want 1", event["v"])
+		}
+	}
+}
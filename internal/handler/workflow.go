@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/service"
+)
+
+// WorkflowHandler lets internal callers start and cancel notification workflows.
+type WorkflowHandler struct {
+	engine *service.WorkflowEngine
+}
+
+// NewWorkflowHandler creates a new workflow handler.
+func NewWorkflowHandler(engine *service.WorkflowEngine) *WorkflowHandler {
+	return &WorkflowHandler{engine: engine}
+}
+
+// StartWorkflowRequest represents a request to start a workflow for a user.
+type StartWorkflowRequest struct {
+	UserID   string                 `json:"user_id" binding:"required"`
+	Email    string                 `json:"email"`
+	Title    string                 `json:"title"` // Used by steps without a template
+	Body     string                 `json:"body"`
+	Data     map[string]interface{} `json:"data"`
+	Locale   string                 `json:"locale"`
+	Critical bool                   `json:"critical"`
+
+	// Delays overrides the definition's step delays by step name, e.g. {"email": "10m"}
+	Delays map[string]string `json:"delays"`
+}
+
+// Start starts the named workflow.
+func (h *WorkflowHandler) Start(c *gin.Context) {
+	name := c.Param("name")
+
+	var req StartWorkflowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id format"})
+		return
+	}
+
+	locale, err := parseLocale(req.Locale)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	run, err := h.engine.StartRun(c.Request.Context(), name, userID, domain.WorkflowInput{
+		Email:    req.Email,
+		Title:    req.Title,
+		Body:     req.Body,
+		Data:     req.Data,
+		Locale:   locale,
+		Critical: req.Critical,
+		Delays:   req.Delays,
+	})
+	if err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "workflow not found"})
+			return
+		}
+		if _, ok := err.(*domain.ErrValidation); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[Workflow] ERROR: failed to start %s for user %s: %v", name, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start workflow"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// GetRun returns a workflow run with its progress.
+func (h *WorkflowHandler) GetRun(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workflow run ID"})
+		return
+	}
+
+	run, err := h.engine.GetRun(c.Request.Context(), id)
+	if err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "workflow run not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get workflow run"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// CancelRun cancels the remaining steps of a workflow run.
+func (h *WorkflowHandler) CancelRun(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workflow run ID"})
+		return
+	}
+
+	if err := h.engine.CancelRun(c.Request.Context(), id); err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "workflow run not found or already finished"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel workflow run"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "workflow run cancelled"})
+}
+
+// RegisterRoutes registers workflow routes.
+func (h *WorkflowHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.POST("/workflows/:name", h.Start)
+	rg.GET("/workflow-runs/:id", h.GetRun)
+	rg.POST("/workflow-runs/:id/cancel", h.CancelRun)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/handler/middleware"
+	"github.com/prepmyapp/notification/internal/service"
+)
+
+// TicketHandler issues the tickets clients open WebSocket connections with.
+type TicketHandler struct {
+	tickets *service.TicketService
+}
+
+// NewTicketHandler creates a new WebSocket ticket handler.
+func NewTicketHandler(tickets *service.TicketService) *TicketHandler {
+	return &TicketHandler{tickets: tickets}
+}
+
+// Issue returns a single-use ticket for the authenticated user, to be passed
+// to /ws/notifications as ?ticket=.
+func (h *TicketHandler) Issue(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == uuid.Nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ticket, err := h.tickets.Issue(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[Ticket] ERROR: failed to issue websocket ticket for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue ticket"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ticket)
+}
+
+// RegisterRoutes registers the ticket route.
+func (h *TicketHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.POST("/ws-ticket", h.Issue)
+}
@@ -2,8 +2,10 @@ package firebase
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/messaging"
@@ -108,7 +110,7 @@ func (c *Client) Send(ctx context.Context, token, title, body string, data map[s
 				log.Printf("failed to deactivate invalid token: %v", deactivateErr)
 			}
 		}
-		return fmt.Errorf("failed to send push notification: %w", err)
+		return classifyError(err, "failed to send push notification")
 	}
 
 	return nil
@@ -122,14 +124,16 @@ func (c *Client) SendToUser(ctx context.Context, userID uuid.UUID, title, body s
 	tokens, err := c.deviceTokenRepo.GetByUserID(ctx, userID)
 	if err != nil {
 		log.Printf("[Firebase] ERROR getting device tokens for user %s: %v", userID, err)
-		return fmt.Errorf("failed to get device tokens: %w", err)
+		return domain.NewRetryableError(fmt.Errorf("failed to get device tokens: %w", err))
 	}
 
 	log.Printf("[Firebase] Found %d device tokens for user %s", len(tokens), userID)
 
 	if len(tokens) == 0 {
-		log.Printf("[Firebase] No device tokens registered for user %s, skipping push", userID)
-		return nil // No devices registered, not an error
+		// Nothing was delivered, so don't report success - a notification
+		// with a fallback chain moves on to its next channel instead
+		log.Printf("[Firebase] No device tokens registered for user %s, push unavailable", userID)
+		return domain.NewPermanentError(fmt.Errorf("%w: no active device tokens for user %s", domain.ErrChannelUnavailable, userID))
 	}
 
 	// Convert data to string map
@@ -171,12 +175,13 @@ func (c *Client) SendToUser(ctx context.Context, userID uuid.UUID, title, body s
 	response, err := c.messaging.SendEachForMulticast(ctx, message)
 	if err != nil {
 		log.Printf("[Firebase] ERROR sending multicast: %v", err)
-		return fmt.Errorf("failed to send multicast: %w", err)
+		return classifyError(err, "failed to send multicast")
 	}
 
 	log.Printf("[Firebase] Multicast result: success=%d, failure=%d", response.SuccessCount, response.FailureCount)
 
 	// Handle failed tokens
+	var lastErr error
 	if response.FailureCount > 0 {
 		for i, resp := range response.Responses {
 			if !resp.Success {
@@ -186,13 +191,50 @@ func (c *Client) SendToUser(ctx context.Context, userID uuid.UUID, title, body s
 						log.Printf("failed to deactivate invalid token: %v", err)
 					}
 				}
+				// Prefer a retryable error so a transient outage on one device isn't dead-lettered
+				if lastErr == nil || isRetryable(resp.Error) {
+					lastErr = resp.Error
+				}
 			}
 		}
 	}
 
+	// Partial success counts as sent - retrying would duplicate the push on
+	// the devices that did receive it.
+	if response.SuccessCount == 0 && lastErr != nil {
+		return classifyError(lastErr, fmt.Sprintf("push failed on all %d devices", response.FailureCount))
+	}
+
 	return nil
 }
 
+// isRetryable reports whether an FCM error is transient: FCM is unavailable
+// or throttling, or it couldn't be reached at all.
+func isRetryable(err error) bool {
+	var netErr net.Error
+	return messaging.IsUnavailable(err) ||
+		messaging.IsInternal(err) ||
+		messaging.IsQuotaExceeded(err) ||
+		messaging.IsMessageRateExceeded(err) ||
+		messaging.IsServerUnavailable(err) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
+
+// classifyError wraps an FCM error with message and marks it as retryable or
+// permanent for the delivery workers. The messaging.Is* helpers only match
+// unwrapped errors, so classification has to happen before wrapping.
+// Anything not known to be transient, such as an unregistered token or a
+// credential problem, is permanent.
+func classifyError(err error, message string) error {
+	wrapped := fmt.Errorf("%s: %w", message, err)
+
+	if isRetryable(err) {
+		return domain.NewRetryableError(wrapped)
+	}
+	return domain.NewPermanentError(wrapped)
+}
+
 // SendToTopic sends a push notification to all subscribers of a topic.
 func (c *Client) SendToTopic(ctx context.Context, topic, title, body string, data map[string]interface{}) error {
 	stringData := make(map[string]string)
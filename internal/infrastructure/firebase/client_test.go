@@ -0,0 +1,128 @@
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	firebase "firebase.google.com/go/v4"
+	"google.golang.org/api/option"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// fakeDeviceTokenRepo records the tokens deactivated.
+type fakeDeviceTokenRepo struct {
+	domain.DeviceTokenRepository
+
+	deactivated []string
+}
+
+func (r *fakeDeviceTokenRepo) Deactivate(ctx context.Context, token string) error {
+	r.deactivated = append(r.deactivated, token)
+	return nil
+}
+
+// newTestClient returns a client whose FCM requests are answered with status
+// and, unless errorCode is empty, an FCM error with that code.
+func newTestClient(t *testing.T, status int, errorCode string) (*Client, *fakeDeviceTokenRepo) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// Longer than the SDK waits, so it returns the error instead of retrying
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			fmt.Fprint(w, `{"name": "projects/test/messages/1"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"error": {"code": %d, "message": "test error", "details": [
+			{"@type": "type.googleapis.com/google.firebase.fcm.v1.FcmError", "errorCode": %q}]}}`, status, errorCode)
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	app, err := firebase.NewApp(ctx, &firebase.Config{ProjectID: "test"}, option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("NewApp: %v", err)
+	}
+	messagingClient, err := app.Messaging(ctx)
+	if err != nil {
+		t.Fatalf("Messaging: %v", err)
+	}
+
+	tokens := &fakeDeviceTokenRepo{}
+	return &Client{messaging: messagingClient, deviceTokenRepo: tokens}, tokens
+}
+
+// TestSendClassifiesErrors checks how FCM failures are classified for the
+// delivery workers, and that tokens FCM rejects are deactivated.
+func TestSendClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          int
+		errorCode       string
+		wantErr         bool
+		wantRetryable   bool
+		wantDeactivated bool
+	}{
+		{name: "sent", status: http.StatusOK},
+		{name: "unavailable", status: http.StatusServiceUnavailable, errorCode: "UNAVAILABLE", wantErr: true, wantRetryable: true},
+		{name: "internal", status: http.StatusInternalServerError, errorCode: "INTERNAL", wantErr: true, wantRetryable: true},
+		{name: "quota exceeded", status: http.StatusTooManyRequests, errorCode: "QUOTA_EXCEEDED", wantErr: true, wantRetryable: true},
+		{name: "unregistered", status: http.StatusNotFound, errorCode: "UNREGISTERED", wantErr: true, wantDeactivated: true},
+		{name: "invalid argument", status: http.StatusBadRequest, errorCode: "INVALID_ARGUMENT", wantErr: true, wantDeactivated: true},
+		{name: "sender ID mismatch", status: http.StatusForbidden, errorCode: "SENDER_ID_MISMATCH", wantErr: true},
+		{name: "unrecognised", status: http.StatusTeapot, errorCode: "SOMETHING_NEW", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, tokens := newTestClient(t, tt.status, tt.errorCode)
+
+			err := client.Send(context.Background(), "device-token", "Hello", "Body", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+
+			var deliveryErr *domain.DeliveryError
+			if !errors.As(err, &deliveryErr) {
+				t.Errorf("error %v is not classified", err)
+			}
+			if got := domain.IsRetryable(err); got != tt.wantRetryable {
+				t.Errorf("retryable = %v, want %v (%v)", got, tt.wantRetryable, err)
+			}
+			if deactivated := len(tokens.deactivated) > 0; deactivated != tt.wantDeactivated {
+				t.Errorf("token deactivated = %v, want %v", deactivated, tt.wantDeactivated)
+			}
+		})
+	}
+}
+
+func TestClassifyErrorTransportFailures(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantRetryable bool
+	}{
+		{"network error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"deadline exceeded", fmt.Errorf("request: %w", context.DeadlineExceeded), true},
+		{"unknown", errors.New("unexpected response"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := domain.IsRetryable(classifyError(tt.err, "failed to send")); got != tt.wantRetryable {
+				t.Errorf("retryable = %v, want %v", got, tt.wantRetryable)
+			}
+		})
+	}
+}
@@ -3,9 +3,13 @@ package sendgrid
 import (
 	"context"
 	"fmt"
+	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
+
+	"github.com/prepmyapp/notification/internal/domain"
 )
 
 // Client wraps the SendGrid API client.
@@ -31,24 +35,60 @@ func NewClient(cfg Config) *Client {
 	}
 }
 
+// ProviderName identifies SendGrid in email receipts.
+const ProviderName = "sendgrid"
+
 // Send sends a simple email with subject and body.
 func (c *Client) Send(ctx context.Context, to, subject, body string) error {
+	_, err := c.SendEmail(ctx, &domain.EmailMessage{To: to, Subject: subject, Text: body})
+	return err
+}
+
+// SendEmail sends msg and returns SendGrid's ID for it.
+// Plain text emails are sent with the text as HTML content too.
+func (c *Client) SendEmail(ctx context.Context, msg *domain.EmailMessage) (*domain.EmailReceipt, error) {
 	from := mail.NewEmail(c.fromName, c.fromEmail)
-	toEmail := mail.NewEmail("", to)
+	toEmail := mail.NewEmail("", msg.To)
 
-	// Create message with both plain text and HTML
-	message := mail.NewSingleEmail(from, subject, toEmail, body, body)
+	htmlContent := msg.HTML
+	if htmlContent == "" {
+		htmlContent = msg.Text
+	}
+	message := mail.NewSingleEmail(from, msg.Subject, toEmail, msg.Text, htmlContent)
+
+	if msg.HTML != "" {
+		// Disable click tracking to preserve original URLs
+		// This prevents SSL issues with the tracking subdomain
+		trackingSettings := mail.NewTrackingSettings()
+		clickTracking := mail.NewClickTrackingSetting()
+		clickTracking.SetEnable(false)
+		clickTracking.SetEnableText(false)
+		trackingSettings.SetClickTracking(clickTracking)
+		message.SetTrackingSettings(trackingSettings)
+	}
+
+	if msg.NotificationID != uuid.Nil {
+		message.SetCustomArg(NotificationIDArg, msg.NotificationID.String())
+	}
+	for key, value := range msg.Headers() {
+		message.SetHeader(key, value)
+	}
 
 	response, err := c.client.Send(message)
 	if err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+		// Transport errors (timeouts, connection resets) are worth retrying
+		return nil, domain.NewRetryableError(fmt.Errorf("failed to send email: %w", err))
 	}
 
-	if response.StatusCode >= 400 {
-		return fmt.Errorf("sendgrid error: status %d, body: %s", response.StatusCode, response.Body)
+	if err := checkResponse(response.StatusCode, response.Body); err != nil {
+		return nil, err
 	}
 
-	return nil
+	receipt := &domain.EmailReceipt{Provider: ProviderName}
+	if ids := response.Headers["X-Message-Id"]; len(ids) > 0 {
+		receipt.MessageID = ids[0]
+	}
+	return receipt, nil
 }
 
 // SendTemplate sends an email using a SendGrid dynamic template.
@@ -72,42 +112,32 @@ func (c *Client) SendTemplate(ctx context.Context, to, templateID string, data m
 
 	response, err := c.client.Send(message)
 	if err != nil {
-		return fmt.Errorf("failed to send template email: %w", err)
+		// Transport errors (timeouts, connection resets) are worth retrying
+		return domain.NewRetryableError(fmt.Errorf("failed to send template email: %w", err))
 	}
 
-	if response.StatusCode >= 400 {
-		return fmt.Errorf("sendgrid error: status %d, body: %s", response.StatusCode, response.Body)
-	}
-
-	return nil
+	return checkResponse(response.StatusCode, response.Body)
 }
 
 // SendHTML sends an email with HTML content.
 func (c *Client) SendHTML(ctx context.Context, to, subject, plainText, htmlContent string) error {
-	from := mail.NewEmail(c.fromName, c.fromEmail)
-	toEmail := mail.NewEmail("", to)
-
-	message := mail.NewSingleEmail(from, subject, toEmail, plainText, htmlContent)
-
-	// Disable click tracking to preserve original URLs
-	// This prevents SSL issues with the tracking subdomain
-	trackingSettings := mail.NewTrackingSettings()
-	clickTracking := mail.NewClickTrackingSetting()
-	clickTracking.SetEnable(false)
-	clickTracking.SetEnableText(false)
-	trackingSettings.SetClickTracking(clickTracking)
-	message.SetTrackingSettings(trackingSettings)
+	_, err := c.SendEmail(ctx, &domain.EmailMessage{To: to, Subject: subject, Text: plainText, HTML: htmlContent})
+	return err
+}
 
-	response, err := c.client.Send(message)
-	if err != nil {
-		return fmt.Errorf("failed to send HTML email: %w", err)
+// checkResponse converts a SendGrid error response into a classified error.
+// Rate limiting and 5xx are transient; any other 4xx means the request
+// itself is bad and will be rejected again.
+func checkResponse(statusCode int, body string) error {
+	if statusCode < 400 {
+		return nil
 	}
 
-	if response.StatusCode >= 400 {
-		return fmt.Errorf("sendgrid error: status %d, body: %s", response.StatusCode, response.Body)
+	err := fmt.Errorf("sendgrid error: status %d, body: %s", statusCode, body)
+	if statusCode == http.StatusTooManyRequests || statusCode >= 500 {
+		return domain.NewRetryableError(err)
 	}
-
-	return nil
+	return domain.NewPermanentError(err)
 }
 
 // SendOTP sends an OTP verification email.
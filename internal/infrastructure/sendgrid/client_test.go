@@ -0,0 +1,49 @@
+package sendgrid
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		status        int
+		wantErr       bool
+		wantRetryable bool
+	}{
+		{http.StatusOK, false, false},
+		{http.StatusAccepted, false, false},
+		{http.StatusBadRequest, true, false},
+		{http.StatusUnauthorized, true, false},
+		{http.StatusForbidden, true, false},
+		{http.StatusRequestEntityTooLarge, true, false},
+		{http.StatusTooManyRequests, true, true},
+		{http.StatusInternalServerError, true, true},
+		{http.StatusBadGateway, true, true},
+		{http.StatusServiceUnavailable, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			err := checkResponse(tt.status, `{"errors": [{"message": "test"}]}`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkResponse error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil {
+				return
+			}
+
+			var deliveryErr *domain.DeliveryError
+			if !errors.As(err, &deliveryErr) {
+				t.Fatalf("error %v is not classified", err)
+			}
+			if deliveryErr.Retryable != tt.wantRetryable {
+				t.Errorf("retryable = %v, want %v", deliveryErr.Retryable, tt.wantRetryable)
+			}
+		})
+	}
+}
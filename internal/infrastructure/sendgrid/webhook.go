@@ -0,0 +1,149 @@
+package sendgrid
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sendgrid/sendgrid-go/helpers/eventwebhook"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// Headers SendGrid signs event webhook requests with.
+const (
+	SignatureHeader = eventwebhook.VerificationHTTPHeader
+	TimestampHeader = eventwebhook.TimestampHTTPHeader
+)
+
+// NotificationIDArg is the custom arg carrying our notification ID on every
+// email. SendGrid echoes it in each event about that email.
+const NotificationIDArg = "notification_id"
+
+// eventTypes maps the SendGrid events we track to notification events.
+// Others (processed, deferred, group unsubscribes, ...) are ignored.
+var eventTypes = map[string]domain.NotificationEventType{
+	"delivered":   domain.NotificationEventDelivered,
+	"bounce":      domain.NotificationEventBounced,
+	"dropped":     domain.NotificationEventDropped,
+	"open":        domain.NotificationEventOpened,
+	"click":       domain.NotificationEventClicked,
+	"spamreport":  domain.NotificationEventSpamReport,
+	"unsubscribe": domain.NotificationEventUnsubscribe,
+}
+
+// bounceTypeBlocked marks a bounce event as a soft bounce: the recipient's
+// server refused the email for now (e.g. rate limiting or reputation) but
+// the address itself may be fine.
+const bounceTypeBlocked = "blocked"
+
+// WebhookVerifier checks the ECDSA signature SendGrid puts on event webhook
+// requests, proving they come from SendGrid and weren't altered.
+type WebhookVerifier struct {
+	publicKey *ecdsa.PublicKey
+	tolerance time.Duration
+}
+
+// NewWebhookVerifier creates a verifier from the base64 public key shown in
+// SendGrid's Signed Event Webhook settings. Requests whose signed timestamp
+// is more than tolerance away from now are rejected, so a captured request
+// can't be replayed later.
+func NewWebhookVerifier(base64PublicKey string, tolerance time.Duration) (*WebhookVerifier, error) {
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(base64PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook public key: %w", err)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook public key: %w", err)
+	}
+
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid webhook public key: not an ECDSA key")
+	}
+
+	return &WebhookVerifier{publicKey: publicKey, tolerance: tolerance}, nil
+}
+
+// Verify reports whether signature is SendGrid's signature of the request
+// body together with its timestamp header, and the timestamp is recent.
+func (v *WebhookVerifier) Verify(body []byte, signature, timestamp string) bool {
+	if signature == "" || timestamp == "" {
+		return false
+	}
+
+	// The timestamp is signed too, so an old request can't be given a new one
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(unix, 0))
+	if age > v.tolerance || age < -v.tolerance {
+		return false
+	}
+
+	ok, err := eventwebhook.VerifySignature(v.publicKey, body, signature, timestamp)
+	return err == nil && ok
+}
+
+// webhookEvent is the part of a SendGrid event we read. Custom args appear
+// as top-level fields.
+type webhookEvent struct {
+	Event          string `json:"event"`
+	Email          string `json:"email"`
+	Timestamp      int64  `json:"timestamp"`
+	EventID        string `json:"sg_event_id"`
+	Reason         string `json:"reason"`
+	Type           string `json:"type"` // "bounce" or "blocked" on bounce events
+	NotificationID string `json:"notification_id"`
+}
+
+// ParseEvents turns an event webhook batch into notification events. Events
+// of untracked types, and events about emails that carry no notification ID
+// (i.e. weren't sent by this service), are skipped.
+func ParseEvents(body []byte) ([]*domain.NotificationEvent, error) {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, fmt.Errorf("invalid event batch: %w", err)
+	}
+
+	events := make([]*domain.NotificationEvent, 0, len(batch))
+	for _, raw := range batch {
+		var e webhookEvent
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("invalid event: %w", err)
+		}
+
+		eventType, ok := eventTypes[e.Event]
+		if !ok {
+			continue
+		}
+		if eventType == domain.NotificationEventBounced && e.Type == bounceTypeBlocked {
+			eventType = domain.NotificationEventBlocked
+		}
+		notificationID, err := uuid.Parse(e.NotificationID)
+		if err != nil {
+			continue
+		}
+
+		event := domain.NewNotificationEvent(notificationID, ProviderName, eventType, time.Unix(e.Timestamp, 0))
+		event.ProviderEventID = e.EventID
+		event.Recipient = e.Email
+		event.Reason = e.Reason
+		if err := json.Unmarshal(raw, &event.Data); err != nil {
+			return nil, fmt.Errorf("invalid event: %w", err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
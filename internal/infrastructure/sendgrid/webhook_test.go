@@ -0,0 +1,151 @@
+package sendgrid
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// testSigner signs webhook requests the way SendGrid does.
+type testSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return &testSigner{key: key}
+}
+
+// publicKey returns the base64 key SendGrid shows in its settings.
+func (s *testSigner) publicKey(t *testing.T) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&s.key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+// sign returns the signature header for body sent at timestamp.
+func (s *testSigner) sign(t *testing.T, body []byte, timestamp string) string {
+	t.Helper()
+	hash := sha256.Sum256(append([]byte(timestamp), body...))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, hash[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	der, err := asn1.Marshal(struct{ R, S *big.Int }{r, sig})
+	if err != nil {
+		t.Fatalf("failed to marshal signature: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func TestWebhookVerifierVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	verifier, err := NewWebhookVerifier(signer.publicKey(t), 5*time.Minute)
+	if err != nil {
+		t.Fatalf("NewWebhookVerifier: %v", err)
+	}
+
+	body := []byte(`[{"event":"delivered","email":"user@example.com"}]`)
+	now := time.Now()
+	fresh := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+	future := strconv.FormatInt(now.Add(10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		timestamp string
+		want      bool
+	}{
+		{"valid signature", body, signer.sign(t, body, fresh), fresh, true},
+		{"tampered body", []byte(`[{"event":"bounce","email":"user@example.com"}]`), signer.sign(t, body, fresh), fresh, false},
+		{"timestamp swapped after signing", body, signer.sign(t, body, stale), fresh, false},
+		{"stale timestamp", body, signer.sign(t, body, stale), stale, false},
+		{"timestamp in the future", body, signer.sign(t, body, future), future, false},
+		{"malformed timestamp", body, signer.sign(t, body, "yesterday"), "yesterday", false},
+		{"signed by another key", body, newTestSigner(t).sign(t, body, fresh), fresh, false},
+		{"malformed signature", body, "not-base64!", fresh, false},
+		{"missing signature", body, "", fresh, false},
+		{"missing timestamp", body, signer.sign(t, body, ""), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifier.Verify(tt.body, tt.signature, tt.timestamp); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWebhookVerifierRejectsInvalidKey(t *testing.T) {
+	for _, key := range []string{"", "not-base64!", base64.StdEncoding.EncodeToString([]byte("not a key"))} {
+		if _, err := NewWebhookVerifier(key, time.Minute); err == nil {
+			t.Errorf("NewWebhookVerifier(%q) succeeded, want an error", key)
+		}
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	notificationID := uuid.New()
+	body := []byte(`[
+		{"event":"delivered","email":"a@example.com","timestamp":1700000000,"sg_event_id":"e1","notification_id":"` + notificationID.String() + `"},
+		{"event":"bounce","type":"blocked","email":"a@example.com","timestamp":1700000001,"sg_event_id":"e2","reason":"rate limited","notification_id":"` + notificationID.String() + `"},
+		{"event":"processed","email":"a@example.com","timestamp":1700000002,"sg_event_id":"e3","notification_id":"` + notificationID.String() + `"},
+		{"event":"open","email":"b@example.com","timestamp":1700000003,"sg_event_id":"e4"}
+	]`)
+
+	events, err := ParseEvents(body)
+	if err != nil {
+		t.Fatalf("ParseEvents: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2 (untracked types and foreign emails skipped)", len(events))
+	}
+
+	if e := events[0]; e.Type != domain.NotificationEventDelivered || e.NotificationID != notificationID ||
+		e.ProviderEventID != "e1" || e.Recipient != "a@example.com" || !e.OccurredAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("first event = %+v, want the delivered event", e)
+	}
+	if e := events[1]; e.Type != domain.NotificationEventBlocked || e.Reason != "rate limited" {
+		t.Errorf("second event = %+v, want a blocked event", e)
+	}
+}
+
+func TestParseEventsRejectsMalformedInput(t *testing.T) {
+	bodies := map[string]string{
+		"not JSON":             `event=delivered`,
+		"object, not an array": `{"event":"delivered"}`,
+		"truncated":            `[{"event":"delivered"`,
+		"event not an object":  `["delivered"]`,
+		"wrong field type":     `[{"event":"delivered","timestamp":"yesterday"}]`,
+	}
+
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseEvents([]byte(body)); err == nil {
+				t.Error("ParseEvents succeeded, want an error")
+			}
+		})
+	}
+}
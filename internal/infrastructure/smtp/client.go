@@ -0,0 +1,324 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/templates"
+)
+
+// Connection security modes.
+const (
+	SecurityStartTLS = "starttls" // Plain connection upgraded with STARTTLS (usually port 587)
+	SecurityTLS      = "tls"      // Implicit TLS from the first byte (usually port 465)
+	SecurityNone     = "none"     // No encryption, for local sinks like MailHog
+)
+
+// ProviderName identifies SMTP in email receipts.
+const ProviderName = "smtp"
+
+// Defaults for unset Config values.
+const (
+	defaultTimeout     = 10 * time.Second
+	defaultIdleTimeout = 30 * time.Second
+)
+
+// TemplateRenderer renders named email templates. SendTemplate uses it, as
+// SMTP servers have no templates of their own.
+type TemplateRenderer interface {
+	Render(name, locale string, data map[string]interface{}) (*templates.Rendered, error)
+}
+
+// Config holds SMTP configuration.
+type Config struct {
+	Host     string
+	Port     int
+	Username string // Empty skips AUTH
+	Password string
+	Security string // SecurityStartTLS, SecurityTLS or SecurityNone
+
+	FromEmail string
+	FromName  string
+
+	Timeout      time.Duration // Per connection attempt and per message
+	MaxIdleConns int           // Connections kept open for reuse (0 closes each one after use)
+	IdleTimeout  time.Duration // Idle connections older than this are not reused
+}
+
+// Client sends email through an SMTP server. Connections are kept open
+// and reused between messages, as setting one up (TLS handshake, AUTH)
+// costs several round trips.
+type Client struct {
+	cfg       Config
+	from      *mail.Address
+	templates TemplateRenderer
+	idle      chan *conn
+}
+
+// conn is an open, authenticated SMTP session.
+type conn struct {
+	client   *smtp.Client
+	netConn  net.Conn // For deadlines; client wraps it
+	lastUsed time.Time
+}
+
+// NewClient creates a new SMTP client. No connection is made until the
+// first message is sent. renderer may be nil if SendTemplate isn't used.
+func NewClient(cfg Config, renderer TemplateRenderer) (*Client, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("smtp host is required")
+	}
+	from, err := mail.ParseAddress(cfg.FromEmail)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", cfg.FromEmail, err)
+	}
+	from.Name = cfg.FromName
+
+	switch cfg.Security {
+	case SecurityStartTLS, SecurityTLS, SecurityNone:
+	case "":
+		cfg.Security = SecurityStartTLS
+	default:
+		return nil, fmt.Errorf("invalid smtp security %q", cfg.Security)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.Security == SecurityTLS {
+			cfg.Port = 465
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+	if cfg.MaxIdleConns < 0 {
+		cfg.MaxIdleConns = 0
+	}
+
+	return &Client{
+		cfg:       cfg,
+		from:      from,
+		templates: renderer,
+		idle:      make(chan *conn, cfg.MaxIdleConns),
+	}, nil
+}
+
+// Send sends a plain text email with subject and body.
+func (c *Client) Send(ctx context.Context, to, subject, body string) error {
+	_, err := c.SendEmail(ctx, &domain.EmailMessage{To: to, Subject: subject, Text: body})
+	return err
+}
+
+// SendHTML sends an email with both plain text and HTML content.
+func (c *Client) SendHTML(ctx context.Context, to, subject, plainText, htmlContent string) error {
+	_, err := c.SendEmail(ctx, &domain.EmailMessage{To: to, Subject: subject, Text: plainText, HTML: htmlContent})
+	return err
+}
+
+// SendTemplate renders a template from the local registry and sends it.
+// templateID is the template's name; data["locale"], if set, picks the
+// translation.
+func (c *Client) SendTemplate(ctx context.Context, to, templateID string, data map[string]interface{}) error {
+	if c.templates == nil {
+		return domain.NewPermanentError(fmt.Errorf("no template renderer configured for template %s", templateID))
+	}
+
+	locale, _ := data["locale"].(string)
+	rendered, err := c.templates.Render(templateID, locale, data)
+	if err != nil {
+		// Rendering fails the same way every time
+		return domain.NewPermanentError(fmt.Errorf("failed to render template %s: %w", templateID, err))
+	}
+
+	_, err = c.SendEmail(ctx, &domain.EmailMessage{To: to, Subject: rendered.Subject, Text: rendered.Text, HTML: rendered.HTML})
+	return err
+}
+
+// SendEmail builds msg and delivers it over a pooled connection. The receipt
+// carries the Message-ID header the email was sent with.
+func (c *Client) SendEmail(ctx context.Context, msg *domain.EmailMessage) (*domain.EmailReceipt, error) {
+	rcpt, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, domain.NewPermanentError(fmt.Errorf("invalid recipient %q: %w", msg.To, err))
+	}
+
+	data, messageID, err := buildMessage(c.from, rcpt, msg.Subject, msg.Text, msg.HTML, msg.Headers())
+	if err != nil {
+		return nil, domain.NewPermanentError(fmt.Errorf("failed to build email: %w", err))
+	}
+
+	cn, err := c.acquire(ctx)
+	if err != nil {
+		return nil, classify(fmt.Errorf("failed to connect to smtp server: %w", err))
+	}
+
+	if err := cn.deliver(c.deadline(ctx), c.from.Address, rcpt.Address, data); err != nil {
+		// The session may be mid-transaction; start the next message on a fresh one
+		cn.client.Close()
+		return nil, classify(fmt.Errorf("failed to send email: %w", err))
+	}
+
+	c.release(cn)
+	return &domain.EmailReceipt{Provider: ProviderName, MessageID: messageID}, nil
+}
+
+// Close closes all idle connections.
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.quit()
+		default:
+			return nil
+		}
+	}
+}
+
+// acquire returns an idle connection that is still alive, or dials a new one.
+func (c *Client) acquire(ctx context.Context) (*conn, error) {
+	for {
+		select {
+		case cn := <-c.idle:
+			if time.Since(cn.lastUsed) > c.cfg.IdleTimeout {
+				cn.quit()
+				continue
+			}
+			// The server may have dropped it meanwhile; RSET finds out cheaply
+			_ = cn.netConn.SetDeadline(c.deadline(ctx))
+			if err := cn.client.Reset(); err != nil {
+				cn.client.Close()
+				continue
+			}
+			return cn, nil
+		default:
+			return c.dial(ctx)
+		}
+	}
+}
+
+// release returns a connection to the pool, or closes it if the pool is full.
+func (c *Client) release(cn *conn) {
+	cn.lastUsed = time.Now()
+	select {
+	case c.idle <- cn:
+	default:
+		cn.quit()
+	}
+}
+
+// dial opens a connection, secures it and authenticates.
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	addr := net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port))
+	dialer := &net.Dialer{Timeout: c.cfg.Timeout}
+	tlsConfig := &tls.Config{ServerName: c.cfg.Host, MinVersion: tls.VersionTLS12}
+
+	var netConn net.Conn
+	var err error
+	if c.cfg.Security == SecurityTLS {
+		netConn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = netConn.SetDeadline(c.deadline(ctx))
+
+	client, err := smtp.NewClient(netConn, c.cfg.Host)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	if c.cfg.Security == SecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, domain.NewPermanentError(fmt.Errorf("smtp server %s does not support STARTTLS", addr))
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	if c.cfg.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			client.Close()
+			return nil, domain.NewPermanentError(fmt.Errorf("smtp server %s does not support AUTH", addr))
+		}
+		// PlainAuth refuses to send credentials unencrypted, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.cfg.Host)); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return &conn{client: client, netConn: netConn, lastUsed: time.Now()}, nil
+}
+
+// deadline bounds a single connection attempt or message by the context's
+// deadline and the configured timeout.
+func (c *Client) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(c.cfg.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+	return deadline
+}
+
+// deliver sends one message over the session.
+func (cn *conn) deliver(deadline time.Time, from, to string, msg []byte) error {
+	_ = cn.netConn.SetDeadline(deadline)
+
+	if err := cn.client.Mail(from); err != nil {
+		return err
+	}
+	if err := cn.client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := cn.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// quit ends the session politely, falling back to just closing it.
+func (cn *conn) quit() {
+	_ = cn.netConn.SetDeadline(time.Now().Add(time.Second))
+	if err := cn.client.Quit(); err != nil {
+		cn.client.Close()
+	}
+}
+
+// classify marks SMTP failures as retryable or permanent. 5xx replies
+// (unknown mailbox, rejected content, bad credentials) fail again on retry;
+// 4xx replies (greylisting, rate limits, full mailbox) and network errors
+// are transient.
+func classify(err error) error {
+	var deliveryErr *domain.DeliveryError
+	if errors.As(err, &deliveryErr) {
+		return err // Already classified
+	}
+
+	var replyErr *textproto.Error
+	if errors.As(err, &replyErr) && replyErr.Code >= 500 {
+		return domain.NewPermanentError(err)
+	}
+	return domain.NewRetryableError(err)
+}
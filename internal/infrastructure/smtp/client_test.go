@@ -0,0 +1,277 @@
+package smtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// fakeServer is a minimal SMTP server. It accepts every message unless a
+// recipient is listed in rcptReplies, in which case RCPT gets that reply.
+type fakeServer struct {
+	listener    net.Listener
+	rcptReplies map[string]string
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	messages int
+}
+
+func newFakeServer(t *testing.T, rcptReplies map[string]string) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &fakeServer{listener: listener, rcptReplies: rcptReplies}
+	go s.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropConnections()
+	})
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, nc)
+		s.mu.Unlock()
+		go s.handle(nc)
+	}
+}
+
+func (s *fakeServer) handle(nc net.Conn) {
+	defer nc.Close()
+	tp := textproto.NewConn(nc)
+	reply := func(line string) { _ = tp.PrintfLine("%s", line) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250 fake")
+		case "MAIL", "RSET", "NOOP":
+			reply("250 OK")
+		case "RCPT":
+			addr := strings.Trim(strings.TrimPrefix(strings.ToUpper(arg), "TO:"), "<>")
+			if r, ok := s.rcptReplies[strings.ToLower(addr)]; ok {
+				reply(r)
+				continue
+			}
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			if _, err := io.Copy(io.Discard, tp.DotReader()); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages++
+			s.mu.Unlock()
+			reply("250 Queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+// dropConnections closes every open connection from the server side, as a
+// server does with connections that sat idle too long.
+func (s *fakeServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, nc := range s.conns {
+		nc.Close()
+	}
+}
+
+func (s *fakeServer) connectionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+func (s *fakeServer) messageCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.messages
+}
+
+func newTestClient(t *testing.T, s *fakeServer, maxIdleConns int) *Client {
+	t.Helper()
+	addr := s.listener.Addr().(*net.TCPAddr)
+	client, err := NewClient(Config{
+		Host:         "127.0.0.1",
+		Port:         addr.Port,
+		Security:     SecurityNone,
+		FromEmail:    "noreply@example.com",
+		Timeout:      2 * time.Second,
+		MaxIdleConns: maxIdleConns,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func sendTestEmail(client *Client, to string) error {
+	_, err := client.SendEmail(context.Background(), &domain.EmailMessage{To: to, Subject: "Hi", Text: "Body"})
+	return err
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxIdleConns int
+		wantConns    int
+	}{
+		{"pooled", 1, 1},
+		{"pool disabled", 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t, nil)
+			client := newTestClient(t, server, tt.maxIdleConns)
+
+			for i := 0; i < 3; i++ {
+				if err := sendTestEmail(client, "user@example.com"); err != nil {
+					t.Fatalf("send %d: %v", i+1, err)
+				}
+			}
+
+			if got := server.messageCount(); got != 3 {
+				t.Errorf("server received %d messages, want 3", got)
+			}
+			if got := server.connectionCount(); got != tt.wantConns {
+				t.Errorf("client opened %d connections, want %d", got, tt.wantConns)
+			}
+		})
+	}
+}
+
+func TestClientRedialsDroppedConnection(t *testing.T) {
+	server := newFakeServer(t, nil)
+	client := newTestClient(t, server, 1)
+
+	if err := sendTestEmail(client, "user@example.com"); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	server.dropConnections()
+
+	if err := sendTestEmail(client, "user@example.com"); err != nil {
+		t.Fatalf("send after the server dropped the pooled connection: %v", err)
+	}
+	if got := server.connectionCount(); got != 2 {
+		t.Errorf("client opened %d connections, want 2", got)
+	}
+}
+
+func TestClientClassifiesRejections(t *testing.T) {
+	server := newFakeServer(t, map[string]string{
+		"unknown@example.com":    "550 5.1.1 No such user",
+		"greylisted@example.com": "451 4.7.1 Try again later",
+	})
+	client := newTestClient(t, server, 1)
+
+	tests := []struct {
+		to            string
+		wantRetryable bool
+	}{
+		{"unknown@example.com", false},
+		{"greylisted@example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.to, func(t *testing.T) {
+			err := sendTestEmail(client, tt.to)
+			if err == nil {
+				t.Fatal("send succeeded, want the rejection")
+			}
+			if got := domain.IsRetryable(err); got != tt.wantRetryable {
+				t.Errorf("IsRetryable(%v) = %v, want %v", err, got, tt.wantRetryable)
+			}
+		})
+	}
+
+	// A rejected message must not poison the pool
+	if err := sendTestEmail(client, "user@example.com"); err != nil {
+		t.Errorf("send after rejections: %v", err)
+	}
+}
+
+func TestClientConnectionRefusedIsRetryable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	client, err := NewClient(Config{Host: "127.0.0.1", Port: port, Security: SecurityNone, FromEmail: "noreply@example.com"}, nil)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	if err := sendTestEmail(client, "user@example.com"); err == nil || !domain.IsRetryable(err) {
+		t.Errorf("send to a closed port = %v, want a retryable error", err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	permanent := domain.NewPermanentError(errors.New("no STARTTLS"))
+
+	tests := []struct {
+		name          string
+		err           error
+		wantRetryable bool
+	}{
+		{"550 unknown mailbox", &textproto.Error{Code: 550, Msg: "No such user"}, false},
+		{"554 rejected content", &textproto.Error{Code: 554, Msg: "Message rejected"}, false},
+		{"535 bad credentials", &textproto.Error{Code: 535, Msg: "Authentication failed"}, false},
+		{"421 service unavailable", &textproto.Error{Code: 421, Msg: "Try again later"}, true},
+		{"451 greylisted", &textproto.Error{Code: 451, Msg: "Greylisted"}, true},
+		{"452 mailbox full", &textproto.Error{Code: 452, Msg: "Mailbox full"}, true},
+		{"wrapped 5xx", fmt.Errorf("failed to send email: %w", &textproto.Error{Code: 553, Msg: "Bad address"}), false},
+		{"network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"timeout", fmt.Errorf("failed to send email: %w", os.ErrDeadlineExceeded), true},
+		{"already classified", fmt.Errorf("failed to connect: %w", permanent), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classify(tt.err)
+			if !errors.Is(got, tt.err) {
+				t.Errorf("classify(%v) = %v, which doesn't wrap the original error", tt.err, got)
+			}
+			if retryable := domain.IsRetryable(got); retryable != tt.wantRetryable {
+				t.Errorf("IsRetryable(classify(%v)) = %v, want %v", tt.err, retryable, tt.wantRetryable)
+			}
+		})
+	}
+}
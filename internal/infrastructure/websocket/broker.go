@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+)
+
+// Broker fans in-app notifications out to every instance of the service.
+// A notification created on one instance is published once, and each
+// instance's Hub receives it and writes it to the sockets it holds.
+type Broker interface {
+	// Publish sends a message to every subscriber, on every instance.
+	Publish(ctx context.Context, msg *BroadcastMessage) error
+
+	// Subscribe calls handler for every published message until ctx is
+	// cancelled or the broker is closed.
+	Subscribe(ctx context.Context, handler func(*BroadcastMessage)) error
+
+	// Close releases the broker's connections and ends all subscriptions.
+	Close() error
+}
+
+// MemoryBroker is a Broker for a single instance (and for tests): messages
+// only reach subscribers in the same process.
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers map[int]func(*BroadcastMessage)
+	nextID   int
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// NewMemoryBroker creates a new in-process broker.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		handlers: make(map[int]func(*BroadcastMessage)),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Publish hands msg to every subscriber.
+func (b *MemoryBroker) Publish(ctx context.Context, msg *BroadcastMessage) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handler := range b.handlers {
+		handler(msg)
+	}
+	return nil
+}
+
+// Subscribe registers handler and blocks until ctx is cancelled or the broker is closed.
+func (b *MemoryBroker) Subscribe(ctx context.Context, handler func(*BroadcastMessage)) error {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.closed:
+		return nil
+	}
+}
+
+// Close ends all subscriptions.
+func (b *MemoryBroker) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
@@ -0,0 +1,202 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// TestRedisBrokerDeliversAcrossHubs runs two hubs, standing in for two
+// instances, against one Redis: a notification published through hub A must
+// reach the client connected to hub B.
+func TestRedisBrokerDeliversAcrossHubs(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	hubA := NewHub(newTestRedisBroker(t, mr), 0)
+	hubB := NewHub(newTestRedisBroker(t, mr), 0)
+	go hubA.Run()
+	go hubB.Run()
+	waitForSubscribers(t, mr, 2)
+
+	userID := uuid.New()
+	client := &Client{ID: uuid.New(), UserID: userID, Send: make(chan []byte, 1)}
+	hubB.Register(client)
+
+	n := domain.NewNotification(userID, domain.NotificationTypeInApp, "", "Interview tomorrow", "Good luck!")
+	if err := hubA.Notify(context.Background(), userID, n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	select {
+	case data := <-client.Send:
+		if !strings.Contains(string(data), n.ID.String()) {
+			t.Errorf("client received %s, want notification %s", data, n.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client on hub B never received the notification published on hub A")
+	}
+}
+
+// TestRedisBrokerResubscribesAfterDisconnect checks that a subscriber keeps
+// receiving after Redis drops its connection, as on a Redis restart.
+func TestRedisBrokerResubscribesAfterDisconnect(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	broker := newTestRedisBroker(t, mr)
+	received := subscribeTestBroker(t, broker)
+	waitForSubscribers(t, mr, 1)
+
+	mr.Close()
+	if err := mr.Restart(); err != nil {
+		t.Fatalf("failed to restart redis: %v", err)
+	}
+	waitForSubscribers(t, mr, 1)
+
+	userID := uuid.New()
+	event := domain.NewEvent(domain.EventUnreadCountChanged, domain.UnreadCountPayload{Count: 2})
+	if err := broker.Publish(context.Background(), &BroadcastMessage{UserID: userID, Event: event}); err != nil {
+		t.Fatalf("Publish after reconnect: %v", err)
+	}
+
+	if msg := receiveBroadcast(t, received); msg.UserID != userID || msg.Event.Type != domain.EventUnreadCountChanged {
+		t.Errorf("received %+v, want the unread count event", msg)
+	}
+}
+
+func newTestRedisBroker(t *testing.T, mr *miniredis.Miniredis) *RedisBroker {
+	t.Helper()
+	broker := NewRedisBroker(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "")
+	t.Cleanup(func() { _ = broker.Close() })
+	return broker
+}
+
+// waitForSubscribers waits until n connections subscribe to the channel,
+// so nothing is published before the hubs listen.
+func waitForSubscribers(t *testing.T, mr *miniredis.Miniredis, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if mr.PubSubNumSub(DefaultRedisChannel)[DefaultRedisChannel] >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("hubs did not subscribe to %s in time", DefaultRedisChannel)
+}
+
+// subscribeTestBroker subscribes to broker in the background and returns
+// the channel received messages arrive on.
+func subscribeTestBroker(t *testing.T, broker Broker) <-chan *BroadcastMessage {
+	t.Helper()
+	received := make(chan *BroadcastMessage, 8)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = broker.Subscribe(context.Background(), func(msg *BroadcastMessage) { received <- msg })
+	}()
+	t.Cleanup(func() {
+		_ = broker.Close()
+		<-done
+	})
+	return received
+}
+
+func receiveBroadcast(t *testing.T, received <-chan *BroadcastMessage) *BroadcastMessage {
+	t.Helper()
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+// TestBrokersDeliverTheSamePayload checks that a notification reaches clients
+// the same whichever broker carries it. The worker publishes an in-app
+// notification as sent while the stored row is still "sending", so the
+// PostgreSQL broker, which loads the row, must apply the published state.
+func TestBrokersDeliverTheSamePayload(t *testing.T) {
+	userID := uuid.New()
+	stored := domain.NewNotification(userID, domain.NotificationTypeInApp, "alert", "Interview tomorrow", "Good luck!")
+	stored.Status = domain.NotificationStatusSending
+	published := *stored
+	published.MarkAsSent()
+
+	loader := &fakeNotificationLoader{}
+	loader.add(stored)
+
+	// Each case returns the broker and a function waiting until it has a subscriber
+	tests := []struct {
+		name  string
+		setup func(t *testing.T) (Broker, func())
+	}{
+		{"memory", func(t *testing.T) (Broker, func()) {
+			broker := NewMemoryBroker()
+			return broker, func() {
+				deadline := time.Now().Add(2 * time.Second)
+				for time.Now().Before(deadline) {
+					broker.mu.RLock()
+					n := len(broker.handlers)
+					broker.mu.RUnlock()
+					if n > 0 {
+						return
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+				t.Fatal("memory broker never subscribed")
+			}
+		}},
+		{"redis", func(t *testing.T) (Broker, func()) {
+			mr := miniredis.RunT(t)
+			return newTestRedisBroker(t, mr), func() { waitForSubscribers(t, mr, 1) }
+		}},
+		{"postgres", func(t *testing.T) (Broker, func()) {
+			server := newFakePostgres(t)
+			broker := NewPostgresBroker(newFakePostgresPool(t, server), loader, "")
+			return broker, func() { server.waitForListens(t, 1, 2*time.Second) }
+		}},
+	}
+
+	want := payloadFields(t, &published)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker, subscribed := tt.setup(t)
+			received := subscribeTestBroker(t, broker)
+			subscribed()
+
+			event := domain.NewEvent(domain.EventNotificationCreated, &published)
+			if err := broker.Publish(context.Background(), &BroadcastMessage{UserID: userID, Event: event}); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+
+			got := payloadFields(t, receiveBroadcast(t, received).Event.Payload)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("payload = %v\nwant %v", got, want)
+			}
+		})
+	}
+}
+
+// payloadFields returns an event payload as clients decode it.
+func payloadFields(t *testing.T, payload interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	return fields
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -12,15 +13,62 @@ import (
 	"github.com/prepmyapp/notification/internal/domain"
 )
 
+// subscribeRetryDelay is how long the hub waits before resubscribing to a failed broker.
+const subscribeRetryDelay = 5 * time.Second
+
 // Client represents a connected WebSocket client.
 type Client struct {
 	ID     uuid.UUID
 	UserID uuid.UUID
 	Conn   *websocket.Conn
 	Send   chan []byte
+
+	// Notification channels the client subscribed to; empty means all
+	channels   map[string]bool
+	channelsMu sync.RWMutex
+
+	// Set by the hub before it closes Send, to tell the peer why
+	closeCode int
+	closeText string
+
+	connectedAt time.Time
+}
+
+// CloseMessage returns the close frame to send once the hub closed Send:
+// the reason if the hub evicted the client, otherwise an empty frame.
+func (c *Client) CloseMessage() []byte {
+	if c.closeCode == 0 {
+		return []byte{}
+	}
+	return websocket.FormatCloseMessage(c.closeCode, c.closeText)
+}
+
+// Subscribe limits the notifications the client receives live to the given
+// channels (e.g. "alert", "otp"). No channels means all of them.
+func (c *Client) Subscribe(channels []string) {
+	set := make(map[string]bool, len(channels))
+	for _, channel := range channels {
+		set[channel] = true
+	}
+
+	c.channelsMu.Lock()
+	defer c.channelsMu.Unlock()
+	c.channels = set
+}
+
+// WantsChannel reports whether the client subscribed to notifications on channel.
+func (c *Client) WantsChannel(channel string) bool {
+	c.channelsMu.RLock()
+	defer c.channelsMu.RUnlock()
+	return len(c.channels) == 0 || c.channels[channel]
 }
 
 // Hub maintains the set of active clients and broadcasts messages to clients.
+//
+// A single goroutine, Run, owns the clients: it alone adds and removes them
+// and closes their Send channels, so a channel is closed exactly once and
+// never written to after. Other goroutines only talk to it through channels;
+// the mutex merely lets them read the connection counts.
 type Hub struct {
 	// Registered clients grouped by user ID
 	clients map[uuid.UUID]map[*Client]bool
@@ -31,127 +79,258 @@ type Hub struct {
 	// Unregister requests from clients
 	unregister chan *Client
 
-	// Broadcast channel for notifications
+	// Broadcast channel for events
 	broadcast chan *BroadcastMessage
 
-	// Mutex for thread-safe client map access
+	// Messages for a single client, such as command replies
+	direct chan directMessage
+
+	// Closed by Close to stop Run
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	// Broker carrying notifications between instances
+	broker Broker
+
+	// Maximum connections per user on this instance (0 for unlimited)
+	maxConnsPerUser int
+
+	// Guards clients against reads from outside Run; Run writes under it
 	mu sync.RWMutex
 }
 
-// BroadcastMessage represents a message to broadcast to specific users.
+// BroadcastMessage represents an event to broadcast to a specific user.
 type BroadcastMessage struct {
-	UserID       uuid.UUID
-	Notification *domain.Notification
+	UserID uuid.UUID     `json:"user_id"`
+	Event  *domain.Event `json:"event"`
 }
 
-// NewHub creates a new WebSocket hub.
-func NewHub() *Hub {
+// directMessage is data for one client.
+type directMessage struct {
+	client *Client
+	data   []byte
+}
+
+// NewHub creates a new WebSocket hub that receives notifications through
+// broker. A nil broker means a MemoryBroker (single instance). Once a user
+// has maxConnsPerUser connections, each new one closes the oldest
+// (0 for unlimited).
+func NewHub(broker Broker, maxConnsPerUser int) *Hub {
+	if broker == nil {
+		broker = NewMemoryBroker()
+	}
+
 	return &Hub{
-		clients:    make(map[uuid.UUID]map[*Client]bool),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		broadcast:  make(chan *BroadcastMessage, 256),
+		clients:         make(map[uuid.UUID]map[*Client]bool),
+		register:        make(chan *Client),
+		unregister:      make(chan *Client),
+		broadcast:       make(chan *BroadcastMessage, 256),
+		direct:          make(chan directMessage, 256),
+		quit:            make(chan struct{}),
+		broker:          broker,
+		maxConnsPerUser: maxConnsPerUser,
 	}
 }
 
-// Run starts the hub's main loop.
+// Run starts the hub's main loop. It returns once the hub is closed, after
+// closing every client's connection.
 func (h *Hub) Run() {
+	go h.subscribe()
+
 	for {
 		select {
 		case client := <-h.register:
 			h.registerClient(client)
 
 		case client := <-h.unregister:
-			h.unregisterClient(client)
+			h.removeClient(client, 0, "")
 
 		case message := <-h.broadcast:
 			h.broadcastToUser(message)
+
+		case message := <-h.direct:
+			h.sendDirect(message)
+
+		case <-h.quit:
+			for _, clients := range h.clients {
+				for client := range clients {
+					h.removeClient(client, websocket.CloseGoingAway, "server shutting down")
+				}
+			}
+			return
 		}
 	}
 }
 
-// registerClient adds a client to the hub.
+// registerClient adds a client to the hub, closing the user's oldest
+// connections beyond the limit.
 func (h *Hub) registerClient(client *Client) {
+	for h.maxConnsPerUser > 0 && len(h.clients[client.UserID]) >= h.maxConnsPerUser {
+		var oldest *Client
+		for c := range h.clients[client.UserID] {
+			if oldest == nil || c.connectedAt.Before(oldest.connectedAt) {
+				oldest = c
+			}
+		}
+		h.removeClient(oldest, websocket.ClosePolicyViolation, "too many connections")
+		log.Printf("Client %s evicted for user %s: connection limit of %d reached",
+			oldest.ID, client.UserID, h.maxConnsPerUser)
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	if h.clients[client.UserID] == nil {
 		h.clients[client.UserID] = make(map[*Client]bool)
 	}
+	client.connectedAt = time.Now()
 	h.clients[client.UserID][client] = true
 
 	log.Printf("Client %s registered for user %s (total connections: %d)",
 		client.ID, client.UserID, len(h.clients[client.UserID]))
 }
 
-// unregisterClient removes a client from the hub.
-func (h *Hub) unregisterClient(client *Client) {
+// removeClient removes a client from the hub and closes its Send channel,
+// telling the peer closeCode and closeText (0 for a plain close). It is a
+// no-op for clients that are already gone, so Send is closed only once.
+func (h *Hub) removeClient(client *Client, closeCode int, closeText string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if clients, ok := h.clients[client.UserID]; ok {
-		if _, exists := clients[client]; exists {
-			delete(clients, client)
-			close(client.Send)
-
-			// Clean up empty user entries
-			if len(clients) == 0 {
-				delete(h.clients, client.UserID)
-			}
+	clients, ok := h.clients[client.UserID]
+	if !ok || !clients[client] {
+		return
+	}
 
-			log.Printf("Client %s unregistered for user %s", client.ID, client.UserID)
-		}
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(h.clients, client.UserID)
 	}
+
+	// Set before closing: the close makes them visible to the writer
+	client.closeCode = closeCode
+	client.closeText = closeText
+	close(client.Send)
+
+	log.Printf("Client %s unregistered for user %s", client.ID, client.UserID)
 }
 
-// broadcastToUser sends a notification to all of a user's connected clients.
+// broadcastToUser sends an event to all of a user's connected clients.
+// A client whose buffer is full isn't keeping up and is disconnected.
 func (h *Hub) broadcastToUser(message *BroadcastMessage) {
-	h.mu.RLock()
-	clients, ok := h.clients[message.UserID]
-	h.mu.RUnlock()
-
-	if !ok {
+	clients := h.clients[message.UserID]
+	if len(clients) == 0 {
 		return
 	}
 
-	data, err := json.Marshal(message.Notification)
+	data, err := json.Marshal(message.Event)
 	if err != nil {
-		log.Printf("Failed to marshal notification: %v", err)
+		log.Printf("Failed to marshal %s event: %v", message.Event.Type, err)
 		return
 	}
 
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-
 	for client := range clients {
+		h.send(client, data)
+	}
+}
+
+// sendDirect sends data to one client, if it is still connected.
+func (h *Hub) sendDirect(message directMessage) {
+	if h.clients[message.client.UserID][message.client] {
+		h.send(message.client, message.data)
+	}
+}
+
+// send queues data for a registered client without blocking the hub.
+func (h *Hub) send(client *Client, data []byte) {
+	select {
+	case client.Send <- data:
+	default:
+		log.Printf("Client %s for user %s is too slow, disconnecting", client.ID, client.UserID)
+		h.removeClient(client, websocket.CloseTryAgainLater, "client too slow")
+	}
+}
+
+// subscribe feeds messages published by any instance into the main loop,
+// retrying if the broker's subscription fails.
+func (h *Hub) subscribe() {
+	for {
+		err := h.broker.Subscribe(context.Background(), func(msg *BroadcastMessage) {
+			select {
+			case h.broadcast <- msg:
+			case <-h.quit:
+			}
+		})
+		if err == nil {
+			return // Broker closed
+		}
+
+		log.Printf("[Hub] broker subscription failed, retrying in %s: %v", subscribeRetryDelay, err)
 		select {
-		case client.Send <- data:
-		default:
-			// Client buffer full, close connection
-			close(client.Send)
-			delete(clients, client)
+		case <-time.After(subscribeRetryDelay):
+		case <-h.quit:
+			return
 		}
 	}
 }
 
 // Register adds a client to the hub.
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.quit:
+		// Hub closed; close Send like Run would have
+		client.closeCode = websocket.CloseGoingAway
+		client.closeText = "server shutting down"
+		close(client.Send)
+	}
+}
+
+// Reply queues data for a single client, such as the answer to a command it
+// sent. It is dropped if the client is gone by the time the hub gets to it.
+func (h *Hub) Reply(client *Client, data []byte) {
+	select {
+	case h.direct <- directMessage{client: client, data: data}:
+	case <-h.quit:
+	}
 }
 
 // Unregister removes a client from the hub.
 func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.quit:
+		// Run closed every client on its way out
+	}
 }
 
-// Notify sends a notification to a user via WebSocket.
+// Emit publishes an event through the broker, so it reaches the user's
+// sockets on whichever instance holds them.
 // Implements the service.InAppNotifier interface.
+func (h *Hub) Emit(ctx context.Context, userID uuid.UUID, event *domain.Event) error {
+	return h.broker.Publish(ctx, &BroadcastMessage{
+		UserID: userID,
+		Event:  event,
+	})
+}
+
+// Notify emits a notification.created event for notification.
 func (h *Hub) Notify(ctx context.Context, userID uuid.UUID, notification *domain.Notification) error {
-	h.broadcast <- &BroadcastMessage{
-		UserID:       userID,
-		Notification: notification,
-	}
-	return nil
+	return h.Emit(ctx, userID, domain.NewEvent(domain.EventNotificationCreated, notification))
+}
+
+// Disconnect stops the hub, disconnecting every client, but leaves its
+// broker open: Emit keeps publishing, so notifications sent while the rest of
+// the service shuts down still reach clients connected to other instances.
+func (h *Hub) Disconnect() {
+	h.quitOnce.Do(func() { close(h.quit) })
+}
+
+// Close stops the hub, disconnecting every client, and closes its broker.
+// Nothing may Emit through the hub afterwards.
+func (h *Hub) Close() error {
+	h.Disconnect()
+	return h.broker.Close()
 }
 
 // GetConnectedUsers returns the number of unique users with active connections.
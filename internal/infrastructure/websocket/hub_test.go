@@ -0,0 +1,299 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// TestHubConcurrentUse registers, unregisters, broadcasts to and replies to
+// clients from many goroutines at once. Run it with -race: any unguarded
+// access to the client map, or a Send channel closed twice or written after
+// close, fails the test.
+func TestHubConcurrentUse(t *testing.T) {
+	hub := newTestHub(t, 0)
+
+	const (
+		users      = 8
+		goroutines = 16
+		rounds     = 50
+	)
+	userIDs := make([]uuid.UUID, users)
+	for i := range userIDs {
+		userIDs[i] = uuid.New()
+	}
+
+	var (
+		connections sync.WaitGroup // Clients coming and going
+		readers     sync.WaitGroup // Drain Send until the hub closes it
+	)
+	for g := 0; g < goroutines; g++ {
+		connections.Add(1)
+		go func(g int) {
+			defer connections.Done()
+			for r := 0; r < rounds; r++ {
+				client := newTestClient(userIDs[(g+r)%users], 4)
+				readers.Add(1)
+				go func() {
+					defer readers.Done()
+					for range client.Send {
+					}
+				}()
+
+				hub.Register(client)
+				hub.Reply(client, []byte(`{}`))
+				if r%2 == 0 {
+					hub.Unregister(client)
+				}
+			}
+		}(g)
+	}
+
+	stop := make(chan struct{})
+	var broadcasters sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		broadcasters.Add(1)
+		go func(g int) {
+			defer broadcasters.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				event := domain.NewEvent(domain.EventUnreadCountChanged, domain.UnreadCountPayload{Count: int64(i)})
+				if err := hub.Emit(context.Background(), userIDs[(g+i)%users], event); err != nil {
+					t.Errorf("Emit: %v", err)
+					return
+				}
+				_ = hub.GetTotalConnections()
+				_ = hub.IsUserConnected(userIDs[i%users])
+			}
+		}(g)
+	}
+
+	connections.Wait()
+	close(stop)
+	broadcasters.Wait()
+
+	// Half the clients are still connected; closing the hub must close them all
+	if err := hub.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	waitGroup(t, &readers, "clients still open after the hub closed")
+}
+
+// TestHubEvictsSlowConsumer checks that a client whose buffer fills up is
+// disconnected with a reason instead of blocking the hub.
+func TestHubEvictsSlowConsumer(t *testing.T) {
+	hub := newTestHub(t, 0)
+
+	userID := uuid.New()
+	slow := newTestClient(userID, 1)
+	fast := newTestClient(userID, 8)
+	hub.Register(slow)
+	hub.Register(fast)
+
+	for i := 0; i < 3; i++ {
+		event := domain.NewEvent(domain.EventUnreadCountChanged, domain.UnreadCountPayload{Count: int64(i)})
+		if err := hub.Emit(context.Background(), userID, event); err != nil {
+			t.Fatalf("Emit: %v", err)
+		}
+	}
+
+	// The other client keeps up and gets every event; once it has, the hub
+	// has handled them all
+	for i := 0; i < 3; i++ {
+		select {
+		case <-fast.Send:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("fast client received %d events, want 3", i)
+		}
+	}
+
+	// The slow client gets the one event it had room for, then the close
+	received := drain(t, slow)
+	if received != 1 {
+		t.Errorf("slow client received %d events before eviction, want 1", received)
+	}
+	if slow.closeCode != websocket.CloseTryAgainLater {
+		t.Errorf("slow client closed with code %d, want %d", slow.closeCode, websocket.CloseTryAgainLater)
+	}
+	if slow.closeText == "" {
+		t.Error("slow client closed without a reason")
+	}
+
+	if got := hub.GetTotalConnections(); got != 1 {
+		t.Errorf("hub has %d connections after eviction, want 1", got)
+	}
+}
+
+// TestHubConnectionLimit checks that a user's oldest connection is closed
+// once they open more than the limit.
+func TestHubConnectionLimit(t *testing.T) {
+	hub := newTestHub(t, 2)
+
+	userID := uuid.New()
+	first := newTestClient(userID, 1)
+	hub.Register(first)
+	hub.Register(newTestClient(userID, 1))
+	hub.Register(newTestClient(userID, 1))
+
+	drain(t, first)
+	if first.closeCode != websocket.ClosePolicyViolation {
+		t.Errorf("oldest client closed with code %d, want %d", first.closeCode, websocket.ClosePolicyViolation)
+	}
+	if got := hub.GetTotalConnections(); got != 2 {
+		t.Errorf("user has %d connections, want 2", got)
+	}
+}
+
+// TestHubClose checks that closing the hub disconnects clients, and that
+// clients arriving afterwards are turned away rather than left hanging.
+func TestHubClose(t *testing.T) {
+	hub := newTestHub(t, 0)
+
+	client := newTestClient(uuid.New(), 1)
+	hub.Register(client)
+
+	if err := hub.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	drain(t, client)
+	if client.closeCode != websocket.CloseGoingAway {
+		t.Errorf("client closed with code %d, want %d", client.closeCode, websocket.CloseGoingAway)
+	}
+
+	// None of these may block
+	late := newTestClient(uuid.New(), 1)
+	hub.Register(late)
+	drain(t, late)
+	hub.Reply(late, []byte(`{}`))
+	hub.Unregister(late)
+	hub.Unregister(client)
+}
+
+// closeRecordingBroker is a MemoryBroker that remembers being closed.
+type closeRecordingBroker struct {
+	*MemoryBroker
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (b *closeRecordingBroker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	return b.MemoryBroker.Close()
+}
+
+func (b *closeRecordingBroker) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// TestHubDisconnectKeepsBroker checks that disconnecting the clients at the
+// start of a shutdown leaves the broker open for the notifications emitted
+// until delivery stops, and that Close closes it afterwards.
+func TestHubDisconnectKeepsBroker(t *testing.T) {
+	broker := &closeRecordingBroker{MemoryBroker: NewMemoryBroker()}
+	hub := NewHub(broker, 0)
+	go hub.Run()
+
+	client := newTestClient(uuid.New(), 1)
+	hub.Register(client)
+
+	hub.Disconnect()
+	drain(t, client)
+	if client.closeCode != websocket.CloseGoingAway {
+		t.Errorf("client closed with code %d, want %d", client.closeCode, websocket.CloseGoingAway)
+	}
+	if broker.isClosed() {
+		t.Fatal("Disconnect closed the broker")
+	}
+
+	event := domain.NewEvent(domain.EventUnreadCountChanged, domain.UnreadCountPayload{Count: 1})
+	if err := hub.Emit(context.Background(), client.UserID, event); err != nil {
+		t.Errorf("Emit after Disconnect: %v", err)
+	}
+
+	if err := hub.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !broker.isClosed() {
+		t.Error("Close left the broker open")
+	}
+}
+
+// newTestHub starts a hub on a MemoryBroker and waits until it is subscribed,
+// so no event emitted by the test is lost.
+func newTestHub(t *testing.T, maxConnsPerUser int) *Hub {
+	t.Helper()
+
+	broker := NewMemoryBroker()
+	hub := NewHub(broker, maxConnsPerUser)
+	go hub.Run()
+	t.Cleanup(func() { _ = hub.Close() })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		broker.mu.RLock()
+		subscribed := len(broker.handlers) > 0
+		broker.mu.RUnlock()
+		if subscribed {
+			return hub
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("hub never subscribed to the broker")
+	return nil
+}
+
+func newTestClient(userID uuid.UUID, buffer int) *Client {
+	return &Client{ID: uuid.New(), UserID: userID, Send: make(chan []byte, buffer)}
+}
+
+// drain reads client.Send until the hub closes it and returns the number of
+// messages read.
+func drain(t *testing.T, client *Client) int {
+	t.Helper()
+
+	received := 0
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-client.Send:
+			if !ok {
+				return received
+			}
+			received++
+		case <-timeout:
+			t.Fatalf("hub never closed client %s", client.ID)
+		}
+	}
+}
+
+// waitGroup waits for wg, failing the test with msg if it takes too long.
+func waitGroup(t *testing.T, wg *sync.WaitGroup, msg string) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal(msg)
+	}
+}
@@ -0,0 +1,214 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+const (
+	// DefaultPostgresChannel is the LISTEN/NOTIFY channel in-app notifications are announced on.
+	DefaultPostgresChannel = "notifications_in_app"
+
+	// maxNotifyPayload is PostgreSQL's limit for a NOTIFY payload (8000 bytes
+	// in a default build). Events are small once notifications are sent by
+	// ID; anything bigger is rejected here rather than by the server.
+	maxNotifyPayload = 7999
+
+	// Reconnect backoff for the listening connection
+	listenRetryMin = time.Second
+	listenRetryMax = 30 * time.Second
+)
+
+// NotificationLoader loads a stored notification by ID.
+type NotificationLoader interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Notification, error)
+}
+
+// PostgresBroker is a Broker for environments with PostgreSQL but no Redis.
+// Publish sends events with pg_notify, a new notification by its ID only;
+// every instance holds one dedicated connection that LISTENs on the channel,
+// loads announced notifications from the database and hands the events to
+// its hub.
+//
+// Like Redis pub/sub, NOTIFY is fire-and-forget: while an instance is
+// reconnecting it misses announcements. The notifications are stored, so
+// clients catch up when they reload the list.
+type PostgresBroker struct {
+	pool          *pgxpool.Pool
+	notifications NotificationLoader
+	channel       string
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewPostgresBroker creates a broker that notifies on channel ("" for DefaultPostgresChannel).
+func NewPostgresBroker(pool *pgxpool.Pool, notifications NotificationLoader, channel string) *PostgresBroker {
+	if channel == "" {
+		channel = DefaultPostgresChannel
+	}
+	return &PostgresBroker{
+		pool:          pool,
+		notifications: notifications,
+		channel:       channel,
+		closed:        make(chan struct{}),
+	}
+}
+
+// postgresMessage is a BroadcastMessage as sent through NOTIFY. An event
+// carrying a notification leaves the payload out and names the notification
+// instead, since notification bodies could exceed the payload limit.
+//
+// The notification's delivery state goes along with the ID: it is published
+// while the worker still has the stored row at "sending", and only records
+// the outcome once delivery returns.
+type postgresMessage struct {
+	UserID         uuid.UUID       `json:"user_id"`
+	Event          *domain.Event   `json:"event"`
+	NotificationID *uuid.UUID      `json:"notification_id,omitempty"`
+	State          *publishedState `json:"state,omitempty"`
+}
+
+// publishedState is the part of a notification that changes during delivery.
+type publishedState struct {
+	Status    domain.NotificationStatus `json:"status"`
+	SentAt    *time.Time                `json:"sent_at,omitempty"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+}
+
+// Publish announces msg on the channel.
+func (b *PostgresBroker) Publish(ctx context.Context, msg *BroadcastMessage) error {
+	m := postgresMessage{UserID: msg.UserID, Event: msg.Event}
+	if n, ok := msg.Event.Payload.(*domain.Notification); ok {
+		event := *msg.Event
+		event.Payload = nil
+		m.Event = &event
+		m.NotificationID = &n.ID
+		m.State = &publishedState{Status: n.Status, SentAt: n.SentAt, UpdatedAt: n.UpdatedAt}
+	}
+
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal broadcast message: %w", err)
+	}
+	if len(payload) > maxNotifyPayload {
+		return fmt.Errorf("notify payload of %d bytes exceeds the %d byte limit", len(payload), maxNotifyPayload)
+	}
+
+	if _, err := b.pool.Exec(ctx, "SELECT pg_notify($1, $2)", b.channel, string(payload)); err != nil {
+		return fmt.Errorf("failed to notify %s: %w", b.channel, err)
+	}
+	return nil
+}
+
+// Subscribe listens on the channel and calls handler for every announced
+// event until ctx is cancelled or the broker is closed. A dropped
+// connection is re-established with exponential backoff.
+func (b *PostgresBroker) Subscribe(ctx context.Context, handler func(*BroadcastMessage)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-b.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	retry := listenRetryMin
+	for {
+		err := b.listen(ctx, handler, func() { retry = listenRetryMin })
+
+		select {
+		case <-b.closed:
+			return nil
+		default:
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Printf("[PostgresBroker] listen connection lost, reconnecting in %s: %v", retry, err)
+		select {
+		case <-ctx.Done():
+			continue // Reported by the checks above
+		case <-time.After(retry):
+		}
+
+		retry *= 2
+		if retry > listenRetryMax {
+			retry = listenRetryMax
+		}
+	}
+}
+
+// listen runs one LISTEN session on a connection taken out of the pool for
+// good (a listening connection can't be shared). connected is called once
+// LISTEN succeeded, to reset the reconnect backoff.
+func (b *PostgresBroker) listen(ctx context.Context, handler func(*BroadcastMessage), connected func()) error {
+	pooled, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	conn := pooled.Hijack()
+	defer func() {
+		// Closing needs its own context: ctx may already be cancelled
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = conn.Close(closeCtx)
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", b.channel, err)
+	}
+	connected()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		b.deliver(ctx, n.Payload, handler)
+	}
+}
+
+// deliver decodes an announced event, loads the notification it names if
+// any, with its state as published, and hands it to handler.
+func (b *PostgresBroker) deliver(ctx context.Context, payload string, handler func(*BroadcastMessage)) {
+	var m postgresMessage
+	if err := json.Unmarshal([]byte(payload), &m); err != nil || m.Event == nil {
+		log.Printf("[PostgresBroker] dropping malformed payload %q", payload)
+		return
+	}
+
+	if m.NotificationID != nil {
+		n, err := b.notifications.GetByID(ctx, *m.NotificationID)
+		if err != nil {
+			log.Printf("[PostgresBroker] failed to load notification %s: %v", *m.NotificationID, err)
+			return
+		}
+		if m.State != nil {
+			n.Status, n.SentAt, n.UpdatedAt = m.State.Status, m.State.SentAt, m.State.UpdatedAt
+		}
+		m.Event.Payload = n
+	}
+
+	handler(&BroadcastMessage{UserID: m.UserID, Event: m.Event})
+}
+
+// Close ends all subscriptions. The pool itself belongs to the caller.
+func (b *PostgresBroker) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
@@ -0,0 +1,310 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// fakePostgres speaks just enough of the PostgreSQL wire protocol for the
+// broker: it accepts any login, answers LISTEN, and turns pg_notify calls
+// into notifications for every listening connection.
+type fakePostgres struct {
+	listener net.Listener
+
+	mu        sync.Mutex
+	conns     map[*fakePostgresConn]bool
+	listening map[*fakePostgresConn]bool
+	listens   int // LISTEN statements received, counting reconnects
+}
+
+// fakePostgresConn is one client connection. Notifications are sent from
+// other connections' goroutines, so sends are serialized.
+type fakePostgresConn struct {
+	net.Conn
+	backend *pgproto3.Backend
+	sendMu  sync.Mutex
+}
+
+func (c *fakePostgresConn) send(msgs ...pgproto3.BackendMessage) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	for _, msg := range msgs {
+		c.backend.Send(msg)
+	}
+	return c.backend.Flush()
+}
+
+// pgNotifyQuery matches pg_notify as pgx sends it over the simple protocol.
+var pgNotifyQuery = regexp.MustCompile(`(?s)^SELECT pg_notify\(\s*'((?:[^']|'')*)'\s*,\s*'((?:[^']|'')*)'\s*\)$`)
+
+func newFakePostgres(t *testing.T) *fakePostgres {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	s := &fakePostgres{
+		listener:  listener,
+		conns:     make(map[*fakePostgresConn]bool),
+		listening: make(map[*fakePostgresConn]bool),
+	}
+	go s.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		s.dropConnections()
+	})
+	return s
+}
+
+func (s *fakePostgres) serve() {
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &fakePostgresConn{Conn: nc, backend: pgproto3.NewBackend(nc, nc)}
+		s.mu.Lock()
+		s.conns[c] = true
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *fakePostgres) handle(c *fakePostgresConn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		delete(s.listening, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	if _, err := c.backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	err := c.send(
+		&pgproto3.AuthenticationOk{},
+		&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"},
+		&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"},
+		&pgproto3.ParameterStatus{Name: "server_version", Value: "16.0"},
+		&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1},
+		&pgproto3.ReadyForQuery{TxStatus: 'I'},
+	)
+	if err != nil {
+		return
+	}
+
+	for {
+		msg, err := c.backend.Receive()
+		if err != nil {
+			return
+		}
+		query, ok := msg.(*pgproto3.Query)
+		if !ok {
+			return // Terminate, or something this fake doesn't support
+		}
+
+		sql := strings.TrimSpace(query.String)
+		switch {
+		case strings.HasPrefix(sql, "LISTEN "):
+			s.mu.Lock()
+			s.listening[c] = true
+			s.listens++
+			s.mu.Unlock()
+			err = c.send(&pgproto3.CommandComplete{CommandTag: []byte("LISTEN")}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+
+		case pgNotifyQuery.MatchString(sql):
+			m := pgNotifyQuery.FindStringSubmatch(sql)
+			channel, payload := strings.ReplaceAll(m[1], "''", "'"), strings.ReplaceAll(m[2], "''", "'")
+			err = c.send(
+				&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{Name: []byte("pg_notify"), DataTypeOID: 2278, DataTypeSize: 4, TypeModifier: -1}}},
+				&pgproto3.DataRow{Values: [][]byte{{}}},
+				&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")},
+				&pgproto3.ReadyForQuery{TxStatus: 'I'},
+			)
+			s.notify(channel, payload)
+
+		case sql == "" || strings.HasPrefix(sql, "--"):
+			err = c.send(&pgproto3.EmptyQueryResponse{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+
+		default:
+			err = c.send(
+				&pgproto3.ErrorResponse{Severity: "ERROR", Code: "0A000", Message: "not supported by fakePostgres: " + sql},
+				&pgproto3.ReadyForQuery{TxStatus: 'I'},
+			)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// notify sends a notification to every listening connection.
+func (s *fakePostgres) notify(channel, payload string) {
+	s.mu.Lock()
+	listeners := make([]*fakePostgresConn, 0, len(s.listening))
+	for c := range s.listening {
+		listeners = append(listeners, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range listeners {
+		_ = c.send(&pgproto3.NotificationResponse{PID: 1, Channel: channel, Payload: payload})
+	}
+}
+
+// dropConnections closes every connection from the server side, as a
+// database restart or failover does.
+func (s *fakePostgres) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// waitForListens waits until the server has received n LISTEN statements.
+func (s *fakePostgres) waitForListens(t *testing.T, n int, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		listens := s.listens
+		s.mu.Unlock()
+		if listens >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("broker did not LISTEN %d time(s) within %s", n, timeout)
+}
+
+// newFakePostgresPool connects a pool to s. The simple protocol lets the
+// fake see pg_notify's arguments in the query text.
+func newFakePostgresPool(t *testing.T, s *fakePostgres) *pgxpool.Pool {
+	t.Helper()
+	cfg, err := pgxpool.ParseConfig(fmt.Sprintf("postgres://test@%s/test?sslmode=disable", s.listener.Addr()))
+	if err != nil {
+		t.Fatalf("failed to parse pool config: %v", err)
+	}
+	cfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// fakeNotificationLoader serves the notifications it was given.
+type fakeNotificationLoader struct {
+	mu            sync.Mutex
+	notifications map[uuid.UUID]*domain.Notification
+}
+
+func (l *fakeNotificationLoader) add(n *domain.Notification) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.notifications == nil {
+		l.notifications = make(map[uuid.UUID]*domain.Notification)
+	}
+	l.notifications[n.ID] = n
+}
+
+func (l *fakeNotificationLoader) GetByID(ctx context.Context, id uuid.UUID) (*domain.Notification, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n, ok := l.notifications[id]
+	if !ok {
+		return nil, domain.NewErrNotFound("notification", id.String())
+	}
+	return n, nil
+}
+
+// TestPostgresBrokerSendsNotificationsByID checks that a notification too
+// big for a NOTIFY payload is announced by ID and loaded by the listener.
+func TestPostgresBrokerSendsNotificationsByID(t *testing.T) {
+	server := newFakePostgres(t)
+	loader := &fakeNotificationLoader{}
+	broker := NewPostgresBroker(newFakePostgresPool(t, server), loader, "")
+	received := subscribeTestBroker(t, broker)
+	server.waitForListens(t, 1, 2*time.Second)
+
+	userID := uuid.New()
+	n := domain.NewNotification(userID, domain.NotificationTypeInApp, "", "Weekly digest", strings.Repeat("It's been a busy week. ", 1000))
+	loader.add(n)
+
+	if err := broker.Publish(context.Background(), &BroadcastMessage{UserID: userID, Event: domain.NewEvent(domain.EventNotificationCreated, n)}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	msg := receiveBroadcast(t, received)
+	got, ok := msg.Event.Payload.(*domain.Notification)
+	if msg.UserID != userID || !ok || got.ID != n.ID || got.Body != n.Body {
+		t.Errorf("received %+v, want notification %s", msg, n.ID)
+	}
+}
+
+// TestPostgresBrokerRejectsOversizedPayload checks that an event over the
+// NOTIFY payload limit fails at Publish instead of at the server.
+func TestPostgresBrokerRejectsOversizedPayload(t *testing.T) {
+	server := newFakePostgres(t)
+	broker := NewPostgresBroker(newFakePostgresPool(t, server), &fakeNotificationLoader{}, "")
+
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{"small", 100, false},
+		{"just over the limit", maxNotifyPayload, true},
+		{"far over the limit", 10 * maxNotifyPayload, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := domain.NewEvent(domain.EventType("test.large"), map[string]string{"data": strings.Repeat("x", tt.size)})
+			err := broker.Publish(context.Background(), &BroadcastMessage{UserID: uuid.New(), Event: event})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Publish error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPostgresBrokerReconnects checks that the listener re-establishes its
+// LISTEN after the database drops the connection, and receives again.
+func TestPostgresBrokerReconnects(t *testing.T) {
+	server := newFakePostgres(t)
+	broker := NewPostgresBroker(newFakePostgresPool(t, server), &fakeNotificationLoader{}, "")
+	received := subscribeTestBroker(t, broker)
+	server.waitForListens(t, 1, 2*time.Second)
+
+	server.dropConnections()
+	server.waitForListens(t, 2, listenRetryMin+2*time.Second)
+
+	userID := uuid.New()
+	event := domain.NewEvent(domain.EventUnreadCountChanged, domain.UnreadCountPayload{Count: 3})
+	if err := broker.Publish(context.Background(), &BroadcastMessage{UserID: userID, Event: event}); err != nil {
+		t.Fatalf("Publish after reconnect: %v", err)
+	}
+
+	if msg := receiveBroadcast(t, received); msg.UserID != userID || msg.Event.Type != domain.EventUnreadCountChanged {
+		t.Errorf("received %+v, want the unread count event", msg)
+	}
+}
@@ -0,0 +1,514 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// DeliveryJobRepository implements domain.DeliveryJobRepository using PostgreSQL.
+type DeliveryJobRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewDeliveryJobRepository creates a new PostgreSQL delivery job repository.
+func NewDeliveryJobRepository(pool *pgxpool.Pool) *DeliveryJobRepository {
+	return &DeliveryJobRepository{pool: pool}
+}
+
+// Enqueue saves a notification and its delivery job in a single transaction.
+// The unique index on (idempotency_key, user_id) catches concurrent
+// duplicates that got past the idempotency key reservation. An expired key
+// is cleared from the notification that had it first, so it can be reused.
+func (r *DeliveryJobRepository) Enqueue(ctx context.Context, n *domain.Notification, job *domain.DeliveryJob) error {
+	metadata, err := json.Marshal(n.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if n.IdempotencyKey != "" {
+		_, err = tx.Exec(ctx, `
+			UPDATE notifications SET idempotency_key = NULL, idempotency_expires_at = NULL
+			WHERE idempotency_key = $1 AND user_id = $2 AND idempotency_expires_at <= $3
+		`, n.IdempotencyKey, n.UserID, n.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to release expired idempotency key: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO notifications (id, user_id, type, channel, title, body, metadata, status, idempotency_key, idempotency_expires_at, scheduled_at, defer_reason, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, NULLIF($12, ''), $13, $14)
+	`,
+		n.ID,
+		n.UserID,
+		n.Type,
+		n.Channel,
+		n.Title,
+		n.Body,
+		metadata,
+		n.Status,
+		n.IdempotencyKey,
+		n.IdempotencyExpiresAt,
+		n.ScheduledAt,
+		n.DeferReason,
+		n.CreatedAt,
+		n.UpdatedAt,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.NewErrDuplicate("notification", n.IdempotencyKey)
+		}
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO delivery_jobs (id, notification_id, payload, status, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`,
+		job.ID,
+		job.NotificationID,
+		payload,
+		job.Status,
+		job.NextAttemptAt,
+		job.CreatedAt,
+		job.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create delivery job: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Claim locks up to limit due jobs for a worker and counts the attempt.
+// SKIP LOCKED lets several workers (and instances) poll the same table
+// without blocking on, or double-claiming, each other's rows.
+func (r *DeliveryJobRepository) Claim(ctx context.Context, workerID string, limit int, lockTimeout time.Duration) ([]*domain.DeliveryJob, error) {
+	query := `
+		UPDATE delivery_jobs j
+		SET status = 'processing', attempts = j.attempts + 1, locked_by = $1, locked_at = $2, updated_at = $2
+		FROM (
+			SELECT id
+			FROM delivery_jobs
+			WHERE (status = 'pending' AND next_attempt_at <= $2)
+			   OR (status = 'processing' AND locked_at < $3)
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		) claimable
+		WHERE j.id = claimable.id
+		RETURNING ` + deliveryJobColumns("j.")
+
+	now := time.Now()
+	rows, err := r.pool.Query(ctx, query, workerID, now, now.Add(-lockTimeout), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim delivery jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*domain.DeliveryJob
+	for rows.Next() {
+		job, err := r.scanDeliveryJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating delivery jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// Complete marks a job as done and clears its lock.
+func (r *DeliveryJobRepository) Complete(ctx context.Context, id uuid.UUID, workerID string) error {
+	query := `
+		UPDATE delivery_jobs
+		SET status = 'done', locked_by = NULL, locked_at = NULL, updated_at = $3
+		WHERE id = $1 AND status = 'processing' AND locked_by = $2
+	`
+
+	result, err := r.pool.Exec(ctx, query, id, workerID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to complete delivery job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrLeaseLost
+	}
+
+	return nil
+}
+
+// Release returns a claimed job to the pending queue.
+func (r *DeliveryJobRepository) Release(ctx context.Context, id uuid.UUID, workerID string) error {
+	query := `
+		UPDATE delivery_jobs
+		SET status = 'pending', locked_by = NULL, locked_at = NULL, updated_at = $3
+		WHERE id = $1 AND status = 'processing' AND locked_by = $2
+	`
+
+	result, err := r.pool.Exec(ctx, query, id, workerID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to release delivery job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrLeaseLost
+	}
+
+	return nil
+}
+
+// Retry records a failed attempt and puts the job back in the queue for nextAttemptAt.
+func (r *DeliveryJobRepository) Retry(ctx context.Context, id uuid.UUID, workerID string, nextAttemptAt time.Time, lastError string) error {
+	query := `
+		UPDATE delivery_jobs
+		SET status = 'pending', next_attempt_at = $3, last_error = $4,
+		    locked_by = NULL, locked_at = NULL, updated_at = $5
+		WHERE id = $1 AND status = 'processing' AND locked_by = $2
+	`
+
+	result, err := r.pool.Exec(ctx, query, id, workerID, nextAttemptAt, lastError, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to schedule delivery retry: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrLeaseLost
+	}
+
+	return nil
+}
+
+// DeadLetter records the final error and parks the job until it is requeued.
+func (r *DeliveryJobRepository) DeadLetter(ctx context.Context, id uuid.UUID, workerID string, lastError string) error {
+	query := `
+		UPDATE delivery_jobs
+		SET status = 'dead_lettered', last_error = $3,
+		    locked_by = NULL, locked_at = NULL, updated_at = $4
+		WHERE id = $1 AND status = 'processing' AND locked_by = $2
+	`
+
+	result, err := r.pool.Exec(ctx, query, id, workerID, lastError, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter delivery job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrLeaseLost
+	}
+
+	return nil
+}
+
+// ListDeadLettered retrieves dead-lettered jobs, most recent first.
+func (r *DeliveryJobRepository) ListDeadLettered(ctx context.Context, opts domain.ListOptions) ([]*domain.DeliveryJob, int64, error) {
+	var total int64
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM delivery_jobs WHERE status = 'dead_lettered'`).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead-lettered jobs: %w", err)
+	}
+
+	query := `
+		SELECT ` + deliveryJobColumns("") + `
+		FROM delivery_jobs
+		WHERE status = 'dead_lettered'
+		ORDER BY updated_at DESC
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := r.pool.Query(ctx, query, opts.Limit, opts.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query dead-lettered jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*domain.DeliveryJob
+	for rows.Next() {
+		job, err := r.scanDeliveryJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating dead-lettered jobs: %w", err)
+	}
+
+	return jobs, total, nil
+}
+
+// Requeue resets a notification's dead-lettered job and the notification
+// status in one transaction, so the next worker poll delivers it again.
+func (r *DeliveryJobRepository) Requeue(ctx context.Context, notificationID uuid.UUID) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	now := time.Now()
+	result, err := tx.Exec(ctx, `
+		UPDATE delivery_jobs
+		SET status = 'pending', attempts = 0, next_attempt_at = $2, last_error = NULL, updated_at = $2
+		WHERE notification_id = $1 AND status = 'dead_lettered'
+	`, notificationID, now)
+	if err != nil {
+		return fmt.Errorf("failed to requeue delivery job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.NewErrNotFound("dead_lettered notification", notificationID.String())
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE notifications SET status = 'pending', updated_at = $2 WHERE id = $1
+	`, notificationID, now)
+	if err != nil {
+		return fmt.Errorf("failed to reset notification status: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseScheduled moves due scheduled jobs, and their notifications, to pending
+// in one statement. SKIP LOCKED keeps several instances' schedulers (and a
+// concurrent Cancel or Reschedule) from fighting over the same rows.
+func (r *DeliveryJobRepository) ReleaseScheduled(ctx context.Context, now time.Time, limit int) (int64, error) {
+	query := `
+		WITH due AS (
+			UPDATE delivery_jobs j
+			SET status = 'pending', next_attempt_at = $1, updated_at = $1
+			FROM (
+				SELECT id
+				FROM delivery_jobs
+				WHERE status = 'scheduled' AND next_attempt_at <= $1
+				ORDER BY next_attempt_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			) scheduled
+			WHERE j.id = scheduled.id
+			RETURNING j.notification_id
+		)
+		UPDATE notifications n
+		SET status = 'pending', updated_at = $1
+		FROM due
+		WHERE n.id = due.notification_id
+	`
+
+	result, err := r.pool.Exec(ctx, query, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release scheduled jobs: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// Cancel marks a scheduled notification and its job as cancelled.
+func (r *DeliveryJobRepository) Cancel(ctx context.Context, notificationID uuid.UUID) error {
+	return r.updateScheduled(ctx, notificationID, `
+		UPDATE delivery_jobs SET status = 'cancelled', updated_at = $2
+		WHERE notification_id = $1 AND status = 'scheduled'
+	`, `
+		UPDATE notifications SET status = 'cancelled', updated_at = $2 WHERE id = $1
+	`, time.Now())
+}
+
+// Reschedule moves a scheduled notification and its job to a new send time.
+func (r *DeliveryJobRepository) Reschedule(ctx context.Context, notificationID uuid.UUID, sendAt time.Time) error {
+	return r.updateScheduled(ctx, notificationID, `
+		UPDATE delivery_jobs SET next_attempt_at = $3, updated_at = $2
+		WHERE notification_id = $1 AND status = 'scheduled'
+	`, `
+		UPDATE notifications SET scheduled_at = $3, updated_at = $2 WHERE id = $1
+	`, time.Now(), sendAt)
+}
+
+// Fallback saves the notification's new channel and copy and requeues its job
+// in one transaction, so a crash can't leave the job pointing at the old channel.
+// The job is requeued first: if workerID lost it, the notification is left alone.
+func (r *DeliveryJobRepository) Fallback(ctx context.Context, n *domain.Notification, job *domain.DeliveryJob, workerID string, lastError string) error {
+	metadata, err := json.Marshal(n.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	now := time.Now()
+	result, err := tx.Exec(ctx, `
+		UPDATE delivery_jobs
+		SET payload = $3, status = 'pending', attempts = 0, next_attempt_at = $4, last_error = $5,
+		    locked_by = NULL, locked_at = NULL, updated_at = $4
+		WHERE id = $1 AND status = 'processing' AND locked_by = $2
+	`, job.ID, workerID, payload, now, lastError)
+	if err != nil {
+		return fmt.Errorf("failed to requeue delivery job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.ErrLeaseLost
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE notifications
+		SET type = $2, title = $3, body = $4, metadata = $5, status = $6, updated_at = $7
+		WHERE id = $1
+	`, n.ID, n.Type, n.Title, n.Body, metadata, n.Status, now)
+	if err != nil {
+		return fmt.Errorf("failed to update notification channel: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// updateScheduled runs jobQuery and then notificationQuery in one transaction.
+// jobQuery only matches scheduled jobs, so a job the scheduler already released
+// is reported as not found instead of being changed under a worker.
+func (r *DeliveryJobRepository) updateScheduled(ctx context.Context, notificationID uuid.UUID, jobQuery, notificationQuery string, args ...interface{}) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	args = append([]interface{}{notificationID}, args...)
+
+	result, err := tx.Exec(ctx, jobQuery, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update scheduled job: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return r.notScheduled(ctx, tx, notificationID)
+	}
+
+	if _, err := tx.Exec(ctx, notificationQuery, args...); err != nil {
+		return fmt.Errorf("failed to update scheduled notification: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// notScheduled explains why no scheduled job was found for a notification:
+// ErrNotFound if the notification doesn't exist, otherwise ErrConflict.
+func (r *DeliveryJobRepository) notScheduled(ctx context.Context, tx pgx.Tx, notificationID uuid.UUID) error {
+	var status domain.NotificationStatus
+	err := tx.QueryRow(ctx, `SELECT status FROM notifications WHERE id = $1`, notificationID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.NewErrNotFound("notification", notificationID.String())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get notification status: %w", err)
+	}
+
+	return domain.NewErrConflict(fmt.Sprintf("notification %s is %s, not scheduled", notificationID, status))
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// deliveryJobColumns returns the column list read by scanDeliveryJob.
+func deliveryJobColumns(prefix string) string {
+	columns := []string{
+		"id", "notification_id", "payload", "status", "attempts", "next_attempt_at",
+		"last_error", "locked_by", "locked_at", "created_at", "updated_at",
+	}
+	for i, c := range columns {
+		columns[i] = prefix + c
+	}
+	return strings.Join(columns, ", ")
+}
+
+// scanDeliveryJob scans a row into a DeliveryJob.
+func (r *DeliveryJobRepository) scanDeliveryJob(rows pgx.Rows) (*domain.DeliveryJob, error) {
+	var job domain.DeliveryJob
+	var payload []byte
+	var lastError, lockedBy *string
+
+	err := rows.Scan(
+		&job.ID,
+		&job.NotificationID,
+		&payload,
+		&job.Status,
+		&job.Attempts,
+		&job.NextAttemptAt,
+		&lastError,
+		&lockedBy,
+		&job.LockedAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan delivery job: %w", err)
+	}
+
+	if lastError != nil {
+		job.LastError = *lastError
+	}
+	if lockedBy != nil {
+		job.LockedBy = *lockedBy
+	}
+
+	if err := json.Unmarshal(payload, &job.Payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job payload: %w", err)
+	}
+
+	return &job, nil
+}
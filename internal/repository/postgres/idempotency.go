@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// IdempotencyRepository implements domain.IdempotencyRepository using PostgreSQL.
+type IdempotencyRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewIdempotencyRepository creates a new PostgreSQL idempotency key repository.
+func NewIdempotencyRepository(pool *pgxpool.Pool) *IdempotencyRepository {
+	return &IdempotencyRepository{pool: pool}
+}
+
+// Reserve claims a key for a new request. The primary key makes this safe
+// against concurrent requests: only one INSERT can win, and an expired
+// record is only taken over by the request that manages to update it. So is
+// a reservation for the same request that stayed in progress longer than
+// lockTimeout: the instance handling it died before completing or releasing it.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, record *domain.IdempotencyRecord, lockTimeout time.Duration) (*domain.IdempotencyRecord, error) {
+	query := `
+		INSERT INTO idempotency_keys (key, request_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			response = NULL,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < EXCLUDED.created_at
+			OR (idempotency_keys.status_code IS NULL
+				AND idempotency_keys.request_hash = EXCLUDED.request_hash
+				AND idempotency_keys.created_at < $5)
+	`
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+
+	lockedSince := record.CreatedAt.Add(-lockTimeout)
+	result, err := r.pool.Exec(ctx, query, record.Key, record.RequestHash, record.CreatedAt, record.ExpiresAt, lockedSince)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	if result.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	// Someone else holds the key - return their record
+	var existing domain.IdempotencyRecord
+	var statusCode *int
+
+	err = r.pool.QueryRow(ctx, `
+		SELECT key, request_hash, status_code, response, created_at, expires_at
+		FROM idempotency_keys
+		WHERE key = $1
+	`, record.Key).Scan(
+		&existing.Key,
+		&existing.RequestHash,
+		&statusCode,
+		&existing.Response,
+		&existing.CreatedAt,
+		&existing.ExpiresAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		// Released between our INSERT and SELECT; let the caller retry
+		return nil, fmt.Errorf("idempotency key %s was released concurrently", record.Key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	if statusCode != nil {
+		existing.StatusCode = *statusCode
+	}
+
+	return &existing, nil
+}
+
+// Complete stores the response for a reserved key.
+func (r *IdempotencyRepository) Complete(ctx context.Context, key string, statusCode int, response []byte) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $2, response = $3
+		WHERE key = $1
+	`
+
+	_, err := r.pool.Exec(ctx, query, key, statusCode, response)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// Release drops a reservation that never completed.
+func (r *IdempotencyRepository) Release(ctx context.Context, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL`
+
+	_, err := r.pool.Exec(ctx, query, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeExpired deletes up to limit records that expired before now and
+// returns how many were deleted.
+func (r *IdempotencyRepository) PurgeExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE key IN (
+			SELECT key FROM idempotency_keys
+			WHERE expires_at < $1
+			LIMIT $2
+		)
+	`
+
+	result, err := r.pool.Exec(ctx, query, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired idempotency keys: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -13,6 +13,10 @@ import (
 	"github.com/prepmyapp/notification/internal/domain"
 )
 
+// notificationColumns is the column list read by scanNotification and scanNotificationFromRows.
+const notificationColumns = `id, user_id, type, channel, title, body, metadata, status, idempotency_key,
+		scheduled_at, defer_reason, read_at, sent_at, created_at, updated_at`
+
 // NotificationRepository implements domain.NotificationRepository using PostgreSQL.
 type NotificationRepository struct {
 	pool *pgxpool.Pool
@@ -31,8 +35,8 @@ func (r *NotificationRepository) Create(ctx context.Context, n *domain.Notificat
 	}
 
 	query := `
-		INSERT INTO notifications (id, user_id, type, channel, title, body, metadata, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO notifications (id, user_id, type, channel, title, body, metadata, status, idempotency_key, idempotency_expires_at, scheduled_at, defer_reason, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, NULLIF($12, ''), $13, $14)
 	`
 
 	_, err = r.pool.Exec(ctx, query,
@@ -44,6 +48,10 @@ func (r *NotificationRepository) Create(ctx context.Context, n *domain.Notificat
 		n.Body,
 		metadata,
 		n.Status,
+		n.IdempotencyKey,
+		n.IdempotencyExpiresAt,
+		n.ScheduledAt,
+		n.DeferReason,
 		n.CreatedAt,
 		n.UpdatedAt,
 	)
@@ -58,7 +66,7 @@ func (r *NotificationRepository) Create(ctx context.Context, n *domain.Notificat
 // GetByID retrieves a notification by its ID.
 func (r *NotificationRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Notification, error) {
 	query := `
-		SELECT id, user_id, type, channel, title, body, metadata, status, read_at, sent_at, created_at, updated_at
+		SELECT ` + notificationColumns + `
 		FROM notifications
 		WHERE id = $1
 	`
@@ -67,10 +75,23 @@ func (r *NotificationRepository) GetByID(ctx context.Context, id uuid.UUID) (*do
 	return r.scanNotification(row)
 }
 
+// GetByIdempotencyKey retrieves the notification created for a user under an idempotency key.
+func (r *NotificationRepository) GetByIdempotencyKey(ctx context.Context, key string, userID uuid.UUID) (*domain.Notification, error) {
+	query := `
+		SELECT ` + notificationColumns + `
+		FROM notifications
+		WHERE idempotency_key = $1 AND user_id = $2
+	`
+
+	row := r.pool.QueryRow(ctx, query, key, userID)
+	return r.scanNotification(row)
+}
+
 // GetByUserID retrieves notifications for a specific user with pagination.
 func (r *NotificationRepository) GetByUserID(ctx context.Context, userID uuid.UUID, opts domain.ListOptions) ([]*domain.Notification, int64, error) {
 	// Build the query based on options
-	baseQuery := `FROM notifications WHERE user_id = $1`
+	// (scheduled notifications stay hidden from the user until they go out)
+	baseQuery := `FROM notifications WHERE user_id = $1 AND status NOT IN ('scheduled', 'cancelled')`
 	args := []interface{}{userID}
 	argIndex := 2
 
@@ -88,7 +109,7 @@ func (r *NotificationRepository) GetByUserID(ctx context.Context, userID uuid.UU
 
 	// Get paginated results
 	selectQuery := `
-		SELECT id, user_id, type, channel, title, body, metadata, status, read_at, sent_at, created_at, updated_at
+		SELECT ` + notificationColumns + `
 	` + baseQuery + fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
 
 	args = append(args, opts.Limit, opts.Offset)
@@ -115,11 +136,50 @@ func (r *NotificationRepository) GetByUserID(ctx context.Context, userID uuid.UU
 	return notifications, total, nil
 }
 
+// GetInAppSince retrieves a user's in-app notifications due after the cursor, oldest first.
+func (r *NotificationRepository) GetInAppSince(ctx context.Context, userID uuid.UUID, dueAt time.Time, afterID uuid.UUID, limit int) ([]*domain.Notification, error) {
+	// Pending notifications are included: they may have been broadcast before
+	// the worker recorded them as sent
+	query := `
+		SELECT ` + notificationColumns + `
+		FROM notifications
+		WHERE user_id = $1 AND type = 'in_app'
+			AND status NOT IN ('scheduled', 'cancelled')
+			AND (COALESCE(scheduled_at, created_at), id) > ($2, $3)
+		ORDER BY COALESCE(scheduled_at, created_at), id
+		LIMIT $4
+	`
+
+	rows, err := r.pool.Query(ctx, query, userID, dueAt, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query in-app notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []*domain.Notification
+	for rows.Next() {
+		n, err := r.scanNotificationFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
 // UpdateStatus updates the status of a notification.
 func (r *NotificationRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.NotificationStatus) error {
+	// A client can acknowledge an in-app notification, or a provider report
+	// an email's fate, before the worker records it as sent; that is kept
 	query := `
 		UPDATE notifications
-		SET status = $2, updated_at = $3, sent_at = CASE WHEN $4 = 'sent' THEN $3 ELSE sent_at END
+		SET status = CASE WHEN status IN ('delivered', 'bounced') AND $4 = 'sent' THEN status ELSE $2 END,
+		    updated_at = $3, sent_at = CASE WHEN $4 = 'sent' THEN $3 ELSE sent_at END
 		WHERE id = $1
 	`
 
@@ -173,12 +233,54 @@ func (r *NotificationRepository) MarkAllAsRead(ctx context.Context, userID uuid.
 	return nil
 }
 
+// MarkDelivered records that a client received the notification. Notifications
+// that are already delivered, failed or cancelled are left unchanged.
+func (r *NotificationRepository) MarkDelivered(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE notifications
+		SET status = 'delivered', sent_at = COALESCE(sent_at, $2), updated_at = $2
+		WHERE id = $1 AND status IN ('pending', 'sending', 'sent')
+	`
+
+	if _, err := r.pool.Exec(ctx, query, id, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark notification as delivered: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateMetadata merges values into the notification's metadata.
+func (r *NotificationRepository) UpdateMetadata(ctx context.Context, id uuid.UUID, values map[string]interface{}) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	query := `
+		UPDATE notifications
+		SET metadata = COALESCE(metadata, '{}'::jsonb) || $2::jsonb, updated_at = $3
+		WHERE id = $1
+	`
+
+	result, err := r.pool.Exec(ctx, query, id, data, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update notification metadata: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.NewErrNotFound("notification", id.String())
+	}
+
+	return nil
+}
+
 // GetUnreadCount returns the count of unread notifications for a user.
 func (r *NotificationRepository) GetUnreadCount(ctx context.Context, userID uuid.UUID) (int64, error) {
 	query := `
 		SELECT COUNT(*)
 		FROM notifications
 		WHERE user_id = $1 AND read_at IS NULL AND type = 'in_app'
+		  AND status NOT IN ('scheduled', 'cancelled')
 	`
 
 	var count int64
@@ -190,25 +292,41 @@ func (r *NotificationRepository) GetUnreadCount(ctx context.Context, userID uuid
 	return count, nil
 }
 
-// DeleteOlderThan removes notifications older than the specified number of days.
-func (r *NotificationRepository) DeleteOlderThan(ctx context.Context, days int) (int64, error) {
+// DeleteOlderThan removes notifications older than the specified number of
+// days and returns them. Their delivery jobs are removed by ON DELETE CASCADE.
+func (r *NotificationRepository) DeleteOlderThan(ctx context.Context, days int) ([]*domain.Notification, error) {
 	query := `
 		DELETE FROM notifications
 		WHERE created_at < NOW() - INTERVAL '1 day' * $1
-	`
+		RETURNING ` + notificationColumns
 
-	result, err := r.pool.Exec(ctx, query, days)
+	rows, err := r.pool.Query(ctx, query, days)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete old notifications: %w", err)
+		return nil, fmt.Errorf("failed to delete old notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []*domain.Notification
+	for rows.Next() {
+		n, err := r.scanNotificationFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
 	}
 
-	return result.RowsAffected(), nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to delete old notifications: %w", err)
+	}
+
+	return notifications, nil
 }
 
 // scanNotification scans a single row into a Notification.
 func (r *NotificationRepository) scanNotification(row pgx.Row) (*domain.Notification, error) {
 	var n domain.Notification
 	var metadata []byte
+	var idempotencyKey, deferReason *string
 
 	err := row.Scan(
 		&n.ID,
@@ -219,6 +337,9 @@ func (r *NotificationRepository) scanNotification(row pgx.Row) (*domain.Notifica
 		&n.Body,
 		&metadata,
 		&n.Status,
+		&idempotencyKey,
+		&n.ScheduledAt,
+		&deferReason,
 		&n.ReadAt,
 		&n.SentAt,
 		&n.CreatedAt,
@@ -236,6 +357,13 @@ func (r *NotificationRepository) scanNotification(row pgx.Row) (*domain.Notifica
 		n.Metadata = make(map[string]interface{})
 	}
 
+	if idempotencyKey != nil {
+		n.IdempotencyKey = *idempotencyKey
+	}
+	if deferReason != nil {
+		n.DeferReason = *deferReason
+	}
+
 	return &n, nil
 }
 
@@ -243,6 +371,7 @@ func (r *NotificationRepository) scanNotification(row pgx.Row) (*domain.Notifica
 func (r *NotificationRepository) scanNotificationFromRows(rows pgx.Rows) (*domain.Notification, error) {
 	var n domain.Notification
 	var metadata []byte
+	var idempotencyKey, deferReason *string
 
 	err := rows.Scan(
 		&n.ID,
@@ -253,6 +382,9 @@ func (r *NotificationRepository) scanNotificationFromRows(rows pgx.Rows) (*domai
 		&n.Body,
 		&metadata,
 		&n.Status,
+		&idempotencyKey,
+		&n.ScheduledAt,
+		&deferReason,
 		&n.ReadAt,
 		&n.SentAt,
 		&n.CreatedAt,
@@ -267,5 +399,12 @@ func (r *NotificationRepository) scanNotificationFromRows(rows pgx.Rows) (*domai
 		n.Metadata = make(map[string]interface{})
 	}
 
+	if idempotencyKey != nil {
+		n.IdempotencyKey = *idempotencyKey
+	}
+	if deferReason != nil {
+		n.DeferReason = *deferReason
+	}
+
 	return &n, nil
 }
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// NotificationEventRepository implements domain.NotificationEventRepository using PostgreSQL.
+type NotificationEventRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewNotificationEventRepository creates a new PostgreSQL notification event repository.
+func NewNotificationEventRepository(pool *pgxpool.Pool) *NotificationEventRepository {
+	return &NotificationEventRepository{pool: pool}
+}
+
+// Create saves an event, or returns ErrDuplicate if the provider reported it before.
+func (r *NotificationEventRepository) Create(ctx context.Context, e *domain.NotificationEvent) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	query := `
+		INSERT INTO notification_events (id, notification_id, provider, type, provider_event_id, recipient, reason, data, occurred_at, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10)
+		ON CONFLICT (provider, provider_event_id) WHERE provider_event_id IS NOT NULL DO NOTHING
+	`
+
+	result, err := r.pool.Exec(ctx, query,
+		e.ID,
+		e.NotificationID,
+		e.Provider,
+		e.Type,
+		e.ProviderEventID,
+		e.Recipient,
+		e.Reason,
+		data,
+		e.OccurredAt,
+		e.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create notification event: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.NewErrDuplicate("notification event", e.ProviderEventID)
+	}
+
+	return nil
+}
+
+// GetByNotificationID retrieves a notification's events, oldest first.
+func (r *NotificationEventRepository) GetByNotificationID(ctx context.Context, notificationID uuid.UUID) ([]*domain.NotificationEvent, error) {
+	query := `
+		SELECT id, notification_id, provider, type, COALESCE(provider_event_id, ''), COALESCE(recipient, ''),
+		       COALESCE(reason, ''), data, occurred_at, created_at
+		FROM notification_events
+		WHERE notification_id = $1
+		ORDER BY occurred_at, created_at
+	`
+
+	rows, err := r.pool.Query(ctx, query, notificationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*domain.NotificationEvent
+	for rows.Next() {
+		var e domain.NotificationEvent
+		var data []byte
+
+		err := rows.Scan(
+			&e.ID,
+			&e.NotificationID,
+			&e.Provider,
+			&e.Type,
+			&e.ProviderEventID,
+			&e.Recipient,
+			&e.Reason,
+			&data,
+			&e.OccurredAt,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification event: %w", err)
+		}
+
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &e.Data); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal event data: %w", err)
+			}
+		}
+
+		events = append(events, &e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notification events: %w", err)
+	}
+
+	return events, nil
+}
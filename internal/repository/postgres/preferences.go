@@ -27,7 +27,7 @@ func NewPreferencesRepository(pool *pgxpool.Pool) *PreferencesRepository {
 func (r *PreferencesRepository) Get(ctx context.Context, userID uuid.UUID) (*domain.NotificationPreferences, error) {
 	query := `
 		SELECT user_id, email_enabled, push_enabled, channels,
-		       quiet_hours_start, quiet_hours_end, created_at, updated_at
+		       quiet_hours_start, quiet_hours_end, timezone, locale, created_at, updated_at
 		FROM notification_preferences
 		WHERE user_id = $1
 	`
@@ -43,6 +43,8 @@ func (r *PreferencesRepository) Get(ctx context.Context, userID uuid.UUID) (*dom
 		&channels,
 		&quietStart,
 		&quietEnd,
+		&prefs.Timezone,
+		&prefs.Locale,
 		&prefs.CreatedAt,
 		&prefs.UpdatedAt,
 	)
@@ -77,17 +79,23 @@ func (r *PreferencesRepository) Upsert(ctx context.Context, prefs *domain.Notifi
 	query := `
 		INSERT INTO notification_preferences
 			(user_id, email_enabled, push_enabled, channels,
-			 quiet_hours_start, quiet_hours_end, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			 quiet_hours_start, quiet_hours_end, timezone, locale, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		ON CONFLICT (user_id) DO UPDATE SET
 			email_enabled = EXCLUDED.email_enabled,
 			push_enabled = EXCLUDED.push_enabled,
 			channels = EXCLUDED.channels,
 			quiet_hours_start = EXCLUDED.quiet_hours_start,
 			quiet_hours_end = EXCLUDED.quiet_hours_end,
+			timezone = EXCLUDED.timezone,
+			locale = EXCLUDED.locale,
 			updated_at = EXCLUDED.updated_at
 	`
 
+	if prefs.Timezone == "" {
+		prefs.Timezone = "UTC"
+	}
+
 	now := time.Now()
 	prefs.UpdatedAt = now
 	if prefs.CreatedAt.IsZero() {
@@ -101,6 +109,8 @@ func (r *PreferencesRepository) Upsert(ctx context.Context, prefs *domain.Notifi
 		channels,
 		prefs.QuietHoursStart,
 		prefs.QuietHoursEnd,
+		prefs.Timezone,
+		prefs.Locale,
 		prefs.CreatedAt,
 		prefs.UpdatedAt,
 	)
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// SuppressionRepository implements domain.SuppressionRepository using PostgreSQL.
+type SuppressionRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewSuppressionRepository creates a new PostgreSQL suppression repository.
+func NewSuppressionRepository(pool *pgxpool.Pool) *SuppressionRepository {
+	return &SuppressionRepository{pool: pool}
+}
+
+// Add suppresses an address, keeping the first entry if it already is.
+func (r *SuppressionRepository) Add(ctx context.Context, s *domain.Suppression) error {
+	query := `
+		INSERT INTO email_suppressions (email, reason, source, details, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		ON CONFLICT (email) DO NOTHING
+	`
+
+	_, err := r.pool.Exec(ctx, query,
+		domain.NormalizeEmail(s.Email),
+		s.Reason,
+		s.Source,
+		s.Details,
+		s.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add email suppression: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves the suppression for an address.
+func (r *SuppressionRepository) Get(ctx context.Context, email string) (*domain.Suppression, error) {
+	query := `
+		SELECT email, reason, source, COALESCE(details, ''), created_at
+		FROM email_suppressions
+		WHERE email = $1
+	`
+
+	var s domain.Suppression
+	err := r.pool.QueryRow(ctx, query, domain.NormalizeEmail(email)).Scan(
+		&s.Email,
+		&s.Reason,
+		&s.Source,
+		&s.Details,
+		&s.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, domain.NewErrNotFound("email_suppression", email)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email suppression: %w", err)
+	}
+
+	return &s, nil
+}
+
+// List retrieves suppressed addresses, most recent first.
+func (r *SuppressionRepository) List(ctx context.Context, opts domain.ListOptions) ([]*domain.Suppression, int64, error) {
+	var total int64
+	if err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM email_suppressions`).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count email suppressions: %w", err)
+	}
+
+	query := `
+		SELECT email, reason, source, COALESCE(details, ''), created_at
+		FROM email_suppressions
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := r.pool.Query(ctx, query, opts.Limit, opts.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query email suppressions: %w", err)
+	}
+	defer rows.Close()
+
+	var suppressions []*domain.Suppression
+	for rows.Next() {
+		var s domain.Suppression
+		if err := rows.Scan(&s.Email, &s.Reason, &s.Source, &s.Details, &s.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan email suppression: %w", err)
+		}
+		suppressions = append(suppressions, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating email suppressions: %w", err)
+	}
+
+	return suppressions, total, nil
+}
+
+// Remove lifts an address's suppression.
+func (r *SuppressionRepository) Remove(ctx context.Context, email string) error {
+	result, err := r.pool.Exec(ctx, `DELETE FROM email_suppressions WHERE email = $1`, domain.NormalizeEmail(email))
+	if err != nil {
+		return fmt.Errorf("failed to remove email suppression: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.NewErrNotFound("email_suppression", email)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// workflowRunColumns is the column list read by scanWorkflowRun.
+const workflowRunColumns = `id, workflow, user_id, input, status, step, next_run_at, notification_ids,
+		outcome, created_at, updated_at`
+
+// WorkflowRunRepository implements domain.WorkflowRunRepository using PostgreSQL.
+type WorkflowRunRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewWorkflowRunRepository creates a new PostgreSQL workflow run repository.
+func NewWorkflowRunRepository(pool *pgxpool.Pool) *WorkflowRunRepository {
+	return &WorkflowRunRepository{pool: pool}
+}
+
+// Create saves a new workflow run.
+func (r *WorkflowRunRepository) Create(ctx context.Context, run *domain.WorkflowRun) error {
+	input, err := json.Marshal(run.Input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow input: %w", err)
+	}
+
+	notificationIDs, err := json.Marshal(run.NotificationIDs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification IDs: %w", err)
+	}
+
+	query := `
+		INSERT INTO workflow_runs (id, workflow, user_id, input, status, step, next_run_at, notification_ids, outcome, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11)
+	`
+
+	_, err = r.pool.Exec(ctx, query,
+		run.ID,
+		run.Workflow,
+		run.UserID,
+		input,
+		run.Status,
+		run.Step,
+		run.NextRunAt,
+		notificationIDs,
+		run.Outcome,
+		run.CreatedAt,
+		run.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create workflow run: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID retrieves a workflow run by its ID.
+func (r *WorkflowRunRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.WorkflowRun, error) {
+	query := `
+		SELECT ` + workflowRunColumns + `
+		FROM workflow_runs
+		WHERE id = $1
+	`
+
+	run, err := r.scanWorkflowRun(r.pool.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, domain.NewErrNotFound("workflow run", id.String())
+	}
+	return run, err
+}
+
+// ClaimDue leases due runs to the caller by moving next_run_at past the lease.
+// SKIP LOCKED keeps several instances' engines from claiming the same run.
+func (r *WorkflowRunRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WorkflowRun, error) {
+	query := `
+		UPDATE workflow_runs w
+		SET next_run_at = $2, updated_at = $1
+		FROM (
+			SELECT id
+			FROM workflow_runs
+			WHERE status = 'running' AND next_run_at <= $1
+			ORDER BY next_run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE w.id = due.id
+		RETURNING w.id, w.workflow, w.user_id, w.input, w.status, w.step, w.next_run_at, w.notification_ids,
+		          w.outcome, w.created_at, w.updated_at
+	`
+
+	rows, err := r.pool.Query(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim workflow runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*domain.WorkflowRun
+	for rows.Next() {
+		run, err := r.scanWorkflowRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating workflow runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// Update saves a run's progress. Runs cancelled in the meantime stay cancelled.
+func (r *WorkflowRunRepository) Update(ctx context.Context, run *domain.WorkflowRun) error {
+	notificationIDs, err := json.Marshal(run.NotificationIDs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification IDs: %w", err)
+	}
+
+	query := `
+		UPDATE workflow_runs
+		SET status = $2, step = $3, next_run_at = $4, notification_ids = $5, outcome = NULLIF($6, ''), updated_at = $7
+		WHERE id = $1 AND status = 'running'
+	`
+
+	_, err = r.pool.Exec(ctx, query,
+		run.ID,
+		run.Status,
+		run.Step,
+		run.NextRunAt,
+		notificationIDs,
+		run.Outcome,
+		time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update workflow run: %w", err)
+	}
+
+	return nil
+}
+
+// Cancel stops a running workflow run.
+func (r *WorkflowRunRepository) Cancel(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE workflow_runs
+		SET status = 'cancelled', outcome = 'cancelled by caller', updated_at = $2
+		WHERE id = $1 AND status = 'running'
+	`
+
+	result, err := r.pool.Exec(ctx, query, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to cancel workflow run: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return domain.NewErrNotFound("running workflow run", id.String())
+	}
+
+	return nil
+}
+
+// scanWorkflowRun scans a row (from QueryRow or Query) into a WorkflowRun.
+// pgx.ErrNoRows is returned unwrapped so callers can map it to ErrNotFound.
+func (r *WorkflowRunRepository) scanWorkflowRun(row pgx.Row) (*domain.WorkflowRun, error) {
+	var run domain.WorkflowRun
+	var input, notificationIDs []byte
+	var outcome *string
+
+	err := row.Scan(
+		&run.ID,
+		&run.Workflow,
+		&run.UserID,
+		&input,
+		&run.Status,
+		&run.Step,
+		&run.NextRunAt,
+		&notificationIDs,
+		&outcome,
+		&run.CreatedAt,
+		&run.UpdatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan workflow run: %w", err)
+	}
+
+	if err := json.Unmarshal(input, &run.Input); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal workflow input: %w", err)
+	}
+	if err := json.Unmarshal(notificationIDs, &run.NotificationIDs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal notification IDs: %w", err)
+	}
+
+	if outcome != nil {
+		run.Outcome = *outcome
+	}
+
+	return &run, nil
+}
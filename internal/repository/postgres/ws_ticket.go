@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// WebSocketTicketRepository implements domain.WebSocketTicketRepository using PostgreSQL.
+type WebSocketTicketRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewWebSocketTicketRepository creates a new PostgreSQL WebSocket ticket repository.
+func NewWebSocketTicketRepository(pool *pgxpool.Pool) *WebSocketTicketRepository {
+	return &WebSocketTicketRepository{pool: pool}
+}
+
+// Create stores a ticket, clearing out expired ones on the way.
+func (r *WebSocketTicketRepository) Create(ctx context.Context, ticketHash string, userID uuid.UUID, expiresAt time.Time) error {
+	now := time.Now()
+
+	if _, err := r.pool.Exec(ctx, `DELETE FROM ws_tickets WHERE expires_at < $1`, now); err != nil {
+		return fmt.Errorf("failed to delete expired websocket tickets: %w", err)
+	}
+
+	query := `
+		INSERT INTO ws_tickets (ticket_hash, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	if _, err := r.pool.Exec(ctx, query, ticketHash, userID, now, expiresAt); err != nil {
+		return fmt.Errorf("failed to create websocket ticket: %w", err)
+	}
+
+	return nil
+}
+
+// Consume deletes a ticket and returns its user. Deleting and reading in one
+// statement makes a ticket usable once, even by concurrent connections.
+func (r *WebSocketTicketRepository) Consume(ctx context.Context, ticketHash string) (uuid.UUID, error) {
+	query := `
+		DELETE FROM ws_tickets
+		WHERE ticket_hash = $1
+		RETURNING user_id, expires_at >= $2
+	`
+
+	var userID uuid.UUID
+	var valid bool
+	err := r.pool.QueryRow(ctx, query, ticketHash, time.Now()).Scan(&userID, &valid)
+	if err == pgx.ErrNoRows {
+		return uuid.Nil, domain.NewErrNotFound("websocket_ticket", "")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to consume websocket ticket: %w", err)
+	}
+
+	if !valid {
+		// Expired tickets are deleted all the same
+		return uuid.Nil, domain.NewErrNotFound("websocket_ticket", "")
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// EmailEventService records what email providers report about sent
+// notifications (delivered, bounced, opened, ...), updates the notifications'
+// status to match and suppresses addresses that must not be emailed again.
+type EmailEventService struct {
+	notificationRepo domain.NotificationRepository
+	eventRepo        domain.NotificationEventRepository
+	suppressionRepo  domain.SuppressionRepository
+}
+
+// NewEmailEventService creates a new email event service.
+func NewEmailEventService(notificationRepo domain.NotificationRepository, eventRepo domain.NotificationEventRepository, suppressionRepo domain.SuppressionRepository) *EmailEventService {
+	return &EmailEventService{
+		notificationRepo: notificationRepo,
+		eventRepo:        eventRepo,
+		suppressionRepo:  suppressionRepo,
+	}
+}
+
+// suppressionReasons lists the events after which the recipient's address is
+// suppressed. Blocked and dropped emails don't say the address is bad.
+var suppressionReasons = map[domain.NotificationEventType]domain.SuppressionReason{
+	domain.NotificationEventBounced:     domain.SuppressionReasonBounce,
+	domain.NotificationEventSpamReport:  domain.SuppressionReasonSpamReport,
+	domain.NotificationEventUnsubscribe: domain.SuppressionReasonUnsubscribe,
+}
+
+// Record applies an event to its notification and adds it to the
+// notification's history:
+//
+//   - delivered, opened and clicked mark it delivered (an open implies delivery,
+//     and events may arrive out of order)
+//   - bounced, blocked and dropped mark it bounced
+//   - spam reports and unsubscribes leave it as is
+//
+// Hard bounces, spam reports and unsubscribes also suppress the recipient's
+// address, so it isn't emailed again.
+//
+// Providers redeliver events: applying one again changes nothing, and it is
+// stored only once. Returns ErrNotFound if the notification doesn't exist
+// (any more).
+func (s *EmailEventService) Record(ctx context.Context, event *domain.NotificationEvent) error {
+	if _, err := s.notificationRepo.GetByID(ctx, event.NotificationID); err != nil {
+		return err
+	}
+
+	// Status first: if it fails, the provider retries and the event is stored then
+	switch event.Type {
+	case domain.NotificationEventDelivered, domain.NotificationEventOpened, domain.NotificationEventClicked:
+		if err := s.notificationRepo.MarkDelivered(ctx, event.NotificationID); err != nil {
+			return err
+		}
+	case domain.NotificationEventBounced, domain.NotificationEventBlocked, domain.NotificationEventDropped:
+		if err := s.notificationRepo.UpdateStatus(ctx, event.NotificationID, domain.NotificationStatusBounced); err != nil {
+			return err
+		}
+		log.Printf("[EmailEvents] Notification %s %s: %s", event.NotificationID, event.Type, event.Reason)
+	}
+
+	if reason, ok := suppressionReasons[event.Type]; ok && event.Recipient != "" {
+		suppression := domain.NewSuppression(event.Recipient, reason, event.Provider)
+		suppression.Details = event.Reason
+		if err := s.suppressionRepo.Add(ctx, suppression); err != nil {
+			return err
+		}
+		log.Printf("[EmailEvents] Suppressed %s after %s", suppression.Email, event.Type)
+	}
+
+	if err := s.eventRepo.Create(ctx, event); err != nil {
+		if _, ok := err.(*domain.ErrDuplicate); ok {
+			return nil
+		}
+		return fmt.Errorf("failed to record %s event: %w", event.Type, err)
+	}
+
+	return nil
+}
+
+// History returns the events reported about a notification, oldest first.
+// Returns ErrNotFound if the notification doesn't exist.
+func (s *EmailEventService) History(ctx context.Context, notificationID uuid.UUID) ([]*domain.NotificationEvent, error) {
+	if _, err := s.notificationRepo.GetByID(ctx, notificationID); err != nil {
+		return nil, err
+	}
+
+	return s.eventRepo.GetByNotificationID(ctx, notificationID)
+}
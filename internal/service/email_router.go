@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// EmailProvider is one of the providers an EmailRouter sends through.
+type EmailProvider struct {
+	Name     string
+	Sender   EmailSender
+	Priority int // Lower priorities are tried first; the next one only on failure
+	Weight   int // Share of traffic among providers of the same priority
+}
+
+// EmailRouterConfig configures the circuit breaker kept per provider.
+type EmailRouterConfig struct {
+	FailureThreshold int           // Consecutive failures that open a provider's circuit
+	Cooldown         time.Duration // How long an open circuit skips the provider before a trial send
+}
+
+// DefaultEmailRouterConfig returns sensible defaults: a provider is skipped
+// for 30 seconds after 5 failures in a row.
+func DefaultEmailRouterConfig() EmailRouterConfig {
+	return EmailRouterConfig{
+		FailureThreshold: 5,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// EmailRouter is an EmailSender that spreads email over several providers
+// and fails over between them, so one provider's outage doesn't stop email.
+//
+// Each send picks the providers of the lowest priority in random order
+// (weighted by Weight), then those of the next priority, and so on. A
+// retryable error moves on to the next provider; a permanent one (such as a
+// rejected address) is returned right away, as another provider would reject
+// it too.
+//
+// Every provider has a circuit breaker. After FailureThreshold retryable
+// errors in a row its circuit opens and it is skipped. Once Cooldown has
+// passed it is half-open: a single trial send goes through, and closes the
+// circuit again if it succeeds or reopens it if it fails.
+type EmailRouter struct {
+	providers []*routedProvider // Sorted by priority
+	cfg       EmailRouterConfig
+
+	mu sync.Mutex // Guards the breakers
+}
+
+// routedProvider is a provider and the state of its circuit breaker.
+type routedProvider struct {
+	EmailProvider
+
+	failures int       // Consecutive retryable failures
+	openedAt time.Time // When the circuit opened; zero while closed
+	trial    bool      // A half-open trial send is in flight
+}
+
+// NewEmailRouter creates a router over providers.
+func NewEmailRouter(providers []EmailProvider, cfg EmailRouterConfig) *EmailRouter {
+	defaults := DefaultEmailRouterConfig()
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaults.FailureThreshold
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaults.Cooldown
+	}
+
+	routed := make([]*routedProvider, 0, len(providers))
+	for _, p := range providers {
+		if p.Weight <= 0 {
+			p.Weight = 1
+		}
+		routed = append(routed, &routedProvider{EmailProvider: p})
+	}
+	sort.SliceStable(routed, func(i, j int) bool {
+		return routed[i].Priority < routed[j].Priority
+	})
+
+	return &EmailRouter{providers: routed, cfg: cfg}
+}
+
+// SendEmail sends msg through the first provider that accepts it. The
+// receipt names that provider.
+func (r *EmailRouter) SendEmail(ctx context.Context, msg *domain.EmailMessage) (*domain.EmailReceipt, error) {
+	return r.route(ctx, func(sender EmailSender) (*domain.EmailReceipt, error) {
+		return sender.SendEmail(ctx, msg)
+	})
+}
+
+// SendTemplate sends a template email through the first provider that accepts
+// it. Every provider must know templateID.
+func (r *EmailRouter) SendTemplate(ctx context.Context, to, templateID string, data map[string]interface{}) error {
+	_, err := r.route(ctx, func(sender EmailSender) (*domain.EmailReceipt, error) {
+		return &domain.EmailReceipt{}, sender.SendTemplate(ctx, to, templateID, data)
+	})
+	return err
+}
+
+// route calls send with each available provider in turn until one succeeds.
+func (r *EmailRouter) route(ctx context.Context, send func(EmailSender) (*domain.EmailReceipt, error)) (*domain.EmailReceipt, error) {
+	var lastErr error
+	for _, p := range r.order() {
+		if !r.allow(p) {
+			continue
+		}
+
+		receipt, err := send(p.Sender)
+		r.record(p, err)
+		if err == nil {
+			receipt.Provider = p.Name
+			return receipt, nil
+		}
+		if !domain.IsRetryable(err) {
+			return nil, err
+		}
+
+		log.Printf("[EmailRouter] %s failed, trying next provider: %v", p.Name, err)
+		lastErr = err
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if lastErr == nil {
+		// Every circuit is open; retry once a provider has cooled down
+		return nil, domain.NewRetryableError(fmt.Errorf("no email provider available"))
+	}
+	return nil, lastErr
+}
+
+// order returns the providers in the order to try them for one send:
+// by priority, and by weighted random order within a priority.
+func (r *EmailRouter) order() []*routedProvider {
+	ordered := make([]*routedProvider, 0, len(r.providers))
+	for start := 0; start < len(r.providers); {
+		end := start
+		for end < len(r.providers) && r.providers[end].Priority == r.providers[start].Priority {
+			end++
+		}
+		ordered = append(ordered, weightedShuffle(r.providers[start:end])...)
+		start = end
+	}
+	return ordered
+}
+
+// weightedShuffle orders providers randomly, each pick favouring heavier ones.
+func weightedShuffle(providers []*routedProvider) []*routedProvider {
+	remaining := append([]*routedProvider(nil), providers...)
+	shuffled := make([]*routedProvider, 0, len(providers))
+
+	for len(remaining) > 0 {
+		total := 0
+		for _, p := range remaining {
+			total += p.Weight
+		}
+
+		pick := rand.IntN(total)
+		i := 0
+		for ; pick >= remaining[i].Weight; i++ {
+			pick -= remaining[i].Weight
+		}
+
+		shuffled = append(shuffled, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return shuffled
+}
+
+// allow reports whether p's circuit lets a send through, claiming the trial
+// send if the circuit is half-open.
+func (r *EmailRouter) allow(p *routedProvider) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p.openedAt.IsZero() {
+		return true // Closed
+	}
+	if time.Since(p.openedAt) < r.cfg.Cooldown || p.trial {
+		return false // Open, or half-open with a trial already under way
+	}
+
+	p.trial = true
+	return true
+}
+
+// record updates p's circuit with the outcome of a send.
+func (r *EmailRouter) record(p *routedProvider, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wasTrial := p.trial
+	p.trial = false
+
+	// Permanent errors are about the email, not the provider
+	if err == nil || !domain.IsRetryable(err) {
+		if !p.openedAt.IsZero() {
+			log.Printf("[EmailRouter] %s recovered, closing its circuit", p.Name)
+		}
+		p.failures = 0
+		p.openedAt = time.Time{}
+		return
+	}
+
+	p.failures++
+	if wasTrial || (p.openedAt.IsZero() && p.failures >= r.cfg.FailureThreshold) {
+		p.openedAt = time.Now()
+		log.Printf("[EmailRouter] %s failed %d times in a row, skipping it for %s",
+			p.Name, p.failures, r.cfg.Cooldown)
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+var errProviderDown = domain.NewRetryableError(errors.New("provider unavailable"))
+
+func TestEmailRouterFailover(t *testing.T) {
+	tests := []struct {
+		name         string
+		primaryErr   error
+		secondaryErr error
+		wantProvider string
+		wantErr      bool
+		wantCalls    [2]int
+	}{
+		{"primary succeeds", nil, nil, "primary", false, [2]int{1, 0}},
+		{"primary down", errProviderDown, nil, "secondary", false, [2]int{1, 1}},
+		{"both down", errProviderDown, errProviderDown, "", true, [2]int{1, 1}},
+		{"address rejected", domain.NewPermanentError(errors.New("invalid recipient")), nil, "", true, [2]int{1, 0}},
+		{"unclassified error is permanent", errors.New("unexpected response"), nil, "", true, [2]int{1, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &fakeEmailSender{name: "primary", err: tt.primaryErr}
+			secondary := &fakeEmailSender{name: "secondary", err: tt.secondaryErr}
+			router := NewEmailRouter([]EmailProvider{
+				{Name: "secondary", Sender: secondary, Priority: 1},
+				{Name: "primary", Sender: primary, Priority: 0},
+			}, DefaultEmailRouterConfig())
+
+			receipt, err := router.SendEmail(context.Background(), &domain.EmailMessage{To: "user@example.com"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendEmail error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil && receipt.Provider != tt.wantProvider {
+				t.Errorf("sent through %q, want %q", receipt.Provider, tt.wantProvider)
+			}
+			if calls := [2]int{primary.callCount(), secondary.callCount()}; calls != tt.wantCalls {
+				t.Errorf("calls (primary, secondary) = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestEmailRouterCircuitBreaker(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+
+	primary := &fakeEmailSender{name: "primary", err: errProviderDown}
+	secondary := &fakeEmailSender{name: "secondary"}
+	router := NewEmailRouter([]EmailProvider{
+		{Name: "primary", Sender: primary, Priority: 0},
+		{Name: "secondary", Sender: secondary, Priority: 1},
+	}, EmailRouterConfig{FailureThreshold: 3, Cooldown: cooldown})
+
+	send := func() string {
+		t.Helper()
+		receipt, err := router.SendEmail(context.Background(), &domain.EmailMessage{To: "user@example.com"})
+		if err != nil {
+			t.Fatalf("SendEmail: %v", err)
+		}
+		return receipt.Provider
+	}
+
+	// Closed: every send tries the primary first until the threshold
+	for i := 0; i < 3; i++ {
+		send()
+	}
+	if calls := primary.callCount(); calls != 3 {
+		t.Fatalf("primary called %d times before its circuit opened, want 3", calls)
+	}
+
+	// Open: the primary is skipped
+	if provider := send(); provider != "secondary" {
+		t.Errorf("sent through %q with the primary's circuit open, want secondary", provider)
+	}
+	if calls := primary.callCount(); calls != 3 {
+		t.Errorf("primary called %d times with its circuit open, want 3", calls)
+	}
+
+	// Half-open: one trial send, which fails and reopens the circuit
+	time.Sleep(2 * cooldown)
+	send()
+	send()
+	if calls := primary.callCount(); calls != 4 {
+		t.Errorf("primary called %d times after a failed trial, want 4", calls)
+	}
+
+	// Half-open again: the trial succeeds and closes the circuit
+	time.Sleep(2 * cooldown)
+	primary.mu.Lock()
+	primary.err = nil
+	primary.mu.Unlock()
+	for i := 0; i < 3; i++ {
+		if provider := send(); provider != "primary" {
+			t.Errorf("send %d went through %q after the primary recovered, want primary", i+1, provider)
+		}
+	}
+}
+
+func TestEmailRouterAllCircuitsOpen(t *testing.T) {
+	sender := &fakeEmailSender{name: "only", err: errProviderDown}
+	router := NewEmailRouter([]EmailProvider{{Name: "only", Sender: sender}},
+		EmailRouterConfig{FailureThreshold: 1, Cooldown: time.Hour})
+
+	router.SendEmail(context.Background(), &domain.EmailMessage{To: "user@example.com"})
+	_, err := router.SendEmail(context.Background(), &domain.EmailMessage{To: "user@example.com"})
+	if err == nil || !domain.IsRetryable(err) {
+		t.Errorf("SendEmail with every circuit open = %v, want a retryable error", err)
+	}
+	if calls := sender.callCount(); calls != 1 {
+		t.Errorf("provider called %d times, want 1", calls)
+	}
+}
+
+func TestEmailRouterWeightedOrder(t *testing.T) {
+	heavy := &fakeEmailSender{name: "heavy"}
+	light := &fakeEmailSender{name: "light"}
+	fallback := &fakeEmailSender{name: "fallback"}
+	router := NewEmailRouter([]EmailProvider{
+		{Name: "fallback", Sender: fallback, Priority: 1, Weight: 100},
+		{Name: "heavy", Sender: heavy, Weight: 3},
+		{Name: "light", Sender: light, Weight: 1},
+	}, DefaultEmailRouterConfig())
+
+	const sends = 4000
+	for i := 0; i < sends; i++ {
+		if _, err := router.SendEmail(context.Background(), &domain.EmailMessage{To: "user@example.com"}); err != nil {
+			t.Fatalf("SendEmail: %v", err)
+		}
+	}
+
+	if calls := fallback.callCount(); calls != 0 {
+		t.Errorf("lower priority provider called %d times while the others were up, want 0", calls)
+	}
+	// 3:1 weights should send about 75% through heavy
+	if share := float64(heavy.callCount()) / sends; share < 0.70 || share > 0.80 {
+		t.Errorf("heavy provider got %.0f%% of sends, want about 75%%", share*100)
+	}
+	if heavy.callCount()+light.callCount() != sends {
+		t.Errorf("heavy and light sent %d emails, want %d", heavy.callCount()+light.callCount(), sends)
+	}
+}
+
+func TestWeightedShuffleKeepsEveryProvider(t *testing.T) {
+	providers := []*routedProvider{
+		{EmailProvider: EmailProvider{Name: "a", Weight: 1}},
+		{EmailProvider: EmailProvider{Name: "b", Weight: 5}},
+		{EmailProvider: EmailProvider{Name: "c", Weight: 2}},
+	}
+
+	shuffled := weightedShuffle(providers)
+	seen := make(map[string]bool)
+	for _, p := range shuffled {
+		seen[p.Name] = true
+	}
+	if len(shuffled) != 3 || len(seen) != 3 {
+		t.Errorf("weightedShuffle returned %d providers (%d distinct), want 3", len(shuffled), len(seen))
+	}
+	if providers[0].Name != "a" || providers[1].Name != "b" || providers[2].Name != "c" {
+		t.Error("weightedShuffle reordered its input")
+	}
+}
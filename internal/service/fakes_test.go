@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// The fakes embed the repository interfaces, so calling a method a test
+// doesn't expect panics instead of silently succeeding.
+
+// fakeNotificationRepo keeps notifications in memory and records status changes.
+type fakeNotificationRepo struct {
+	domain.NotificationRepository
+
+	mu            sync.Mutex
+	notifications map[uuid.UUID]*domain.Notification
+	statuses      []domain.NotificationStatus
+}
+
+func newFakeNotificationRepo(notifications ...*domain.Notification) *fakeNotificationRepo {
+	r := &fakeNotificationRepo{notifications: make(map[uuid.UUID]*domain.Notification)}
+	for _, n := range notifications {
+		r.notifications[n.ID] = n
+	}
+	return r
+}
+
+func (r *fakeNotificationRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.notifications[id]
+	if !ok {
+		return nil, domain.NewErrNotFound("notification", id.String())
+	}
+	copied := *n
+	return &copied, nil
+}
+
+func (r *fakeNotificationRepo) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.NotificationStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, status)
+	if n, ok := r.notifications[id]; ok {
+		n.Status = status
+	}
+	return nil
+}
+
+func (r *fakeNotificationRepo) UpdateMetadata(ctx context.Context, id uuid.UUID, values map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n, ok := r.notifications[id]; ok {
+		if n.Metadata == nil {
+			n.Metadata = make(map[string]interface{})
+		}
+		for k, v := range values {
+			n.Metadata[k] = v
+		}
+	}
+	return nil
+}
+
+func (r *fakeNotificationRepo) GetByIdempotencyKey(ctx context.Context, key string, userID uuid.UUID) (*domain.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, n := range r.notifications {
+		if n.IdempotencyKey == key && n.UserID == userID {
+			copied := *n
+			return &copied, nil
+		}
+	}
+	return nil, domain.NewErrNotFound("notification", key)
+}
+
+func (r *fakeNotificationRepo) MarkAsRead(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, ok := r.notifications[id]
+	if !ok {
+		return domain.NewErrNotFound("notification", id.String())
+	}
+	now := time.Now()
+	n.ReadAt = &now
+	return nil
+}
+
+func (r *fakeNotificationRepo) MarkAllAsRead(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, n := range r.notifications {
+		if n.UserID == userID && n.ReadAt == nil {
+			n.ReadAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeNotificationRepo) GetUnreadCount(ctx context.Context, userID uuid.UUID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, n := range r.notifications {
+		if n.UserID == userID && n.Type == domain.NotificationTypeInApp && n.ReadAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeNotificationRepo) DeleteOlderThan(ctx context.Context, days int) ([]*domain.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cutoff := time.Now().AddDate(0, 0, -days)
+	var deleted []*domain.Notification
+	for id, n := range r.notifications {
+		if n.CreatedAt.Before(cutoff) {
+			deleted = append(deleted, n)
+			delete(r.notifications, id)
+		}
+	}
+	return deleted, nil
+}
+
+// status returns a notification's current status.
+func (r *fakeNotificationRepo) status(id uuid.UUID) domain.NotificationStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.notifications[id].Status
+}
+
+// fakeJobRepo records what the worker did with each job. With leaseLost set,
+// it acts as if another worker had claimed every job in the meantime.
+type fakeJobRepo struct {
+	domain.DeliveryJobRepository
+
+	mu           sync.Mutex
+	leaseLost    bool
+	enqueueErr   error
+	queued       []*domain.Notification
+	enqueued     []*domain.DeliveryJob
+	completed    int
+	retried      int
+	deadLettered int
+}
+
+// Enqueue records the notification and its job, rejecting a second
+// notification for the same user and idempotency key like the unique index.
+func (r *fakeJobRepo) Enqueue(ctx context.Context, notification *domain.Notification, job *domain.DeliveryJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.enqueueErr != nil {
+		return r.enqueueErr
+	}
+	for _, n := range r.queued {
+		if notification.IdempotencyKey != "" && n.IdempotencyKey == notification.IdempotencyKey && n.UserID == notification.UserID {
+			return domain.NewErrDuplicate("notification", notification.IdempotencyKey)
+		}
+	}
+	r.queued = append(r.queued, notification)
+	r.enqueued = append(r.enqueued, job)
+	return nil
+}
+
+func (r *fakeJobRepo) Complete(ctx context.Context, id uuid.UUID, workerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.leaseLost {
+		return domain.ErrLeaseLost
+	}
+	r.completed++
+	return nil
+}
+
+func (r *fakeJobRepo) Retry(ctx context.Context, id uuid.UUID, workerID string, nextAttemptAt time.Time, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.leaseLost {
+		return domain.ErrLeaseLost
+	}
+	r.retried++
+	return nil
+}
+
+func (r *fakeJobRepo) DeadLetter(ctx context.Context, id uuid.UUID, workerID string, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.leaseLost {
+		return domain.ErrLeaseLost
+	}
+	r.deadLettered++
+	return nil
+}
+
+// fakeSuppressionRepo is an in-memory suppression list.
+type fakeSuppressionRepo struct {
+	domain.SuppressionRepository
+
+	mu           sync.Mutex
+	suppressions map[string]*domain.Suppression
+}
+
+func newFakeSuppressionRepo(suppressions ...*domain.Suppression) *fakeSuppressionRepo {
+	r := &fakeSuppressionRepo{suppressions: make(map[string]*domain.Suppression)}
+	for _, s := range suppressions {
+		r.suppressions[s.Email] = s
+	}
+	return r
+}
+
+func (r *fakeSuppressionRepo) Get(ctx context.Context, email string) (*domain.Suppression, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.suppressions[domain.NormalizeEmail(email)]
+	if !ok {
+		return nil, domain.NewErrNotFound("email_suppression", email)
+	}
+	return s, nil
+}
+
+// fakeEmailSender counts the emails it is asked to send and fails with err.
+type fakeEmailSender struct {
+	mu    sync.Mutex
+	name  string
+	err   error
+	calls int
+}
+
+func (s *fakeEmailSender) SendEmail(ctx context.Context, msg *domain.EmailMessage) (*domain.EmailReceipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &domain.EmailReceipt{Provider: s.name}, nil
+}
+
+func (s *fakeEmailSender) SendTemplate(ctx context.Context, to, templateID string, data map[string]interface{}) error {
+	_, err := s.SendEmail(ctx, &domain.EmailMessage{To: to})
+	return err
+}
+
+// callCount returns how many emails the sender was asked to send.
+func (s *fakeEmailSender) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// fakePushSender fails every push with err.
+type fakePushSender struct {
+	err error
+}
+
+func (s *fakePushSender) Send(ctx context.Context, token, title, body string, data map[string]interface{}) error {
+	return s.err
+}
+
+func (s *fakePushSender) SendToUser(ctx context.Context, userID uuid.UUID, title, body string, data map[string]interface{}) error {
+	return s.err
+}
+
+// fakePreferencesRepo returns the same preferences for every user and counts
+// the times they are saved.
+type fakePreferencesRepo struct {
+	domain.PreferencesRepository
+
+	prefs   *domain.NotificationPreferences
+	upserts int
+}
+
+func (r *fakePreferencesRepo) Get(ctx context.Context, userID uuid.UUID) (*domain.NotificationPreferences, error) {
+	return r.prefs, nil
+}
+
+func (r *fakePreferencesRepo) Upsert(ctx context.Context, prefs *domain.NotificationPreferences) error {
+	r.upserts++
+	r.prefs = prefs
+	return nil
+}
+
+// fakeWorkflowRunRepo keeps workflow runs in memory and counts saves.
+type fakeWorkflowRunRepo struct {
+	domain.WorkflowRunRepository
+
+	mu      sync.Mutex
+	runs    map[uuid.UUID]*domain.WorkflowRun
+	updates int
+}
+
+func (r *fakeWorkflowRunRepo) Create(ctx context.Context, run *domain.WorkflowRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.runs == nil {
+		r.runs = make(map[uuid.UUID]*domain.WorkflowRun)
+	}
+	copied := *run
+	r.runs[run.ID] = &copied
+	return nil
+}
+
+func (r *fakeWorkflowRunRepo) Update(ctx context.Context, run *domain.WorkflowRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates++
+	copied := *run
+	r.runs[run.ID] = &copied
+	return nil
+}
+
+// fakeWorkflows serves workflow definitions from a map.
+type fakeWorkflows map[string]*domain.Workflow
+
+func (w fakeWorkflows) Get(name string) (*domain.Workflow, error) {
+	workflow, ok := w[name]
+	if !ok {
+		return nil, domain.NewErrNotFound("workflow", name)
+	}
+	return workflow, nil
+}
+
+// fakeNotifier records the realtime events emitted per user.
+type fakeNotifier struct {
+	mu     sync.Mutex
+	events map[uuid.UUID][]*domain.Event
+}
+
+func (n *fakeNotifier) Emit(ctx context.Context, userID uuid.UUID, event *domain.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.events == nil {
+		n.events = make(map[uuid.UUID][]*domain.Event)
+	}
+	n.events[userID] = append(n.events[userID], event)
+	return nil
+}
+
+// emitted returns the events emitted to userID.
+func (n *fakeNotifier) emitted(userID uuid.UUID) []*domain.Event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.events[userID]
+}
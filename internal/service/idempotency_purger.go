@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// idempotencyPurgeBatchSize is how many expired keys are deleted per statement.
+const idempotencyPurgeBatchSize = 1000
+
+// IdempotencyPurger periodically deletes idempotency keys whose TTL has
+// passed. Reserve only reuses an expired key when the same key comes back,
+// so without purging the table would keep every key ever used.
+type IdempotencyPurger struct {
+	keys     domain.IdempotencyRepository
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewIdempotencyPurger creates a purger that deletes expired keys every interval.
+func NewIdempotencyPurger(keys domain.IdempotencyRepository, interval time.Duration) *IdempotencyPurger {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &IdempotencyPurger{
+		keys:     keys,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start launches the purge loop in the background.
+func (p *IdempotencyPurger) Start() {
+	go p.run()
+}
+
+// Stop stops the purge loop and waits for it to exit, or for ctx to expire.
+func (p *IdempotencyPurger) Stop(ctx context.Context) error {
+	close(p.stop)
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run is the purge loop.
+func (p *IdempotencyPurger) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(context.Background())
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge deletes expired keys batch by batch until none are left.
+func (p *IdempotencyPurger) purge(ctx context.Context) {
+	var total int64
+	for {
+		deleted, err := p.keys.PurgeExpired(ctx, time.Now(), idempotencyPurgeBatchSize)
+		if err != nil {
+			log.Printf("[IdempotencyPurger] failed to purge expired idempotency keys: %v", err)
+			return
+		}
+		total += deleted
+
+		if deleted < idempotencyPurgeBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("[IdempotencyPurger] purged %d expired idempotency key(s)", total)
+	}
+}
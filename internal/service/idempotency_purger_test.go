@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// fakeIdempotencyRepo has expired keys to purge and records each purge call.
+type fakeIdempotencyRepo struct {
+	domain.IdempotencyRepository
+
+	expired int64
+	err     error
+	calls   int
+}
+
+func (r *fakeIdempotencyRepo) PurgeExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	r.calls++
+	if r.err != nil {
+		return 0, r.err
+	}
+	deleted := min(r.expired, int64(limit))
+	r.expired -= deleted
+	return deleted, nil
+}
+
+func TestIdempotencyPurgerPurgesInBatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		expired   int64
+		wantCalls int
+	}{
+		{"nothing expired", 0, 1},
+		{"less than a batch", 5, 1},
+		{"exactly one batch", idempotencyPurgeBatchSize, 2},
+		{"several batches", 2*idempotencyPurgeBatchSize + 5, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &fakeIdempotencyRepo{expired: tt.expired}
+			NewIdempotencyPurger(keys, time.Hour).purge(context.Background())
+
+			if keys.expired != 0 {
+				t.Errorf("%d expired keys left, want 0", keys.expired)
+			}
+			if keys.calls != tt.wantCalls {
+				t.Errorf("PurgeExpired called %d times, want %d", keys.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyPurgerStopsOnError(t *testing.T) {
+	keys := &fakeIdempotencyRepo{expired: 10 * idempotencyPurgeBatchSize, err: errors.New("connection refused")}
+	NewIdempotencyPurger(keys, time.Hour).purge(context.Background())
+
+	if keys.calls != 1 {
+		t.Errorf("PurgeExpired called %d times after failing, want 1", keys.calls)
+	}
+}
+
+func TestIdempotencyPurgerStartStop(t *testing.T) {
+	keys := &fakeIdempotencyRepo{expired: 5}
+	purger := NewIdempotencyPurger(keys, time.Hour)
+	purger.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := purger.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if keys.expired != 0 {
+		t.Errorf("%d expired keys left after the first run, want 0", keys.expired)
+	}
+}
@@ -4,17 +4,19 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/templates"
 )
 
 // EmailSender is the interface for sending emails.
 type EmailSender interface {
-	Send(ctx context.Context, to, subject, body string) error
+	// SendEmail sends msg and reports which provider accepted it.
+	SendEmail(ctx context.Context, msg *domain.EmailMessage) (*domain.EmailReceipt, error)
 	SendTemplate(ctx context.Context, to, templateID string, data map[string]interface{}) error
-	SendHTML(ctx context.Context, to, subject, plainText, htmlContent string) error
 }
 
 // PushSender is the interface for sending push notifications.
@@ -23,37 +25,58 @@ type PushSender interface {
 	SendToUser(ctx context.Context, userID uuid.UUID, title, body string, data map[string]interface{}) error
 }
 
-// InAppNotifier is the interface for sending in-app notifications.
+// InAppNotifier is the interface for pushing realtime events, such as a new
+// in-app notification or a changed unread count, to a user's connected clients.
 type InAppNotifier interface {
-	Notify(ctx context.Context, userID uuid.UUID, notification *domain.Notification) error
+	Emit(ctx context.Context, userID uuid.UUID, event *domain.Event) error
+}
+
+// TemplateRenderer renders named templates (email subject, text and HTML plus
+// push copy) in the closest available locale.
+type TemplateRenderer interface {
+	Has(name string) bool
+	Critical(name string) bool
+	Render(name, locale string, data map[string]interface{}) (*templates.Rendered, error)
 }
 
 // NotificationService orchestrates notification sending across all channels.
 type NotificationService struct {
 	notificationRepo domain.NotificationRepository
+	deliveryJobRepo  domain.DeliveryJobRepository
 	deviceTokenRepo  domain.DeviceTokenRepository
 	preferencesRepo  domain.PreferencesRepository
+	suppressionRepo  domain.SuppressionRepository
 	emailSender      EmailSender
 	pushSender       PushSender
 	inAppNotifier    InAppNotifier
+	templates        TemplateRenderer
+	unsubscribe      *UnsubscribeService
 }
 
 // NewNotificationService creates a new notification service.
 func NewNotificationService(
 	notificationRepo domain.NotificationRepository,
+	deliveryJobRepo domain.DeliveryJobRepository,
 	deviceTokenRepo domain.DeviceTokenRepository,
 	preferencesRepo domain.PreferencesRepository,
+	suppressionRepo domain.SuppressionRepository,
 	emailSender EmailSender,
 	pushSender PushSender,
 	inAppNotifier InAppNotifier,
+	templateRenderer TemplateRenderer,
+	unsubscribe *UnsubscribeService,
 ) *NotificationService {
 	return &NotificationService{
 		notificationRepo: notificationRepo,
+		deliveryJobRepo:  deliveryJobRepo,
 		deviceTokenRepo:  deviceTokenRepo,
 		preferencesRepo:  preferencesRepo,
+		suppressionRepo:  suppressionRepo,
 		emailSender:      emailSender,
 		pushSender:       pushSender,
 		inAppNotifier:    inAppNotifier,
+		templates:        templateRenderer,
+		unsubscribe:      unsubscribe,
 	}
 }
 
@@ -62,17 +85,78 @@ type SendRequest struct {
 	UserID   uuid.UUID
 	Email    string // Required for email channel
 	Channels []domain.NotificationType
-	Template string
+	Template string // For emails, a registered template rendered with Data replaces Title/Body/HtmlBody
+	Channel  string // What kind of notification it is, e.g. "marketing"; defaults to Template
 	Title    string
 	Body     string
 	HtmlBody string // Optional HTML content for emails
 	Data     map[string]interface{}
+
+	// IdempotencyKey, if set, is stored on every notification created by this
+	// request (see notificationKey). Sending again with the same key before
+	// IdempotencyExpiresAt returns the existing notifications instead of
+	// queuing new ones. A zero IdempotencyExpiresAt never expires.
+	IdempotencyKey       string
+	IdempotencyExpiresAt time.Time
+
+	// SendAt, if set and in the future, holds the notifications back until
+	// then. The Scheduler releases them into the delivery queue when due.
+	SendAt *time.Time
+
+	// Critical notifications (OTP, password reset, ...) ignore quiet hours.
+	// Anything else arriving during quiet hours is deferred until they end.
+	// Templates whose manifest sets "critical" are critical even without it.
+	Critical bool
+
+	// Locale (BCP 47, e.g. "de-AT") the template is rendered in. Empty uses
+	// the locale from the user's preferences, then the default locale.
+	Locale string
+
+	// Fallback treats Channels as an ordered chain (e.g. push, in_app, email):
+	// a single notification goes out on the first channel that is available,
+	// and moves on to the next one if delivery there fails for good.
+	Fallback bool
+}
+
+// channelName returns the notification channel the request belongs to.
+func (r SendRequest) channelName() string {
+	if r.Channel != "" {
+		return r.Channel
+	}
+	return r.Template
+}
+
+// notificationKey returns the idempotency key for the request's notification
+// on channel. A fallback chain creates a single notification whichever
+// channel it ends up on; otherwise each channel gets a key of its own.
+func (r SendRequest) notificationKey(channel domain.NotificationType) string {
+	if r.IdempotencyKey == "" || r.Fallback {
+		return r.IdempotencyKey
+	}
+	return r.IdempotencyKey + ":" + string(channel)
+}
+
+// SendResult lists the notifications that were queued for delivery.
+type SendResult struct {
+	Notifications []*domain.Notification
 }
 
-// Send sends notifications through the specified channels.
-func (s *NotificationService) Send(ctx context.Context, req SendRequest) error {
+// Send persists one notification per channel and queues it for delivery.
+// Nothing is sent to a provider here - the DeliveryWorkerPool picks the
+// queued jobs up in the background, so slow providers never block callers.
+// With a future SendAt the notifications are stored as scheduled instead.
+// With Fallback set, only one notification is created for the whole chain.
+func (s *NotificationService) Send(ctx context.Context, req SendRequest) (*SendResult, error) {
 	log.Printf("[NotificationService] Sending notification to user %s via channels: %v", req.UserID, req.Channels)
 
+	result := &SendResult{}
+
+	// A template can mark its notifications critical (e.g. otp_verification),
+	// so callers of such templates can't forget the flag
+	if !req.Critical && s.templates != nil && s.templates.Critical(req.Template) {
+		req.Critical = true
+	}
+
 	// Get user preferences (if preferencesRepo is available)
 	var prefs *domain.NotificationPreferences
 	if s.preferencesRepo != nil {
@@ -87,202 +171,415 @@ func (s *NotificationService) Send(ctx context.Context, req SendRequest) error {
 		prefs = domain.NewDefaultPreferences(req.UserID)
 	}
 
-	// Check quiet hours
-	if prefs.IsInQuietHours() {
-		// During quiet hours, only send critical notifications (like OTP)
-		if req.Template != "otp_verification" && req.Template != "password_reset" {
-			log.Printf("Skipping notification during quiet hours for user %s", req.UserID)
-			return nil
+	// Users can turn off whole notification channels (e.g. "marketing"), in
+	// their preferences or through an unsubscribe link. Critical
+	// notifications go out regardless.
+	if !req.Critical && !prefs.IsChannelEnabled(req.channelName()) {
+		log.Printf("[NotificationService] %s notifications disabled for user %s", req.channelName(), req.UserID)
+		return result, nil
+	}
+
+	// Check quiet hours at the time the notification would go out.
+	// Critical notifications (like OTP) always go out; everything else
+	// is held back until the user's quiet hours are over.
+	var deferReason string
+	if !req.Critical {
+		sendAt := time.Now()
+		if req.SendAt != nil && req.SendAt.After(sendAt) {
+			sendAt = *req.SendAt
+		}
+
+		if prefs.IsInQuietHoursAt(sendAt) {
+			until := prefs.QuietHoursEndAfter(sendAt)
+			log.Printf("Deferring notification for user %s until quiet hours end at %s", req.UserID, until.Format(time.RFC3339))
+			req.SendAt = &until
+			deferReason = domain.DeferReasonQuietHours
 		}
 	}
 
+	// Render the template once up front, so missing variables fail the
+	// request instead of every delivery attempt. An explicit locale wins
+	// over the user's preference; the registry falls back from there.
+	emailReq, pushReq := req, req
+	if s.templates != nil && s.templates.Has(req.Template) {
+		locale := req.Locale
+		if locale == "" {
+			locale = prefs.Locale
+		}
+
+		rendered, err := s.templates.Render(req.Template, locale, req.Data)
+		if err != nil {
+			return result, fmt.Errorf("failed to render template %s: %w", req.Template, err)
+		}
+		if rendered.Subject != "" {
+			emailReq.Title = rendered.Subject
+		}
+		emailReq.Body = rendered.Text
+		emailReq.HtmlBody = rendered.HTML
+
+		// Push and in-app notifications use the template's push copy, if any
+		if rendered.PushTitle != "" {
+			pushReq.Title = rendered.PushTitle
+		}
+		if rendered.PushBody != "" {
+			pushReq.Body = rendered.PushBody
+		}
+	}
+
+	if req.Fallback {
+		notification, err := s.enqueueChain(ctx, req, emailReq, pushReq, prefs, deferReason)
+		if err != nil {
+			return result, err
+		}
+		result.Notifications = append(result.Notifications, notification)
+		return result, nil
+	}
+
 	var errors []error
 
 	for _, channel := range req.Channels {
-		var err error
-
 		switch channel {
 		case domain.NotificationTypeEmail:
 			if !prefs.EmailEnabled {
 				log.Printf("Email notifications disabled for user %s", req.UserID)
 				continue
 			}
-			err = s.sendEmail(ctx, req)
 
 		case domain.NotificationTypePush:
 			if !prefs.PushEnabled {
 				log.Printf("Push notifications disabled for user %s", req.UserID)
 				continue
 			}
-			err = s.sendPush(ctx, req)
+		}
 
-		case domain.NotificationTypeInApp:
-			err = s.sendInApp(ctx, req)
+		channelReq := pushReq
+		if channel == domain.NotificationTypeEmail {
+			channelReq = emailReq
 		}
 
+		notification, err := s.enqueue(ctx, channelReq, channel, deferReason, nil)
 		if err != nil {
 			errors = append(errors, fmt.Errorf("%s: %w", channel, err))
+			continue
 		}
+		result.Notifications = append(result.Notifications, notification)
 	}
 
 	if len(errors) > 0 {
-		return fmt.Errorf("notification errors: %v", errors)
+		return result, fmt.Errorf("notification errors: %v", errors)
 	}
 
-	return nil
+	return result, nil
 }
 
-// sendEmail sends an email notification.
-func (s *NotificationService) sendEmail(ctx context.Context, req SendRequest) error {
-	if s.emailSender == nil {
-		return fmt.Errorf("email sender not configured")
+// enqueueChain queues one notification for the first available channel of
+// req.Channels and stores the remaining ones as its fallback chain. Channels
+// the user disabled, or that can't be used for this request, are skipped
+// and recorded on the notification's channel path.
+func (s *NotificationService) enqueueChain(ctx context.Context, req, emailReq, pushReq SendRequest, prefs *domain.NotificationPreferences, deferReason string) (*domain.Notification, error) {
+	var steps []domain.FallbackStep
+	var skipped []domain.NotificationType
+	var reasons []string
+
+	for _, channel := range req.Channels {
+		if reason := s.unavailable(channel, req, prefs); reason != "" {
+			log.Printf("[NotificationService] Skipping %s for user %s in fallback chain: %s", channel, req.UserID, reason)
+			skipped = append(skipped, channel)
+			reasons = append(reasons, reason)
+			continue
+		}
+
+		step := domain.FallbackStep{Type: channel, Title: pushReq.Title, Body: pushReq.Body}
+		if channel == domain.NotificationTypeEmail {
+			step = domain.FallbackStep{Type: channel, Title: emailReq.Title, Body: emailReq.Body, HtmlBody: emailReq.HtmlBody}
+		}
+		steps = append(steps, step)
+	}
+
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%w: no channel of fallback chain %v can reach user %s", domain.ErrChannelUnavailable, req.Channels, req.UserID)
+	}
+
+	// The skipped channels and the first step make up the start of the path;
+	// the metadata is copied so the caller's Data isn't modified
+	path := &domain.Notification{Metadata: make(map[string]interface{})}
+	for k, v := range req.Data {
+		path.Metadata[k] = v
+	}
+	for i, channel := range skipped {
+		path.RecordChannel(channel, reasons[i])
+	}
+	path.RecordChannel(steps[0].Type, "")
+
+	channelReq := pushReq
+	if steps[0].Type == domain.NotificationTypeEmail {
+		channelReq = emailReq
+	}
+	channelReq.Data = path.Metadata
+
+	return s.enqueue(ctx, channelReq, steps[0].Type, deferReason, steps[1:])
+}
+
+// unavailable returns why channel can't be used for req, or "" if it can.
+func (s *NotificationService) unavailable(channel domain.NotificationType, req SendRequest, prefs *domain.NotificationPreferences) string {
+	switch channel {
+	case domain.NotificationTypeEmail:
+		if !prefs.EmailEnabled {
+			return "disabled by user"
+		}
+		if s.emailSender == nil {
+			return "email sender not configured"
+		}
+		if req.Email == "" {
+			return "email address required"
+		}
+
+	case domain.NotificationTypePush:
+		if !prefs.PushEnabled {
+			return "disabled by user"
+		}
+		if s.pushSender == nil {
+			return "push sender not configured"
+		}
+	}
+	return ""
+}
+
+// enqueue validates a channel and writes its notification and delivery job.
+// deferReason is recorded on the notification when Send pushed SendAt back,
+// and fallback is the rest of the chain when the request has one.
+func (s *NotificationService) enqueue(ctx context.Context, req SendRequest, channel domain.NotificationType, deferReason string, fallback []domain.FallbackStep) (*domain.Notification, error) {
+	var payload domain.DeliveryPayload
+
+	switch channel {
+	case domain.NotificationTypeEmail:
+		if s.emailSender == nil {
+			return nil, fmt.Errorf("email sender not configured")
+		}
+		if req.Email == "" {
+			return nil, fmt.Errorf("email address required")
+		}
+		payload.Email = req.Email
+		payload.HtmlBody = req.HtmlBody
+
+	case domain.NotificationTypePush:
+		if s.pushSender == nil {
+			log.Printf("[NotificationService] ERROR: pushSender is nil - Firebase not configured")
+			return nil, fmt.Errorf("push sender not configured")
+		}
 	}
 
-	if req.Email == "" {
-		return fmt.Errorf("email address required")
+	if len(fallback) > 0 {
+		payload.Fallback = fallback
+		payload.Email = req.Email // For a later email step
+	}
+
+	// Non-critical emails can be unsubscribed from in one click. Only an
+	// explicit channel qualifies: a template name is no channel a user could
+	// turn back on in their settings.
+	if payload.Email != "" && !req.Critical && s.unsubscribe != nil && req.Channel != "" {
+		payload.UnsubscribeURL = s.unsubscribe.URL(req.UserID, req.Channel)
 	}
 
-	// Create notification record
 	notification := domain.NewNotification(
 		req.UserID,
-		domain.NotificationTypeEmail,
-		req.Template,
+		channel,
+		req.channelName(),
 		req.Title,
 		req.Body,
 	)
-	notification.Metadata = req.Data
-
-	// Save to database
-	if err := s.notificationRepo.Create(ctx, notification); err != nil {
-		return fmt.Errorf("failed to create notification record: %w", err)
-	}
-
-	// Send email based on template type
-	var err error
-	switch req.Template {
-	case "otp_verification":
-		// Use styled OTP email template
-		otp := ""
-		if req.Data != nil {
-			if otpVal, ok := req.Data["otp"]; ok {
-				otp = fmt.Sprintf("%v", otpVal)
-			}
-		}
-		htmlContent := generateOtpEmailHtml(otp)
-		err = s.emailSender.SendHTML(ctx, req.Email, req.Title, req.Body, htmlContent)
-	case "welcome_trial":
-		name := ""
-		hasTrial := false
-		trialDays := 3
-		if req.Data != nil {
-			if n, ok := req.Data["name"]; ok && n != nil {
-				name = fmt.Sprintf("%v", n)
-			}
-			if t, ok := req.Data["hasTrial"]; ok {
-				hasTrial = fmt.Sprintf("%v", t) == "true"
-			}
-			if d, ok := req.Data["trialDays"]; ok {
-				fmt.Sscanf(fmt.Sprintf("%v", d), "%d", &trialDays)
-			}
+	if req.Data != nil {
+		notification.Metadata = req.Data
+	}
+	notification.IdempotencyKey = req.notificationKey(channel)
+	if notification.IdempotencyKey != "" && !req.IdempotencyExpiresAt.IsZero() {
+		expiresAt := req.IdempotencyExpiresAt
+		notification.IdempotencyExpiresAt = &expiresAt
+	}
+
+	var job *domain.DeliveryJob
+	if req.SendAt != nil && req.SendAt.After(time.Now()) {
+		notification.Defer(*req.SendAt, deferReason)
+		job = domain.NewScheduledDeliveryJob(notification.ID, payload, *req.SendAt)
+	} else {
+		job = domain.NewDeliveryJob(notification.ID, payload)
+	}
+
+	if err := s.deliveryJobRepo.Enqueue(ctx, notification, job); err != nil {
+		if _, ok := err.(*domain.ErrDuplicate); ok {
+			// Already queued under this key - hand back the original instead of sending twice
+			log.Printf("[NotificationService] Duplicate %s notification for user %s (idempotency key %s)", channel, req.UserID, notification.IdempotencyKey)
+			return s.notificationRepo.GetByIdempotencyKey(ctx, notification.IdempotencyKey, req.UserID)
 		}
-		htmlContent := generateWelcomeTrialEmailHtml(name, hasTrial, trialDays)
-		err = s.emailSender.SendHTML(ctx, req.Email, req.Title, req.Body, htmlContent)
-	case "trial_expired":
-		htmlContent := generateTrialExpiredEmailHtml()
-		err = s.emailSender.SendHTML(ctx, req.Email, req.Title, req.Body, htmlContent)
+		return nil, fmt.Errorf("failed to queue notification: %w", err)
+	}
+
+	return notification, nil
+}
+
+// fallback moves a notification whose delivery failed for good to the next
+// channel of its job's fallback chain and queues it again.
+func (s *NotificationService) fallback(ctx context.Context, workerID string, n *domain.Notification, job *domain.DeliveryJob, cause error) error {
+	next := job.Payload.Fallback[0]
+	log.Printf("[NotificationService] Notification %s falling back from %s to %s: %v", n.ID, n.Type, next.Type, cause)
+
+	n.FallBack(next, cause.Error())
+	job.Payload.HtmlBody = next.HtmlBody
+	job.Payload.Fallback = job.Payload.Fallback[1:]
+
+	return s.deliveryJobRepo.Fallback(ctx, n, job, workerID, cause.Error())
+}
+
+// deliver sends a stored notification through its channel's provider.
+func (s *NotificationService) deliver(ctx context.Context, n *domain.Notification, payload domain.DeliveryPayload) error {
+	switch n.Type {
+	case domain.NotificationTypeEmail:
+		return s.sendEmail(ctx, n, payload)
+	case domain.NotificationTypePush:
+		return s.sendPush(ctx, n)
+	case domain.NotificationTypeInApp:
+		return s.sendInApp(ctx, n)
 	default:
-		// Use SendHTML if HtmlBody is provided, otherwise use simple send
-		if req.HtmlBody != "" {
-			err = s.emailSender.SendHTML(ctx, req.Email, req.Title, req.Body, req.HtmlBody)
-		} else {
-			err = s.emailSender.Send(ctx, req.Email, req.Title, req.Body)
+		return domain.NewPermanentError(fmt.Errorf("unsupported notification type: %s", n.Type))
+	}
+}
+
+// sendEmail sends an email notification.
+func (s *NotificationService) sendEmail(ctx context.Context, n *domain.Notification, payload domain.DeliveryPayload) error {
+	if s.emailSender == nil {
+		return domain.NewPermanentError(fmt.Errorf("email sender not configured"))
+	}
+
+	if payload.Email == "" {
+		return domain.NewPermanentError(fmt.Errorf("email address required"))
+	}
+
+	// Never email addresses that bounced, complained or unsubscribed
+	if s.suppressionRepo != nil {
+		suppression, err := s.suppressionRepo.Get(ctx, payload.Email)
+		if err == nil {
+			return domain.NewErrSuppressed(suppression)
+		}
+		if _, ok := err.(*domain.ErrNotFound); !ok {
+			return domain.NewRetryableError(fmt.Errorf("failed to check email suppression: %w", err))
 		}
 	}
 
-	// Update status
+	// Templated emails were rendered into the notification and payload by Send
+	receipt, err := s.emailSender.SendEmail(ctx, &domain.EmailMessage{
+		To:      payload.Email,
+		Subject: n.Title,
+		Text:    n.Body,
+		HTML:    payload.HtmlBody,
+
+		NotificationID: n.ID,
+		UnsubscribeURL: payload.UnsubscribeURL,
+	})
 	if err != nil {
-		if statusErr := s.notificationRepo.UpdateStatus(ctx, notification.ID, domain.NotificationStatusFailed); statusErr != nil {
-			log.Printf("failed to update notification status to failed: %v", statusErr)
-		}
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
-	if err := s.notificationRepo.UpdateStatus(ctx, notification.ID, domain.NotificationStatusSent); err != nil {
-		log.Printf("failed to update notification status to sent: %v", err)
+	// Record which provider took it, for support and for matching provider events
+	metadata := map[string]interface{}{domain.EmailProviderKey: receipt.Provider}
+	if receipt.MessageID != "" {
+		metadata[domain.EmailMessageIDKey] = receipt.MessageID
+	}
+	if err := s.notificationRepo.UpdateMetadata(ctx, n.ID, metadata); err != nil {
+		log.Printf("[NotificationService] failed to record email provider for notification %s: %v", n.ID, err)
 	}
 	return nil
 }
 
 // sendPush sends a push notification to all user devices.
-func (s *NotificationService) sendPush(ctx context.Context, req SendRequest) error {
-	log.Printf("[NotificationService] sendPush called for user %s, title: %s", req.UserID, req.Title)
+func (s *NotificationService) sendPush(ctx context.Context, n *domain.Notification) error {
+	log.Printf("[NotificationService] sendPush called for user %s, title: %s", n.UserID, n.Title)
 
 	if s.pushSender == nil {
 		log.Printf("[NotificationService] ERROR: pushSender is nil - Firebase not configured")
-		return fmt.Errorf("push sender not configured")
+		return domain.NewPermanentError(fmt.Errorf("push sender not configured"))
 	}
 
-	// Create notification record
-	notification := domain.NewNotification(
-		req.UserID,
-		domain.NotificationTypePush,
-		req.Template,
-		req.Title,
-		req.Body,
-	)
-	notification.Metadata = req.Data
-
-	if err := s.notificationRepo.Create(ctx, notification); err != nil {
-		return fmt.Errorf("failed to create notification record: %w", err)
+	if err := s.pushSender.SendToUser(ctx, n.UserID, n.Title, n.Body, n.Metadata); err != nil {
+		return fmt.Errorf("failed to send push: %w", err)
 	}
+	return nil
+}
 
-	// Send push notification
-	err := s.pushSender.SendToUser(ctx, req.UserID, req.Title, req.Body, req.Data)
+// sendInApp broadcasts a stored in-app notification via WebSocket.
+func (s *NotificationService) sendInApp(ctx context.Context, n *domain.Notification) error {
+	// In-app notifications are "sent" once stored; the broadcast is best effort
+	n.MarkAsSent()
 
-	if err != nil {
-		if statusErr := s.notificationRepo.UpdateStatus(ctx, notification.ID, domain.NotificationStatusFailed); statusErr != nil {
-			log.Printf("failed to update notification status to failed: %v", statusErr)
-		}
-		return fmt.Errorf("failed to send push: %w", err)
-	}
+	// Broadcast via WebSocket if available
+	s.emit(ctx, n.UserID, domain.NewEvent(domain.EventNotificationCreated, n))
+	s.emitUnreadCount(ctx, n.UserID)
 
-	if err := s.notificationRepo.UpdateStatus(ctx, notification.ID, domain.NotificationStatusSent); err != nil {
-		log.Printf("failed to update notification status to sent: %v", err)
-	}
 	return nil
 }
 
-// sendInApp creates an in-app notification and broadcasts it via WebSocket.
-func (s *NotificationService) sendInApp(ctx context.Context, req SendRequest) error {
-	// Create notification record
-	notification := domain.NewNotification(
-		req.UserID,
-		domain.NotificationTypeInApp,
-		req.Template,
-		req.Title,
-		req.Body,
-	)
-	notification.Metadata = req.Data
+// emit pushes a realtime event to the user's connected clients.
+// Events are best effort: failures are logged, never returned.
+func (s *NotificationService) emit(ctx context.Context, userID uuid.UUID, event *domain.Event) {
+	if s.inAppNotifier == nil {
+		return
+	}
+	if err := s.inAppNotifier.Emit(ctx, userID, event); err != nil {
+		log.Printf("Failed to emit %s event: %v", event.Type, err)
+	}
+}
 
-	if err := s.notificationRepo.Create(ctx, notification); err != nil {
-		return fmt.Errorf("failed to create notification record: %w", err)
+// emitUnreadCount pushes the user's current unread count.
+func (s *NotificationService) emitUnreadCount(ctx context.Context, userID uuid.UUID) {
+	if s.inAppNotifier == nil {
+		return
 	}
 
-	// Mark as sent (in-app notifications are "sent" when stored)
-	notification.MarkAsSent()
-	if err := s.notificationRepo.UpdateStatus(ctx, notification.ID, domain.NotificationStatusSent); err != nil {
-		log.Printf("failed to update notification status to sent: %v", err)
+	count, err := s.notificationRepo.GetUnreadCount(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to get unread count for user %s: %v", userID, err)
+		return
 	}
+	s.emit(ctx, userID, domain.NewEvent(domain.EventUnreadCountChanged, domain.UnreadCountPayload{Count: count}))
+}
 
-	// Broadcast via WebSocket if available
-	if s.inAppNotifier != nil {
-		if err := s.inAppNotifier.Notify(ctx, req.UserID, notification); err != nil {
-			// Log but don't fail - the notification is still stored
-			log.Printf("Failed to broadcast in-app notification: %v", err)
+// DeadLetter pairs a dead-lettered notification with its last delivery attempt.
+type DeadLetter struct {
+	Notification *domain.Notification `json:"notification"`
+	Job          *domain.DeliveryJob  `json:"job"`
+}
+
+// ListDeadLetters returns notifications whose delivery was given up on.
+func (s *NotificationService) ListDeadLetters(ctx context.Context, opts domain.ListOptions) ([]*DeadLetter, int64, error) {
+	jobs, total, err := s.deliveryJobRepo.ListDeadLettered(ctx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	deadLetters := make([]*DeadLetter, 0, len(jobs))
+	for _, job := range jobs {
+		notification, err := s.notificationRepo.GetByID(ctx, job.NotificationID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to load notification %s: %w", job.NotificationID, err)
 		}
+		deadLetters = append(deadLetters, &DeadLetter{Notification: notification, Job: job})
 	}
 
-	return nil
+	return deadLetters, total, nil
+}
+
+// RequeueDeadLetter resets a dead-lettered notification so it is delivered again.
+func (s *NotificationService) RequeueDeadLetter(ctx context.Context, notificationID uuid.UUID) error {
+	return s.deliveryJobRepo.Requeue(ctx, notificationID)
+}
+
+// CancelScheduled cancels a notification that is still waiting for its send time.
+func (s *NotificationService) CancelScheduled(ctx context.Context, notificationID uuid.UUID) error {
+	return s.deliveryJobRepo.Cancel(ctx, notificationID)
+}
+
+// Reschedule moves a notification that is still waiting for its send time to sendAt.
+func (s *NotificationService) Reschedule(ctx context.Context, notificationID uuid.UUID, sendAt time.Time) error {
+	return s.deliveryJobRepo.Reschedule(ctx, notificationID, sendAt)
 }
 
 // GetNotifications retrieves notifications for a user.
@@ -295,268 +592,72 @@ func (s *NotificationService) GetNotification(ctx context.Context, id uuid.UUID)
 	return s.notificationRepo.GetByID(ctx, id)
 }
 
-// MarkAsRead marks a notification as read.
-func (s *NotificationService) MarkAsRead(ctx context.Context, id uuid.UUID) error {
-	return s.notificationRepo.MarkAsRead(ctx, id)
+// MarkAsRead marks one of the user's notifications as read and tells the
+// user's other clients.
+func (s *NotificationService) MarkAsRead(ctx context.Context, userID, id uuid.UUID) error {
+	if err := s.notificationRepo.MarkAsRead(ctx, id); err != nil {
+		return err
+	}
+
+	s.emit(ctx, userID, domain.NewEvent(domain.EventNotificationRead, domain.NotificationReadPayload{
+		NotificationID: &id,
+		ReadAt:         time.Now(),
+	}))
+	s.emitUnreadCount(ctx, userID)
+	return nil
 }
 
-// MarkAllAsRead marks all notifications for a user as read.
+// MarkAllAsRead marks all notifications for a user as read and tells the
+// user's clients.
 func (s *NotificationService) MarkAllAsRead(ctx context.Context, userID uuid.UUID) error {
-	return s.notificationRepo.MarkAllAsRead(ctx, userID)
+	if err := s.notificationRepo.MarkAllAsRead(ctx, userID); err != nil {
+		return err
+	}
+
+	s.emit(ctx, userID, domain.NewEvent(domain.EventNotificationRead, domain.NotificationReadPayload{
+		All:    true,
+		ReadAt: time.Now(),
+	}))
+	s.emit(ctx, userID, domain.NewEvent(domain.EventUnreadCountChanged, domain.UnreadCountPayload{Count: 0}))
+	return nil
+}
+
+// AcknowledgeDelivery records that one of the user's clients received an
+// in-app notification.
+func (s *NotificationService) AcknowledgeDelivery(ctx context.Context, id uuid.UUID) error {
+	return s.notificationRepo.MarkDelivered(ctx, id)
+}
+
+// DeleteOlderThan deletes notifications older than the given number of days
+// and tells the clients of their users, returning how many were deleted.
+func (s *NotificationService) DeleteOlderThan(ctx context.Context, days int) (int, error) {
+	deleted, err := s.notificationRepo.DeleteOlderThan(ctx, days)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only in-app notifications are shown to clients
+	var users []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, n := range deleted {
+		if n.Type != domain.NotificationTypeInApp {
+			continue
+		}
+		s.emit(ctx, n.UserID, domain.NewEvent(domain.EventNotificationDeleted, domain.NotificationDeletedPayload{NotificationID: n.ID}))
+		if !seen[n.UserID] {
+			seen[n.UserID] = true
+			users = append(users, n.UserID)
+		}
+	}
+
+	for _, userID := range users {
+		s.emitUnreadCount(ctx, userID)
+	}
+
+	return len(deleted), nil
 }
 
 // GetUnreadCount returns the count of unread notifications.
 func (s *NotificationService) GetUnreadCount(ctx context.Context, userID uuid.UUID) (int64, error) {
 	return s.notificationRepo.GetUnreadCount(ctx, userID)
 }
-
-// generateOtpEmailHtml generates a styled HTML email for OTP verification.
-// Uses brand colors: Primary Navy #1E3A5F, Primary Cyan #7DD3FC
-func generateOtpEmailHtml(otp string) string {
-	return fmt.Sprintf(`<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>PrepMyApp Verification Code</title>
-</head>
-<body style="margin: 0; padding: 0; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif; line-height: 1.6; color: #111827; background-color: #F9FAFB;">
-    <div style="max-width: 600px; margin: 20px auto; background-color: #ffffff; border-radius: 16px; box-shadow: 0 4px 20px rgba(30, 58, 95, 0.1); overflow: hidden;">
-        <!-- Header with Navy gradient -->
-        <div style="background: linear-gradient(135deg, #1E3A5F 0%%, #2d4a6f 100%%); padding: 40px 20px; text-align: center;">
-            <img src="https://prepmyapp.com/prepmyapp.png" alt="PrepMyApp" style="display: block; margin: 0 auto 12px auto; width: 64px; height: 64px; border-radius: 12px;">
-            <h1 style="font-size: 28px; font-weight: bold; color: #ffffff; margin: 0; letter-spacing: 1px;">PrepMyApp</h1>
-        </div>
-
-        <!-- Main Content -->
-        <div style="padding: 40px 30px; text-align: center;">
-            <h2 style="font-size: 24px; font-weight: 600; color: #1E3A5F; margin: 0 0 16px 0;">Verification Code</h2>
-            <p style="font-size: 16px; color: #6B7280; margin: 0 0 32px 0; line-height: 1.6;">
-                We received a request to access your PrepMyApp account.<br>Use the code below to complete your sign-in.
-            </p>
-
-            <!-- OTP Code Box -->
-            <div style="background: linear-gradient(135deg, #F9FAFB 0%%, #F3F4F6 100%%); border-radius: 12px; padding: 32px; margin: 0 0 32px 0; border: 2px solid #E5E7EB;">
-                <p style="font-size: 42px; font-weight: bold; color: #1E3A5F; letter-spacing: 12px; margin: 0; font-family: 'SF Mono', 'Courier New', monospace;">%s</p>
-                <p style="font-size: 12px; color: #9CA3AF; margin: 12px 0 0 0; text-transform: uppercase; letter-spacing: 2px; font-weight: 600;">Verification Code</p>
-            </div>
-
-            <!-- Expiry Notice -->
-            <div style="background-color: #FEF3C7; border-radius: 8px; padding: 14px 20px; margin: 0 0 24px 0; display: inline-block;">
-                <p style="font-size: 14px; color: #92400E; margin: 0; font-weight: 500;">
-                    ⏱ This code expires in 5 minutes
-                </p>
-            </div>
-
-            <!-- Security Notice -->
-            <div style="background-color: #F0F9FF; border-left: 4px solid #7DD3FC; padding: 16px 20px; margin: 0 0 20px 0; text-align: left; border-radius: 0 8px 8px 0;">
-                <p style="font-size: 14px; color: #1E3A5F; margin: 0;">
-                    🔒 If you didn't request this code, please ignore this email. Your account remains secure.
-                </p>
-            </div>
-        </div>
-
-        <!-- Footer -->
-        <div style="background-color: #1E3A5F; padding: 30px; text-align: center;">
-            <div style="margin: 0 0 20px 0;">
-                <a href="https://prepmyapp.com" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Website</a>
-                <span style="color: #4B5563;">|</span>
-                <a href="https://prepmyapp.com/privacy" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Privacy</a>
-                <span style="color: #4B5563;">|</span>
-                <a href="https://prepmyapp.com/terms" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Terms</a>
-                <span style="color: #4B5563;">|</span>
-                <a href="mailto:info@prepmy.app" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Support</a>
-            </div>
-
-            <p style="font-size: 12px; color: #9CA3AF; margin: 0 0 12px 0; line-height: 1.6;">
-                This email was sent because you requested a verification code for PrepMyApp.
-            </p>
-
-            <p style="font-size: 11px; color: #6B7280; margin: 0;">
-                © 2025 PrepMyApp LLC · <a href="mailto:info@prepmy.app" style="color: #7DD3FC; text-decoration: none;">info@prepmy.app</a>
-            </p>
-        </div>
-    </div>
-</body>
-</html>`, otp)
-}
-
-// generateWelcomeTrialEmailHtml generates a styled welcome email with trial information.
-func generateWelcomeTrialEmailHtml(name string, hasTrial bool, trialDays int) string {
-	greeting := "Hi"
-	if name != "" {
-		greeting = fmt.Sprintf("Hi %s", name)
-	}
-
-	trialSection := ""
-	if hasTrial {
-		trialSection = fmt.Sprintf(`
-            <!-- Trial Banner -->
-            <div style="background: linear-gradient(135deg, #DCFCE7 0%%%%, #D1FAE5 100%%%%); border-radius: 12px; padding: 24px; margin: 0 0 28px 0; border: 1px solid #86EFAC;">
-                <p style="font-size: 18px; font-weight: 700; color: #166534; margin: 0 0 8px 0;">🎉 Activate Your %d-Day Free Trial!</p>
-                <p style="font-size: 14px; color: #15803D; margin: 0; line-height: 1.6;">
-                    Go to the Subscriptions tab in the app and tap "Start free trial" to unlock the Accelerate plan:
-                </p>
-            </div>
-
-            <!-- Features Grid -->
-            <div style="text-align: left; margin: 0 0 28px 0;">
-                <div style="padding: 12px 0; border-bottom: 1px solid #F3F4F6;">
-                    <span style="font-size: 16px; margin-right: 10px;">🚀</span>
-                    <span style="font-size: 15px; color: #1E3A5F; font-weight: 600;">100 Auto-Applies per Month</span>
-                    <p style="font-size: 13px; color: #6B7280; margin: 4px 0 0 30px;">We apply to matching jobs for you automatically</p>
-                </div>
-                <div style="padding: 12px 0; border-bottom: 1px solid #F3F4F6;">
-                    <span style="font-size: 16px; margin-right: 10px;">🔍</span>
-                    <span style="font-size: 15px; color: #1E3A5F; font-weight: 600;">Unlimited Job Discovery</span>
-                    <p style="font-size: 13px; color: #6B7280; margin: 4px 0 0 30px;">AI-powered matching finds the best roles for you</p>
-                </div>
-                <div style="padding: 12px 0; border-bottom: 1px solid #F3F4F6;">
-                    <span style="font-size: 16px; margin-right: 10px;">📧</span>
-                    <span style="font-size: 15px; color: #1E3A5F; font-weight: 600;">AI Email Categorization</span>
-                    <p style="font-size: 13px; color: #6B7280; margin: 4px 0 0 30px;">Automatically organize recruiter emails and track responses</p>
-                </div>
-                <div style="padding: 12px 0;">
-                    <span style="font-size: 16px; margin-right: 10px;">📋</span>
-                    <span style="font-size: 15px; color: #1E3A5F; font-weight: 600;">Full Application Management</span>
-                    <p style="font-size: 13px; color: #6B7280; margin: 4px 0 0 30px;">Track applications, interviews, tasks, and documents</p>
-                </div>
-            </div>
-
-            <!-- Urgency Note -->
-            <div style="background-color: #FEF3C7; border-radius: 8px; padding: 14px 20px; margin: 0 0 28px 0;">
-                <p style="font-size: 14px; color: #92400E; margin: 0; font-weight: 500;">
-                    ⏱ Your trial ends in %d days — make the most of it!
-                </p>
-            </div>`, trialDays, trialDays)
-	}
-
-	return fmt.Sprintf(`<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Welcome to PrepMyApp</title>
-</head>
-<body style="margin: 0; padding: 0; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif; line-height: 1.6; color: #111827; background-color: #F9FAFB;">
-    <div style="max-width: 600px; margin: 20px auto; background-color: #ffffff; border-radius: 16px; box-shadow: 0 4px 20px rgba(30, 58, 95, 0.1); overflow: hidden;">
-        <!-- Header -->
-        <div style="background: linear-gradient(135deg, #1E3A5F 0%%%%, #2d4a6f 100%%%%); padding: 40px 20px; text-align: center;">
-            <img src="https://prepmyapp.com/prepmyapp.png" alt="PrepMyApp" style="display: block; margin: 0 auto 12px auto; width: 64px; height: 64px; border-radius: 12px;">
-            <h1 style="font-size: 28px; font-weight: bold; color: #ffffff; margin: 0;">Welcome to PrepMyApp!</h1>
-        </div>
-
-        <!-- Main Content -->
-        <div style="padding: 40px 30px; text-align: center;">
-            <p style="font-size: 18px; color: #374151; margin: 0 0 24px 0; line-height: 1.6;">
-                %s, welcome to PrepMyApp! We're excited to help you streamline your job search.
-            </p>
-
-            %s
-
-            <!-- CTA Button -->
-            <a href="https://prepmyapp.com" style="display: inline-block; background: linear-gradient(135deg, #1E3A5F 0%%%%, #2d4a6f 100%%%%); color: #ffffff; text-decoration: none; padding: 16px 40px; border-radius: 10px; font-size: 16px; font-weight: 600; margin: 8px 0 0 0;">
-                Get Started
-            </a>
-        </div>
-
-        <!-- Footer -->
-        <div style="background-color: #1E3A5F; padding: 30px; text-align: center;">
-            <div style="margin: 0 0 20px 0;">
-                <a href="https://prepmyapp.com" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Website</a>
-                <span style="color: #4B5563;">|</span>
-                <a href="https://prepmyapp.com/privacy" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Privacy</a>
-                <span style="color: #4B5563;">|</span>
-                <a href="https://prepmyapp.com/terms" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Terms</a>
-                <span style="color: #4B5563;">|</span>
-                <a href="mailto:info@prepmy.app" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Support</a>
-            </div>
-            <p style="font-size: 11px; color: #6B7280; margin: 0;">
-                © 2025 PrepMyApp LLC · <a href="mailto:info@prepmy.app" style="color: #7DD3FC; text-decoration: none;">info@prepmy.app</a>
-            </p>
-        </div>
-    </div>
-</body>
-</html>`, greeting, trialSection)
-}
-
-// generateTrialExpiredEmailHtml generates a styled email for trial expiration.
-func generateTrialExpiredEmailHtml() string {
-	return `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Your Free Trial Has Ended</title>
-</head>
-<body style="margin: 0; padding: 0; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, sans-serif; line-height: 1.6; color: #111827; background-color: #F9FAFB;">
-    <div style="max-width: 600px; margin: 20px auto; background-color: #ffffff; border-radius: 16px; box-shadow: 0 4px 20px rgba(30, 58, 95, 0.1); overflow: hidden;">
-        <!-- Header -->
-        <div style="background: linear-gradient(135deg, #1E3A5F 0%, #2d4a6f 100%); padding: 40px 20px; text-align: center;">
-            <img src="https://prepmyapp.com/prepmyapp.png" alt="PrepMyApp" style="display: block; margin: 0 auto 12px auto; width: 64px; height: 64px; border-radius: 12px;">
-            <h1 style="font-size: 24px; font-weight: bold; color: #ffffff; margin: 0;">Your Free Trial Has Ended</h1>
-        </div>
-
-        <!-- Main Content -->
-        <div style="padding: 40px 30px; text-align: center;">
-            <p style="font-size: 18px; color: #374151; margin: 0 0 24px 0; line-height: 1.6;">
-                Your free Accelerate trial has come to an end. We hope you enjoyed the experience!
-            </p>
-
-            <!-- What you lose section -->
-            <div style="text-align: left; background-color: #FEF2F2; border-radius: 12px; padding: 24px; margin: 0 0 28px 0; border: 1px solid #FECACA;">
-                <p style="font-size: 15px; font-weight: 600; color: #991B1B; margin: 0 0 12px 0;">Without a subscription, you'll lose access to:</p>
-                <div style="padding: 6px 0;">
-                    <span style="color: #DC2626; margin-right: 8px;">✕</span>
-                    <span style="font-size: 14px; color: #7F1D1D;">Auto-apply to jobs</span>
-                </div>
-                <div style="padding: 6px 0;">
-                    <span style="color: #DC2626; margin-right: 8px;">✕</span>
-                    <span style="font-size: 14px; color: #7F1D1D;">Unlimited job discovery</span>
-                </div>
-                <div style="padding: 6px 0;">
-                    <span style="color: #DC2626; margin-right: 8px;">✕</span>
-                    <span style="font-size: 14px; color: #7F1D1D;">AI email categorization</span>
-                </div>
-                <div style="padding: 6px 0;">
-                    <span style="color: #DC2626; margin-right: 8px;">✕</span>
-                    <span style="font-size: 14px; color: #7F1D1D;">Application tracking & management</span>
-                </div>
-            </div>
-
-            <!-- Pricing nudge -->
-            <div style="background: linear-gradient(135deg, #F0F9FF 0%, #E0F2FE 100%); border-radius: 12px; padding: 24px; margin: 0 0 28px 0; border: 1px solid #BAE6FD;">
-                <p style="font-size: 16px; font-weight: 600; color: #1E3A5F; margin: 0 0 8px 0;">Continue with Accelerate</p>
-                <p style="font-size: 14px; color: #374151; margin: 0 0 4px 0;">100 auto-applies/month, unlimited discovery, and all premium features</p>
-                <p style="font-size: 20px; font-weight: 700; color: #1E3A5F; margin: 12px 0 0 0;">€19/month</p>
-            </div>
-
-            <!-- CTA Button -->
-            <a href="https://prepmyapp.com" style="display: inline-block; background: linear-gradient(135deg, #1E3A5F 0%, #2d4a6f 100%); color: #ffffff; text-decoration: none; padding: 16px 40px; border-radius: 10px; font-size: 16px; font-weight: 600;">
-                Subscribe Now
-            </a>
-
-            <p style="font-size: 13px; color: #9CA3AF; margin: 16px 0 0 0;">
-                Open the PrepMyApp app to view all plans
-            </p>
-        </div>
-
-        <!-- Footer -->
-        <div style="background-color: #1E3A5F; padding: 30px; text-align: center;">
-            <div style="margin: 0 0 20px 0;">
-                <a href="https://prepmyapp.com" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Website</a>
-                <span style="color: #4B5563;">|</span>
-                <a href="https://prepmyapp.com/privacy" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Privacy</a>
-                <span style="color: #4B5563;">|</span>
-                <a href="https://prepmyapp.com/terms" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Terms</a>
-                <span style="color: #4B5563;">|</span>
-                <a href="mailto:info@prepmy.app" style="color: #7DD3FC; text-decoration: none; font-size: 13px; margin: 0 12px;">Support</a>
-            </div>
-            <p style="font-size: 11px; color: #6B7280; margin: 0;">
-                © 2025 PrepMyApp LLC · <a href="mailto:info@prepmy.app" style="color: #7DD3FC; text-decoration: none;">info@prepmy.app</a>
-            </p>
-        </div>
-    </div>
-</body>
-</html>`
-}
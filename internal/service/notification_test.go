@@ -0,0 +1,393 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/templates"
+)
+
+// TestSendDefersDuringQuietHours checks that only critical notifications go
+// out during the user's quiet hours: flagged by the caller, or sent from a
+// template whose manifest marks it critical. Everything else is deferred.
+func TestSendDefersDuringQuietHours(t *testing.T) {
+	registry, err := templates.Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	userID := uuid.New()
+	now := time.Now().UTC()
+	quietStart, quietEnd := now.Add(-time.Hour), now.Add(time.Hour)
+
+	prefs := domain.NewDefaultPreferences(userID)
+	prefs.QuietHoursStart = &quietStart
+	prefs.QuietHoursEnd = &quietEnd
+
+	tests := []struct {
+		name         string
+		req          SendRequest
+		wantDeferred bool
+	}{
+		{"flagged critical", SendRequest{Critical: true}, false},
+		{"critical template", SendRequest{Template: "otp_verification", Data: map[string]interface{}{"otp": "123456"}}, false},
+		{"not critical", SendRequest{}, true},
+		{"template not critical", SendRequest{Template: "interview_reminder", Data: map[string]interface{}{
+			"company": "Acme", "job_title": "Engineer", "starts_at": "10:00",
+		}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := &fakeJobRepo{}
+			svc := NewNotificationService(nil, jobs, nil, &fakePreferencesRepo{prefs: prefs}, nil, &fakeEmailSender{}, nil, nil, registry, nil)
+
+			req := tt.req
+			req.UserID = userID
+			req.Channels = []domain.NotificationType{domain.NotificationTypeEmail}
+			req.Email = "user@example.com"
+			req.Title, req.Body = "Hello", "Body"
+
+			result, err := svc.Send(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if len(jobs.enqueued) != 1 || len(result.Notifications) != 1 {
+				t.Fatalf("queued %d jobs, want 1", len(jobs.enqueued))
+			}
+
+			n := result.Notifications[0]
+			if n.IsScheduled() != tt.wantDeferred {
+				t.Errorf("deferred = %v (until %v), want %v", n.IsScheduled(), n.ScheduledAt, tt.wantDeferred)
+			}
+			if tt.wantDeferred && n.DeferReason != domain.DeferReasonQuietHours {
+				t.Errorf("defer reason = %q, want %q", n.DeferReason, domain.DeferReasonQuietHours)
+			}
+		})
+	}
+}
+
+func TestSendIdempotencyKeys(t *testing.T) {
+	expiresAt := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		req        SendRequest
+		wantKeys   []string
+		wantExpiry bool
+	}{
+		{"one key per channel", SendRequest{
+			Channels:       []domain.NotificationType{domain.NotificationTypeEmail, domain.NotificationTypeInApp},
+			IdempotencyKey: "notify:abc", IdempotencyExpiresAt: expiresAt,
+		}, []string{"notify:abc:email", "notify:abc:in_app"}, true},
+		{"one key per fallback chain", SendRequest{
+			Channels:       []domain.NotificationType{domain.NotificationTypeInApp, domain.NotificationTypeEmail},
+			IdempotencyKey: "notify:abc", IdempotencyExpiresAt: expiresAt, Fallback: true,
+		}, []string{"notify:abc"}, true},
+		{"key that never expires", SendRequest{
+			Channels:       []domain.NotificationType{domain.NotificationTypeInApp},
+			IdempotencyKey: "workflow:run:step",
+		}, []string{"workflow:run:step:in_app"}, false},
+		{"no key", SendRequest{
+			Channels: []domain.NotificationType{domain.NotificationTypeInApp},
+		}, []string{""}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := &fakeJobRepo{}
+			svc := NewNotificationService(newFakeNotificationRepo(), jobs, nil, nil, nil, &fakeEmailSender{}, nil, nil, nil, nil)
+
+			req := tt.req
+			req.UserID = uuid.New()
+			req.Email = "user@example.com"
+			req.Title, req.Body = "Hello", "Body"
+
+			if _, err := svc.Send(context.Background(), req); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			if len(jobs.queued) != len(tt.wantKeys) {
+				t.Fatalf("queued %d notifications, want %d", len(jobs.queued), len(tt.wantKeys))
+			}
+			for i, n := range jobs.queued {
+				if n.IdempotencyKey != tt.wantKeys[i] {
+					t.Errorf("notification %d key = %q, want %q", i, n.IdempotencyKey, tt.wantKeys[i])
+				}
+				if (n.IdempotencyExpiresAt != nil) != tt.wantExpiry {
+					t.Errorf("notification %d key expires at %v, want expiry: %v", i, n.IdempotencyExpiresAt, tt.wantExpiry)
+				}
+			}
+		})
+	}
+}
+
+// TestSendReturnsOriginalAfterFallback checks that a repeated request finds
+// the notification it created even after a fallback moved it to another type.
+func TestSendReturnsOriginalAfterFallback(t *testing.T) {
+	notifications := newFakeNotificationRepo()
+	jobs := &fakeJobRepo{}
+	svc := NewNotificationService(notifications, jobs, nil, nil, nil, &fakeEmailSender{}, nil, nil, nil, nil)
+
+	req := SendRequest{
+		UserID:         uuid.New(),
+		Channels:       []domain.NotificationType{domain.NotificationTypeInApp, domain.NotificationTypeEmail},
+		Email:          "user@example.com",
+		Title:          "Hello",
+		Body:           "Body",
+		IdempotencyKey: "notify:abc",
+		Fallback:       true,
+	}
+
+	first, err := svc.Send(context.Background(), req)
+	if err != nil {
+		t.Fatalf("first Send: %v", err)
+	}
+
+	// The worker moved it on to email
+	original := *first.Notifications[0]
+	original.Type = domain.NotificationTypeEmail
+	notifications.notifications[original.ID] = &original
+
+	repeat, err := svc.Send(context.Background(), req)
+	if err != nil {
+		t.Fatalf("repeated Send: %v", err)
+	}
+	if len(jobs.enqueued) != 1 {
+		t.Errorf("queued %d jobs, want 1", len(jobs.enqueued))
+	}
+	if len(repeat.Notifications) != 1 || repeat.Notifications[0].ID != original.ID {
+		t.Errorf("repeat returned %v, want the original notification %s", repeat.Notifications, original.ID)
+	}
+}
+
+// inApp returns an unread in-app notification for userID created age ago.
+func inApp(userID uuid.UUID, age time.Duration) *domain.Notification {
+	n := domain.NewNotification(userID, domain.NotificationTypeInApp, "", "Hello", "Body")
+	n.CreatedAt = time.Now().Add(-age)
+	return n
+}
+
+// eventTypes lists the types of events, in order.
+func eventTypes(events []*domain.Event) []domain.EventType {
+	types := make([]domain.EventType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+// TestMarkAsReadEmitsEvents checks that marking notifications as read tells
+// the user's clients what was read and what their unread count is now.
+func TestMarkAsReadEmitsEvents(t *testing.T) {
+	userID := uuid.New()
+	first, second := inApp(userID, time.Minute), inApp(userID, time.Minute)
+
+	tests := []struct {
+		name        string
+		markRead    func(svc *NotificationService) error
+		wantPayload domain.NotificationReadPayload
+		wantCount   int64
+	}{
+		{
+			"one",
+			func(svc *NotificationService) error { return svc.MarkAsRead(context.Background(), userID, first.ID) },
+			domain.NotificationReadPayload{NotificationID: &first.ID},
+			1,
+		},
+		{
+			"all",
+			func(svc *NotificationService) error { return svc.MarkAllAsRead(context.Background(), userID) },
+			domain.NotificationReadPayload{All: true},
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := *first, *second
+			notifier := &fakeNotifier{}
+			svc := NewNotificationService(newFakeNotificationRepo(&a, &b), nil, nil, nil, nil, nil, nil, notifier, nil, nil)
+
+			if err := tt.markRead(svc); err != nil {
+				t.Fatalf("mark read: %v", err)
+			}
+
+			events := notifier.emitted(userID)
+			if len(events) != 2 {
+				t.Fatalf("emitted %v, want notification.read then unread_count.changed", eventTypes(events))
+			}
+
+			read, ok := events[0].Payload.(domain.NotificationReadPayload)
+			if events[0].Type != domain.EventNotificationRead || !ok {
+				t.Fatalf("first event = %s with %T, want %s", events[0].Type, events[0].Payload, domain.EventNotificationRead)
+			}
+			if read.All != tt.wantPayload.All {
+				t.Errorf("all = %v, want %v", read.All, tt.wantPayload.All)
+			}
+			if (read.NotificationID == nil) != (tt.wantPayload.NotificationID == nil) ||
+				read.NotificationID != nil && *read.NotificationID != *tt.wantPayload.NotificationID {
+				t.Errorf("notification_id = %v, want %v", read.NotificationID, tt.wantPayload.NotificationID)
+			}
+			if read.ReadAt.IsZero() {
+				t.Error("read_at not set")
+			}
+
+			count, ok := events[1].Payload.(domain.UnreadCountPayload)
+			if events[1].Type != domain.EventUnreadCountChanged || !ok {
+				t.Fatalf("second event = %s with %T, want %s", events[1].Type, events[1].Payload, domain.EventUnreadCountChanged)
+			}
+			if count.Count != tt.wantCount {
+				t.Errorf("unread count = %d, want %d", count.Count, tt.wantCount)
+			}
+		})
+	}
+}
+
+// TestDeleteOlderThanEmitsDeleted checks that retention cleanup tells each
+// user's clients which of their in-app notifications are gone, followed by
+// their new unread count, and stays quiet about other channels.
+func TestDeleteOlderThanEmitsDeleted(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+
+	old := inApp(alice, 40*24*time.Hour)
+	recent := inApp(alice, time.Hour)
+	oldEmail := domain.NewNotification(bob, domain.NotificationTypeEmail, "", "Hello", "Body")
+	oldEmail.CreatedAt = old.CreatedAt
+
+	notifier := &fakeNotifier{}
+	svc := NewNotificationService(newFakeNotificationRepo(old, recent, oldEmail), nil, nil, nil, nil, nil, nil, notifier, nil, nil)
+
+	deleted, err := svc.DeleteOlderThan(context.Background(), 30)
+	if err != nil {
+		t.Fatalf("DeleteOlderThan: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d notifications, want 2", deleted)
+	}
+
+	events := notifier.emitted(alice)
+	if len(events) != 2 {
+		t.Fatalf("emitted %v to alice, want notification.deleted then unread_count.changed", eventTypes(events))
+	}
+	payload, ok := events[0].Payload.(domain.NotificationDeletedPayload)
+	if events[0].Type != domain.EventNotificationDeleted || !ok || payload.NotificationID != old.ID {
+		t.Errorf("first event = %s %+v, want %s for %s", events[0].Type, events[0].Payload, domain.EventNotificationDeleted, old.ID)
+	}
+	if count, ok := events[1].Payload.(domain.UnreadCountPayload); !ok || count.Count != 1 {
+		t.Errorf("second event = %s %+v, want unread count 1", events[1].Type, events[1].Payload)
+	}
+
+	if events := notifier.emitted(bob); len(events) != 0 {
+		t.Errorf("emitted %v for an email notification, want nothing", eventTypes(events))
+	}
+}
+
+// TestSendAddsUnsubscribeLink checks that only non-critical emails with an
+// explicit channel get a List-Unsubscribe link.
+func TestSendAddsUnsubscribeLink(t *testing.T) {
+	unsubscribe := NewUnsubscribeService(nil, "secret", "https://notify.example.com")
+
+	tests := []struct {
+		name     string
+		req      SendRequest
+		wantLink bool
+	}{
+		{"channel", SendRequest{Channel: "marketing"}, true},
+		{"critical", SendRequest{Channel: "marketing", Critical: true}, false},
+		{"template without channel", SendRequest{Template: "interview_reminder", Data: map[string]interface{}{
+			"company": "Acme", "job_title": "Engineer", "starts_at": "10:00",
+		}}, false},
+		{"neither", SendRequest{}, false},
+	}
+
+	registry, err := templates.Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := &fakeJobRepo{}
+			svc := NewNotificationService(nil, jobs, nil, nil, nil, &fakeEmailSender{}, nil, nil, registry, unsubscribe)
+
+			req := tt.req
+			req.UserID = uuid.New()
+			req.Channels = []domain.NotificationType{domain.NotificationTypeEmail}
+			req.Email = "user@example.com"
+			req.Title, req.Body = "Hello", "Body"
+
+			if _, err := svc.Send(context.Background(), req); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if len(jobs.enqueued) != 1 {
+				t.Fatalf("queued %d jobs, want 1", len(jobs.enqueued))
+			}
+
+			link := jobs.enqueued[0].Payload.UnsubscribeURL
+			if (link != "") != tt.wantLink {
+				t.Errorf("unsubscribe link = %q, want one: %v", link, tt.wantLink)
+			}
+			if tt.wantLink && link != unsubscribe.URL(req.UserID, req.Channel) {
+				t.Errorf("unsubscribe link = %q, want %q", link, unsubscribe.URL(req.UserID, req.Channel))
+			}
+		})
+	}
+}
+
+// TestSendLocalePrecedence checks that an explicit locale wins over the
+// user's preference, which wins over the default locale.
+func TestSendLocalePrecedence(t *testing.T) {
+	registry, err := templates.Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	const german, english = "Dein PrepMyApp-Bestätigungscode", "Your PrepMyApp verification code"
+
+	tests := []struct {
+		name        string
+		requested   string
+		preferred   string
+		wantSubject string
+	}{
+		{"preference", "", "de-AT", german},
+		{"explicit locale over preference", "en", "de", english},
+		{"explicit locale without preference", "de", "", german},
+		{"neither", "", "", english},
+		{"untranslated preference", "", "fr", english},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			prefs := domain.NewDefaultPreferences(userID)
+			prefs.Locale = tt.preferred
+
+			jobs := &fakeJobRepo{}
+			svc := NewNotificationService(nil, jobs, nil, &fakePreferencesRepo{prefs: prefs}, nil, &fakeEmailSender{}, nil, nil, registry, nil)
+
+			_, err := svc.Send(context.Background(), SendRequest{
+				UserID:   userID,
+				Email:    "user@example.com",
+				Channels: []domain.NotificationType{domain.NotificationTypeEmail},
+				Template: "otp_verification",
+				Data:     map[string]interface{}{"otp": "123456"},
+				Locale:   tt.requested,
+			})
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if len(jobs.queued) != 1 {
+				t.Fatalf("queued %d notifications, want 1", len(jobs.queued))
+			}
+			if got := jobs.queued[0].Title; got != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", got, tt.wantSubject)
+			}
+		})
+	}
+}
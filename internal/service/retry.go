@@ -0,0 +1,46 @@
+package service
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy decides how often and how quickly failed deliveries are retried.
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts, including the first one
+	BaseDelay   time.Duration // Delay before the first retry
+	MaxDelay    time.Duration // Upper bound for any single delay
+}
+
+// DefaultRetryPolicy returns sensible defaults: 5 attempts spread over roughly 8 minutes.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    time.Hour,
+	}
+}
+
+// Backoff returns the delay before the next attempt, given how many attempts
+// have been made so far. The delay doubles each time (BaseDelay, 2x, 4x, ...)
+// up to MaxDelay, plus up to 20% random jitter so that jobs which failed
+// together (e.g. during a provider outage) don't all retry in the same second.
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if jitter := int64(delay / 5); jitter > 0 {
+		delay += time.Duration(rand.Int64N(jitter)) //nolint:gosec // jitter doesn't need a CSPRNG
+	}
+
+	return delay
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration // Before jitter
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{50, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		// Jitter adds up to 20%; sample enough to see it vary
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 100; i++ {
+			delay := policy.Backoff(tt.attempts)
+			if delay < tt.want || delay >= tt.want+tt.want/5 {
+				t.Fatalf("Backoff(%d) = %s, want within [%s, %s)", tt.attempts, delay, tt.want, tt.want+tt.want/5)
+			}
+			seen[delay] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("Backoff(%d) returned the same delay every time, want jitter", tt.attempts)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffWithoutJitter(t *testing.T) {
+	// Below 5ns a fifth of the delay rounds down to nothing
+	policy := RetryPolicy{BaseDelay: time.Nanosecond, MaxDelay: 4 * time.Nanosecond}
+
+	for attempts, want := range map[int]time.Duration{1: 1, 2: 2, 3: 4, 4: 4} {
+		if got := policy.Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
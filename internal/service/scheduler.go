@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// schedulerBatchSize is how many due jobs are released per statement.
+const schedulerBatchSize = 100
+
+// Scheduler periodically releases scheduled notifications whose send time has
+// come into the delivery queue, where the DeliveryWorkerPool picks them up.
+type Scheduler struct {
+	jobs     domain.DeliveryJobRepository
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewScheduler creates a scheduler that checks for due notifications every interval.
+func NewScheduler(jobs domain.DeliveryJobRepository, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	return &Scheduler{
+		jobs:     jobs,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start launches the scheduler loop in the background.
+func (s *Scheduler) Start() {
+	go s.run()
+}
+
+// Stop stops the scheduler loop and waits for it to exit, or for ctx to expire.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	close(s.stop)
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run is the scheduler loop.
+func (s *Scheduler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.releaseDue(context.Background())
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// releaseDue releases due notifications batch by batch until none are left.
+func (s *Scheduler) releaseDue(ctx context.Context) {
+	for {
+		released, err := s.jobs.ReleaseScheduled(ctx, time.Now(), schedulerBatchSize)
+		if err != nil {
+			log.Printf("[Scheduler] failed to release scheduled notifications: %v", err)
+			return
+		}
+
+		if released > 0 {
+			log.Printf("[Scheduler] released %d scheduled notification(s) for delivery", released)
+		}
+
+		if released < schedulerBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// fakeScheduledJobs releases up to limit of its due jobs per call.
+type fakeScheduledJobs struct {
+	domain.DeliveryJobRepository
+
+	due   int
+	err   error
+	calls int
+}
+
+func (r *fakeScheduledJobs) ReleaseScheduled(ctx context.Context, now time.Time, limit int) (int64, error) {
+	r.calls++
+	if r.err != nil {
+		return 0, r.err
+	}
+	released := min(r.due, limit)
+	r.due -= released
+	return int64(released), nil
+}
+
+func TestSchedulerReleaseDue(t *testing.T) {
+	tests := []struct {
+		name      string
+		due       int
+		err       error
+		wantCalls int
+		wantLeft  int
+	}{
+		{"nothing due", 0, nil, 1, 0},
+		{"one short batch", 3, nil, 1, 0},
+		{"several batches", 2*schedulerBatchSize + 5, nil, 3, 0},
+		// A full last batch needs one more call to find out nothing is left
+		{"exactly full batches", 2 * schedulerBatchSize, nil, 3, 0},
+		{"error stops the round", 5, errors.New("connection refused"), 1, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := &fakeScheduledJobs{due: tt.due, err: tt.err}
+			NewScheduler(jobs, time.Second).releaseDue(context.Background())
+
+			if jobs.calls != tt.wantCalls {
+				t.Errorf("ReleaseScheduled calls = %d, want %d", jobs.calls, tt.wantCalls)
+			}
+			if jobs.due != tt.wantLeft {
+				t.Errorf("%d jobs left due, want %d", jobs.due, tt.wantLeft)
+			}
+		})
+	}
+}
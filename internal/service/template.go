@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/templates"
+)
+
+// testSubjectPrefix marks test sends so they can't be mistaken for real mail.
+const testSubjectPrefix = "[TEST] "
+
+// PreviewTemplate renders a template with data without sending anything.
+// Returns ErrNotFound for unknown templates and *templates.ErrMissingVariables
+// if data lacks any of the template's required variables.
+func (s *NotificationService) PreviewTemplate(name, locale string, data map[string]interface{}) (*templates.Rendered, error) {
+	if s.templates == nil || !s.templates.Has(name) {
+		return nil, domain.NewErrNotFound("template", name)
+	}
+
+	return s.templates.Render(name, locale, data)
+}
+
+// SendTestEmail renders a template and emails the result straight to one
+// address. Unlike Send, nothing is stored or queued: the provider is called
+// synchronously so the caller sees delivery errors immediately.
+func (s *NotificationService) SendTestEmail(ctx context.Context, name, locale, to string, data map[string]interface{}) (*templates.Rendered, error) {
+	if s.emailSender == nil {
+		return nil, fmt.Errorf("email sender not configured")
+	}
+
+	rendered, err := s.PreviewTemplate(name, locale, data)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[NotificationService] Sending test email for template %s (%s) to %s", name, rendered.Locale, to)
+
+	_, err = s.emailSender.SendEmail(ctx, &domain.EmailMessage{
+		To:      to,
+		Subject: testSubjectPrefix + rendered.Subject,
+		Text:    rendered.Text,
+		HTML:    rendered.HTML,
+	})
+	if err != nil {
+		return rendered, fmt.Errorf("failed to send test email: %w", err)
+	}
+
+	return rendered, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// UnsubscribeService issues and redeems the tokens behind the unsubscribe
+// links in emails. A token names a user and a notification channel (e.g.
+// "marketing") and is signed, so it needs no storage and can't be forged or
+// altered to unsubscribe someone else. Tokens don't expire: an unsubscribe
+// link has to keep working for as long as the email sits in an inbox.
+type UnsubscribeService struct {
+	preferencesRepo domain.PreferencesRepository
+	secret          []byte
+	baseURL         string
+}
+
+// NewUnsubscribeService creates an unsubscribe service signing tokens with
+// secret. baseURL is where this service is publicly reachable; links point
+// to baseURL + "/unsubscribe/<token>".
+func NewUnsubscribeService(preferencesRepo domain.PreferencesRepository, secret, baseURL string) *UnsubscribeService {
+	return &UnsubscribeService{
+		preferencesRepo: preferencesRepo,
+		secret:          []byte(secret),
+		baseURL:         strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// Token returns the unsubscribe token for a user and channel.
+func (s *UnsubscribeService) Token(userID uuid.UUID, channel string) string {
+	payload := []byte(userID.String() + ":" + channel)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+// URL returns the unsubscribe link for a user and channel.
+func (s *UnsubscribeService) URL(userID uuid.UUID, channel string) string {
+	return s.baseURL + "/unsubscribe/" + s.Token(userID, channel)
+}
+
+// Parse verifies a token and returns the user and channel it was issued for.
+// Returns ErrValidation if the token is malformed or its signature is wrong.
+func (s *UnsubscribeService) Parse(token string) (uuid.UUID, string, error) {
+	invalid := domain.NewErrValidation("invalid unsubscribe token")
+
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, "", invalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return uuid.Nil, "", invalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil || !hmac.Equal(sig, s.sign(payload)) {
+		return uuid.Nil, "", invalid
+	}
+
+	rawUserID, channel, ok := strings.Cut(string(payload), ":")
+	if !ok || channel == "" {
+		return uuid.Nil, "", invalid
+	}
+	userID, err := uuid.Parse(rawUserID)
+	if err != nil {
+		return uuid.Nil, "", invalid
+	}
+
+	return userID, channel, nil
+}
+
+// Unsubscribe turns off the channel a token was issued for in its user's
+// preferences and returns the channel. Unsubscribing twice is harmless.
+// Returns ErrValidation if the token is invalid.
+func (s *UnsubscribeService) Unsubscribe(ctx context.Context, token string) (string, error) {
+	userID, channel, err := s.Parse(token)
+	if err != nil {
+		return "", err
+	}
+
+	prefs, err := s.preferencesRepo.Get(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get preferences: %w", err)
+	}
+
+	if !prefs.IsChannelEnabled(channel) {
+		return channel, nil
+	}
+
+	if prefs.ChannelSettings == nil {
+		prefs.ChannelSettings = make(map[string]bool)
+	}
+	prefs.ChannelSettings[channel] = false
+
+	if err := s.preferencesRepo.Upsert(ctx, prefs); err != nil {
+		return "", fmt.Errorf("failed to update preferences: %w", err)
+	}
+
+	log.Printf("[Unsubscribe] User %s unsubscribed from %s notifications", userID, channel)
+	return channel, nil
+}
+
+// sign returns the HMAC-SHA256 of a token payload.
+func (s *UnsubscribeService) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+func TestUnsubscribeTokenRoundTrip(t *testing.T) {
+	s := NewUnsubscribeService(nil, "secret", "https://notify.example.com/")
+	userID := uuid.New()
+
+	token := s.Token(userID, "marketing")
+	gotUser, gotChannel, err := s.Parse(token)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if gotUser != userID || gotChannel != "marketing" {
+		t.Errorf("Parse = %s, %q, want %s, %q", gotUser, gotChannel, userID, "marketing")
+	}
+
+	if got, want := s.URL(userID, "marketing"), "https://notify.example.com/unsubscribe/"+token; got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+}
+
+func TestUnsubscribeTokenRejectsInvalid(t *testing.T) {
+	s := NewUnsubscribeService(nil, "secret", "")
+	userID := uuid.New()
+	token := s.Token(userID, "marketing")
+	encodedPayload, encodedSig, _ := strings.Cut(token, ".")
+
+	// signed builds a correctly signed token around payload
+	signed := func(payload string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+			base64.RawURLEncoding.EncodeToString(s.sign([]byte(payload)))
+	}
+	otherUser := base64.RawURLEncoding.EncodeToString([]byte(uuid.NewString() + ":marketing"))
+	tamperedSig := []byte(encodedSig)
+	tamperedSig[0] ^= 1
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"tampered payload", otherUser + "." + encodedSig},
+		{"tampered signature", encodedPayload + "." + string(tamperedSig)},
+		{"other secret", NewUnsubscribeService(nil, "other", "").Token(userID, "marketing")},
+		{"missing signature", encodedPayload},
+		{"not base64", "!!!." + encodedSig},
+		{"bad user ID", signed("not-a-uuid:marketing")},
+		{"empty channel", signed(userID.String() + ":")},
+		{"no channel", signed(userID.String())},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := s.Parse(tt.token)
+			if _, ok := err.(*domain.ErrValidation); !ok {
+				t.Errorf("Parse error = %v, want a validation error", err)
+			}
+		})
+	}
+}
+
+// TestUnsubscribeIsIdempotent checks that using a link again leaves the
+// preferences alone.
+func TestUnsubscribeIsIdempotent(t *testing.T) {
+	userID := uuid.New()
+	prefs := &fakePreferencesRepo{prefs: domain.NewDefaultPreferences(userID)}
+	s := NewUnsubscribeService(prefs, "secret", "")
+	token := s.Token(userID, "marketing")
+
+	for i := 0; i < 2; i++ {
+		channel, err := s.Unsubscribe(context.Background(), token)
+		if err != nil {
+			t.Fatalf("Unsubscribe #%d: %v", i+1, err)
+		}
+		if channel != "marketing" {
+			t.Errorf("Unsubscribe #%d channel = %q, want marketing", i+1, channel)
+		}
+	}
+
+	if prefs.prefs.IsChannelEnabled("marketing") {
+		t.Error("marketing still enabled")
+	}
+	if prefs.upserts != 1 {
+		t.Errorf("preferences saved %d times, want 1", prefs.upserts)
+	}
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// DeliveryWorkerConfig controls how the delivery worker pool drains the outbox.
+type DeliveryWorkerConfig struct {
+	Workers      int           // Number of concurrent workers
+	BatchSize    int           // Jobs claimed per poll
+	PollInterval time.Duration // Wait between polls when the queue is empty
+	LockTimeout  time.Duration // After this, a claimed job is considered abandoned
+	Retry        RetryPolicy
+}
+
+// DeliveryWorkerPool claims queued delivery jobs and sends them through the
+// configured providers. Several instances can run against the same database.
+type DeliveryWorkerPool struct {
+	service  *NotificationService
+	jobs     domain.DeliveryJobRepository
+	cfg      DeliveryWorkerConfig
+	hostname string
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewDeliveryWorkerPool creates a new delivery worker pool.
+func NewDeliveryWorkerPool(svc *NotificationService, jobs domain.DeliveryJobRepository, cfg DeliveryWorkerConfig) *DeliveryWorkerPool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = 5 * time.Minute
+	}
+	if cfg.Retry.MaxAttempts <= 0 {
+		cfg.Retry = DefaultRetryPolicy()
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+
+	return &DeliveryWorkerPool{
+		service:  svc,
+		jobs:     jobs,
+		cfg:      cfg,
+		hostname: hostname,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start launches the workers in the background.
+func (p *DeliveryWorkerPool) Start() {
+	for i := 0; i < p.cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.run(fmt.Sprintf("%s-%d", p.hostname, i))
+	}
+}
+
+// Stop tells the workers to stop claiming new jobs and waits for in-flight
+// deliveries to finish, or for ctx to expire.
+func (p *DeliveryWorkerPool) Stop(ctx context.Context) error {
+	close(p.stop)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run is the main loop of a single worker.
+func (p *DeliveryWorkerPool) run(workerID string) {
+	defer p.wg.Done()
+
+	// Deliveries use a background context so shutdown never aborts a send halfway
+	ctx := context.Background()
+
+	for {
+		if p.stopping() {
+			return
+		}
+
+		jobs, err := p.jobs.Claim(ctx, workerID, p.cfg.BatchSize, p.cfg.LockTimeout)
+		if err != nil {
+			log.Printf("[DeliveryWorker] %s: failed to claim jobs: %v", workerID, err)
+		}
+
+		for i, job := range jobs {
+			if p.stopping() {
+				// Hand the rest of the batch back so another worker can pick it up
+				p.release(ctx, workerID, jobs[i:])
+				return
+			}
+			p.process(ctx, workerID, job)
+		}
+
+		// Poll again right away if the batch was full, there may be more waiting
+		if len(jobs) == p.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(p.cfg.PollInterval):
+		}
+	}
+}
+
+// process drives a single job through the notification status lifecycle:
+// pending -> sending -> sent, or failed -> (retry) -> ... -> dead_lettered.
+// Emails to suppressed addresses end up suppressed instead of being sent.
+func (p *DeliveryWorkerPool) process(ctx context.Context, workerID string, job *domain.DeliveryJob) {
+	repo := p.service.notificationRepo
+
+	n, err := repo.GetByID(ctx, job.NotificationID)
+	if err != nil {
+		if _, ok := err.(*domain.ErrNotFound); ok {
+			// Notification was deleted, nothing left to deliver
+			p.complete(ctx, workerID, job)
+			return
+		}
+		log.Printf("[DeliveryWorker] failed to load notification %s: %v", job.NotificationID, err)
+		p.release(ctx, workerID, []*domain.DeliveryJob{job})
+		return
+	}
+
+	// A previous worker delivered this but died before completing the job
+	if n.Status == domain.NotificationStatusSent || n.Status == domain.NotificationStatusDelivered {
+		p.complete(ctx, workerID, job)
+		return
+	}
+
+	if err := repo.UpdateStatus(ctx, n.ID, domain.NotificationStatusSending); err != nil {
+		log.Printf("failed to update notification status to sending: %v", err)
+	}
+
+	if err := p.service.deliver(ctx, n, job.Payload); err != nil {
+		p.fail(ctx, workerID, job, n, err)
+		return
+	}
+
+	if err := repo.UpdateStatus(ctx, n.ID, domain.NotificationStatusSent); err != nil {
+		log.Printf("failed to update notification status to sent: %v", err)
+	}
+
+	p.complete(ctx, workerID, job)
+}
+
+// fail schedules a retry for transient errors. When the error is permanent or
+// the job has used up its attempts, the notification moves on to its next
+// fallback channel, or the job is dead-lettered if there is none. Emails to
+// suppressed addresses and channels that can't reach the user at all (push
+// without an installed app) are finished instead: requeueing them from the
+// dead-letter queue could never succeed.
+//
+// The job is updated before the notification, so a worker that lost the job
+// to another one (see ErrLeaseLost) leaves the new owner's state alone.
+func (p *DeliveryWorkerPool) fail(ctx context.Context, workerID string, job *domain.DeliveryJob, n *domain.Notification, deliveryErr error) {
+	repo := p.service.notificationRepo
+
+	// The address stays suppressed however often the email is retried
+	var suppressed *domain.ErrSuppressed
+	isSuppressed := errors.As(deliveryErr, &suppressed)
+
+	if !isSuppressed && domain.IsRetryable(deliveryErr) && job.Attempts < p.cfg.Retry.MaxAttempts {
+		nextAttemptAt := time.Now().Add(p.cfg.Retry.Backoff(job.Attempts))
+		log.Printf("[DeliveryWorker] %s notification %s failed (attempt %d/%d), retrying at %s: %v",
+			n.Type, n.ID, job.Attempts, p.cfg.Retry.MaxAttempts, nextAttemptAt.Format(time.RFC3339), deliveryErr)
+
+		if err := p.jobs.Retry(ctx, job.ID, workerID, nextAttemptAt, deliveryErr.Error()); err != nil {
+			p.logJobError(workerID, job, "schedule retry for", err)
+			return
+		}
+		if err := repo.UpdateStatus(ctx, n.ID, domain.NotificationStatusFailed); err != nil {
+			log.Printf("failed to update notification status to failed: %v", err)
+		}
+		return
+	}
+
+	// Out of luck on this channel - try the next one of the chain, if any
+	if len(job.Payload.Fallback) > 0 {
+		err := p.service.fallback(ctx, workerID, n, job, deliveryErr)
+		if err == nil {
+			return
+		}
+		if errors.Is(err, domain.ErrLeaseLost) {
+			p.logJobError(workerID, job, "fall back", err)
+			return
+		}
+		log.Printf("[DeliveryWorker] failed to fall back notification %s: %v", n.ID, err)
+	}
+
+	if isSuppressed {
+		p.finish(ctx, workerID, job, n, domain.NotificationStatusSuppressed, deliveryErr)
+		return
+	}
+	if errors.Is(deliveryErr, domain.ErrChannelUnavailable) {
+		p.finish(ctx, workerID, job, n, domain.NotificationStatusFailed, deliveryErr)
+		return
+	}
+
+	if err := p.jobs.DeadLetter(ctx, job.ID, workerID, deliveryErr.Error()); err != nil {
+		p.logJobError(workerID, job, "dead-letter", err)
+		return
+	}
+
+	log.Printf("[DeliveryWorker] %s notification %s dead-lettered after %d attempt(s): %v",
+		n.Type, n.ID, job.Attempts, deliveryErr)
+
+	if err := repo.UpdateStatus(ctx, n.ID, domain.NotificationStatusDeadLettered); err != nil {
+		log.Printf("failed to update notification status to dead_lettered: %v", err)
+	}
+}
+
+// finish ends a job whose notification can't be delivered, leaving the
+// notification in status with the reason recorded in its metadata.
+func (p *DeliveryWorkerPool) finish(ctx context.Context, workerID string, job *domain.DeliveryJob, n *domain.Notification, status domain.NotificationStatus, reason error) {
+	repo := p.service.notificationRepo
+
+	if !p.complete(ctx, workerID, job) {
+		return
+	}
+
+	log.Printf("[DeliveryWorker] %s notification %s not sent (%s): %v", n.Type, n.ID, status, reason)
+
+	if err := repo.UpdateStatus(ctx, n.ID, status); err != nil {
+		log.Printf("failed to update notification status to %s: %v", status, err)
+	}
+	if err := repo.UpdateMetadata(ctx, n.ID, map[string]interface{}{domain.FailureReasonKey: reason.Error()}); err != nil {
+		log.Printf("[DeliveryWorker] failed to record failure reason for notification %s: %v", n.ID, err)
+	}
+}
+
+// complete marks a job as done, and reports whether the worker still held it.
+func (p *DeliveryWorkerPool) complete(ctx context.Context, workerID string, job *domain.DeliveryJob) bool {
+	if err := p.jobs.Complete(ctx, job.ID, workerID); err != nil {
+		p.logJobError(workerID, job, "complete", err)
+		return false
+	}
+	return true
+}
+
+// release returns claimed jobs to the queue.
+func (p *DeliveryWorkerPool) release(ctx context.Context, workerID string, jobs []*domain.DeliveryJob) {
+	for _, job := range jobs {
+		if err := p.jobs.Release(ctx, job.ID, workerID); err != nil {
+			p.logJobError(workerID, job, "release", err)
+		}
+	}
+}
+
+// logJobError logs a failed job update. Losing the lease isn't a failure of
+// the worker: its delivery took longer than LockTimeout and the job is
+// another worker's now.
+func (p *DeliveryWorkerPool) logJobError(workerID string, job *domain.DeliveryJob, action string, err error) {
+	if errors.Is(err, domain.ErrLeaseLost) {
+		log.Printf("[DeliveryWorker] %s lost the lease on job %s before it could %s it, leaving it to its new owner", workerID, job.ID, action)
+		return
+	}
+	log.Printf("[DeliveryWorker] %s failed to %s job %s: %v", workerID, action, job.ID, err)
+}
+
+// stopping reports whether Stop has been called.
+func (p *DeliveryWorkerPool) stopping() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
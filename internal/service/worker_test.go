@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// newTestWorkerPool creates a pool whose service delivers through the fakes.
+func newTestWorkerPool(notifications *fakeNotificationRepo, jobs *fakeJobRepo, suppressions domain.SuppressionRepository, email EmailSender, push PushSender) *DeliveryWorkerPool {
+	svc := NewNotificationService(notifications, jobs, nil, nil, suppressions, email, push, nil, nil, nil)
+	return NewDeliveryWorkerPool(svc, jobs, DeliveryWorkerConfig{Retry: DefaultRetryPolicy()})
+}
+
+func TestWorkerFinishesSuppressedEmail(t *testing.T) {
+	n := domain.NewNotification(uuid.New(), domain.NotificationTypeEmail, "marketing", "Hi", "Body")
+	notifications := newFakeNotificationRepo(n)
+	jobs := &fakeJobRepo{}
+	suppressions := newFakeSuppressionRepo(domain.NewSuppression("Bounced@Example.com", domain.SuppressionReasonBounce, "sendgrid"))
+	sender := &fakeEmailSender{name: "fake"}
+
+	pool := newTestWorkerPool(notifications, jobs, suppressions, sender, nil)
+
+	job := domain.NewDeliveryJob(n.ID, domain.DeliveryPayload{Email: "bounced@example.com"})
+	job.Attempts = 1 // Claim counts the attempt
+	pool.process(context.Background(), "test-worker", job)
+
+	if calls := sender.callCount(); calls != 0 {
+		t.Errorf("provider called %d times for a suppressed address, want 0", calls)
+	}
+	if jobs.completed != 1 || jobs.retried != 0 || jobs.deadLettered != 0 {
+		t.Errorf("job completed %d, retried %d, dead-lettered %d times; want 1, 0, 0",
+			jobs.completed, jobs.retried, jobs.deadLettered)
+	}
+	if status := notifications.status(n.ID); status != domain.NotificationStatusSuppressed {
+		t.Errorf("status = %s, want %s", status, domain.NotificationStatusSuppressed)
+	}
+}
+
+func TestWorkerRetriesTransientFailure(t *testing.T) {
+	n := domain.NewNotification(uuid.New(), domain.NotificationTypeEmail, "marketing", "Hi", "Body")
+	notifications := newFakeNotificationRepo(n)
+	jobs := &fakeJobRepo{}
+	sender := &fakeEmailSender{err: domain.NewRetryableError(context.DeadlineExceeded)}
+
+	pool := newTestWorkerPool(notifications, jobs, newFakeSuppressionRepo(), sender, nil)
+
+	job := domain.NewDeliveryJob(n.ID, domain.DeliveryPayload{Email: "user@example.com"})
+	job.Attempts = 1
+	pool.process(context.Background(), "test-worker", job)
+
+	if jobs.retried != 1 || jobs.completed != 0 || jobs.deadLettered != 0 {
+		t.Errorf("job completed %d, retried %d, dead-lettered %d times; want 0, 1, 0",
+			jobs.completed, jobs.retried, jobs.deadLettered)
+	}
+}
+
+// TestWorkerDeadLettersUnclassifiedFailure checks that an error no provider
+// marked as transient is not retried.
+func TestWorkerDeadLettersUnclassifiedFailure(t *testing.T) {
+	n := domain.NewNotification(uuid.New(), domain.NotificationTypeEmail, "marketing", "Hi", "Body")
+	notifications := newFakeNotificationRepo(n)
+	jobs := &fakeJobRepo{}
+	sender := &fakeEmailSender{err: fmt.Errorf("unexpected response")}
+
+	pool := newTestWorkerPool(notifications, jobs, newFakeSuppressionRepo(), sender, nil)
+
+	job := domain.NewDeliveryJob(n.ID, domain.DeliveryPayload{Email: "user@example.com"})
+	job.Attempts = 1
+	pool.process(context.Background(), "test-worker", job)
+
+	if jobs.deadLettered != 1 || jobs.retried != 0 || jobs.completed != 0 {
+		t.Errorf("job completed %d, retried %d, dead-lettered %d times; want 0, 0, 1",
+			jobs.completed, jobs.retried, jobs.deadLettered)
+	}
+	if status := notifications.status(n.ID); status != domain.NotificationStatusDeadLettered {
+		t.Errorf("status = %s, want %s", status, domain.NotificationStatusDeadLettered)
+	}
+}
+
+func TestWorkerFinishesUnreachableChannel(t *testing.T) {
+	n := domain.NewNotification(uuid.New(), domain.NotificationTypePush, "alert", "Hi", "Body")
+	notifications := newFakeNotificationRepo(n)
+	jobs := &fakeJobRepo{}
+	push := &fakePushSender{err: domain.NewPermanentError(fmt.Errorf("%w: no active device tokens", domain.ErrChannelUnavailable))}
+
+	pool := newTestWorkerPool(notifications, jobs, nil, nil, push)
+
+	job := domain.NewDeliveryJob(n.ID, domain.DeliveryPayload{})
+	job.Attempts = 1
+	pool.process(context.Background(), "test-worker", job)
+
+	if jobs.completed != 1 || jobs.retried != 0 || jobs.deadLettered != 0 {
+		t.Errorf("job completed %d, retried %d, dead-lettered %d times; want 1, 0, 0",
+			jobs.completed, jobs.retried, jobs.deadLettered)
+	}
+	if status := notifications.status(n.ID); status != domain.NotificationStatusFailed {
+		t.Errorf("status = %s, want %s", status, domain.NotificationStatusFailed)
+	}
+	if reason, _ := notifications.notifications[n.ID].Metadata[domain.FailureReasonKey].(string); reason == "" {
+		t.Errorf("no %s recorded", domain.FailureReasonKey)
+	}
+}
+
+// TestWorkerLeavesLostJobAlone checks that a worker whose lock timed out
+// while it was delivering doesn't touch the notification any more: another
+// worker claimed the job and owns its state now.
+func TestWorkerLeavesLostJobAlone(t *testing.T) {
+	tests := []struct {
+		name string
+		to   string
+		err  error
+	}{
+		{"retry", "user@example.com", domain.NewRetryableError(context.DeadlineExceeded)},
+		{"dead letter", "user@example.com", domain.NewPermanentError(fmt.Errorf("invalid recipient"))},
+		{"suppressed", "bounced@example.com", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := domain.NewNotification(uuid.New(), domain.NotificationTypeEmail, "marketing", "Hi", "Body")
+			notifications := newFakeNotificationRepo(n)
+			jobs := &fakeJobRepo{leaseLost: true}
+			suppressions := newFakeSuppressionRepo(domain.NewSuppression("bounced@example.com", domain.SuppressionReasonBounce, "sendgrid"))
+			sender := &fakeEmailSender{err: tt.err}
+
+			pool := newTestWorkerPool(notifications, jobs, suppressions, sender, nil)
+
+			job := domain.NewDeliveryJob(n.ID, domain.DeliveryPayload{Email: tt.to})
+			job.Attempts = 1
+			pool.process(context.Background(), "test-worker", job)
+
+			// Sending is the last status this worker set while it still held the job
+			if status := notifications.status(n.ID); status != domain.NotificationStatusSending {
+				t.Errorf("status = %s, want %s", status, domain.NotificationStatusSending)
+			}
+			if _, ok := notifications.notifications[n.ID].Metadata[domain.FailureReasonKey]; ok {
+				t.Errorf("%s recorded by the worker that lost the job", domain.FailureReasonKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/templates"
+	"github.com/prepmyapp/notification/internal/workflows"
+)
+
+const (
+	// workflowBatchSize is how many due runs are claimed per poll.
+	workflowBatchSize = 50
+
+	// workflowLease is how long a claimed run is hidden from other engines.
+	// If an engine dies mid-step, the run is picked up again after this.
+	workflowLease = 5 * time.Minute
+)
+
+// WorkflowDefinitions looks up workflow definitions by name.
+type WorkflowDefinitions interface {
+	Get(name string) (*domain.Workflow, error)
+}
+
+// WorkflowEngine runs multi-step notification workflows. Every run is stored
+// in the database with the index of its next step and when it is due, so
+// pending steps survive restarts; the engine loop polls for due runs, checks
+// the step's condition against the notifications sent so far and sends it.
+// A run ends early as soon as the user reads any of its notifications.
+type WorkflowEngine struct {
+	service   *NotificationService
+	runs      domain.WorkflowRunRepository
+	workflows WorkflowDefinitions
+	interval  time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWorkflowEngine creates a workflow engine that checks for due steps every interval.
+func NewWorkflowEngine(svc *NotificationService, runs domain.WorkflowRunRepository, defs WorkflowDefinitions, interval time.Duration) *WorkflowEngine {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	return &WorkflowEngine{
+		service:   svc,
+		runs:      runs,
+		workflows: defs,
+		interval:  interval,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// StartRun starts the named workflow for a user. A first step without a
+// delay is sent right away, so its notifications are on the returned run.
+// Returns ErrNotFound for unknown workflows and ErrValidation for bad delays.
+func (e *WorkflowEngine) StartRun(ctx context.Context, name string, userID uuid.UUID, input domain.WorkflowInput) (*domain.WorkflowRun, error) {
+	w, err := e.workflows.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for stepName, delay := range input.Delays {
+		if !hasStep(w, stepName) {
+			return nil, domain.NewErrValidation(fmt.Sprintf("workflow %s has no step %q", name, stepName))
+		}
+		if _, err := workflows.ParseDelay(delay); err != nil {
+			return nil, domain.NewErrValidation(fmt.Sprintf("step %s: %v", stepName, err))
+		}
+	}
+
+	now := time.Now()
+	delay := stepDelay(w, input, 0)
+
+	// A run that starts right away is created already leased to us, so the
+	// engine loop doesn't pick it up while the first step is being sent
+	runNow := delay == 0
+	firstRunAt := now.Add(delay)
+	if runNow {
+		firstRunAt = now.Add(workflowLease)
+	}
+
+	run := domain.NewWorkflowRun(name, userID, input, firstRunAt)
+	if err := e.runs.Create(ctx, run); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[WorkflowEngine] Started %s run %s for user %s", name, run.ID, userID)
+
+	if runNow {
+		e.advance(ctx, run)
+	}
+
+	return run, nil
+}
+
+// GetRun retrieves a workflow run.
+func (e *WorkflowEngine) GetRun(ctx context.Context, id uuid.UUID) (*domain.WorkflowRun, error) {
+	return e.runs.GetByID(ctx, id)
+}
+
+// CancelRun stops a workflow run before its remaining steps are sent.
+func (e *WorkflowEngine) CancelRun(ctx context.Context, id uuid.UUID) error {
+	return e.runs.Cancel(ctx, id)
+}
+
+// Start launches the engine loop in the background.
+func (e *WorkflowEngine) Start() {
+	go e.run()
+}
+
+// Stop stops the engine loop and waits for it to exit, or for ctx to expire.
+func (e *WorkflowEngine) Stop(ctx context.Context) error {
+	close(e.stop)
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run is the engine loop.
+func (e *WorkflowEngine) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.processDue(context.Background())
+
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processDue advances due runs batch by batch until none are left.
+func (e *WorkflowEngine) processDue(ctx context.Context) {
+	for {
+		runs, err := e.runs.ClaimDue(ctx, time.Now(), workflowLease, workflowBatchSize)
+		if err != nil {
+			log.Printf("[WorkflowEngine] failed to claim due workflow runs: %v", err)
+			return
+		}
+
+		for _, run := range runs {
+			e.advance(ctx, run)
+		}
+
+		if len(runs) < workflowBatchSize {
+			return
+		}
+	}
+}
+
+// advance runs the next step of a run and saves where it got to.
+func (e *WorkflowEngine) advance(ctx context.Context, run *domain.WorkflowRun) {
+	if err := e.step(ctx, run); err != nil {
+		// Leave the run leased; it is retried once the lease runs out
+		log.Printf("[WorkflowEngine] %s run %s failed at step %d, retrying later: %v", run.Workflow, run.ID, run.Step, err)
+		return
+	}
+
+	if err := e.runs.Update(ctx, run); err != nil {
+		log.Printf("[WorkflowEngine] failed to save %s run %s: %v", run.Workflow, run.ID, err)
+	}
+}
+
+// step evaluates and sends the next step of run, updating run in memory.
+// An error means the step should be tried again later.
+func (e *WorkflowEngine) step(ctx context.Context, run *domain.WorkflowRun) error {
+	w, err := e.workflows.Get(run.Workflow)
+	if err != nil {
+		// The definition was removed in a deploy since the run started
+		run.Finish(domain.WorkflowRunStatusFailed, err.Error())
+		return nil
+	}
+	if run.Step >= len(w.Steps) {
+		run.Finish(domain.WorkflowRunStatusCompleted, "")
+		return nil
+	}
+
+	sent, err := e.notifications(ctx, run.NotificationIDs)
+	if err != nil {
+		return err
+	}
+
+	// Once the user has seen any notification there is nothing to escalate
+	if domain.Engaged(sent) {
+		log.Printf("[WorkflowEngine] User %s engaged with %s run %s, cancelling remaining steps", run.UserID, run.Workflow, run.ID)
+		run.Finish(domain.WorkflowRunStatusCancelled, "user engaged")
+		return nil
+	}
+
+	step := w.Steps[run.Step]
+	if step.Condition.Met(sent) {
+		if err := e.send(ctx, run, w, step); err != nil {
+			var missing *templates.ErrMissingVariables
+			if errors.As(err, &missing) || errors.Is(err, domain.ErrChannelUnavailable) {
+				// Retrying won't help - the input or the user's channels are the problem
+				run.Finish(domain.WorkflowRunStatusFailed, fmt.Sprintf("step %s: %v", step.Name, err))
+				return nil
+			}
+			return err
+		}
+	} else {
+		log.Printf("[WorkflowEngine] Skipping step %s of %s run %s: condition %s not met", step.Name, run.Workflow, run.ID, step.Condition)
+	}
+
+	run.Step++
+	if run.Step >= len(w.Steps) {
+		run.Finish(domain.WorkflowRunStatusCompleted, "")
+		return nil
+	}
+
+	run.NextRunAt = time.Now().Add(stepDelay(w, run.Input, run.Step))
+	return nil
+}
+
+// send sends a step. The idempotency key is derived from the run and step,
+// so a step retried after a crash returns the notifications it already created.
+func (e *WorkflowEngine) send(ctx context.Context, run *domain.WorkflowRun, w *domain.Workflow, step domain.WorkflowStep) error {
+	template := step.Template
+	if template == "" {
+		template = w.Template
+	}
+
+	result, err := e.service.Send(ctx, SendRequest{
+		UserID:         run.UserID,
+		Email:          run.Input.Email,
+		Channels:       step.Channels,
+		Template:       template,
+		Title:          run.Input.Title,
+		Body:           run.Input.Body,
+		Data:           run.Input.Data,
+		IdempotencyKey: fmt.Sprintf("workflow:%s:%s", run.ID, step.Name),
+		Critical:       run.Input.Critical,
+		Locale:         run.Input.Locale,
+		Fallback:       step.Fallback,
+	})
+
+	// Keep whatever was queued even if another channel failed
+	if result != nil {
+		for _, n := range result.Notifications {
+			run.NotificationIDs = append(run.NotificationIDs, n.ID)
+		}
+	}
+	if err != nil && (result == nil || len(result.Notifications) == 0) {
+		return err
+	}
+	if err != nil {
+		log.Printf("[WorkflowEngine] Step %s of %s run %s partly failed: %v", step.Name, run.Workflow, run.ID, err)
+	}
+
+	return nil
+}
+
+// notifications loads the notifications sent by a run so far. Deleted
+// notifications are left out.
+func (e *WorkflowEngine) notifications(ctx context.Context, ids []uuid.UUID) ([]*domain.Notification, error) {
+	sent := make([]*domain.Notification, 0, len(ids))
+	for _, id := range ids {
+		n, err := e.service.notificationRepo.GetByID(ctx, id)
+		if err != nil {
+			if _, ok := err.(*domain.ErrNotFound); ok {
+				continue
+			}
+			return nil, err
+		}
+		sent = append(sent, n)
+	}
+	return sent, nil
+}
+
+// stepDelay returns the delay before step i, honouring the run's overrides.
+// Delays were validated when the workflow was loaded or the run started.
+func stepDelay(w *domain.Workflow, input domain.WorkflowInput, i int) time.Duration {
+	delay := w.Steps[i].Delay
+	if override, ok := input.Delays[w.Steps[i].Name]; ok {
+		delay = override
+	}
+
+	d, _ := workflows.ParseDelay(delay)
+	return d
+}
+
+// hasStep reports whether w has a step called name.
+func hasStep(w *domain.Workflow, name string) bool {
+	for _, step := range w.Steps {
+		if step.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+	"github.com/prepmyapp/notification/internal/templates"
+)
+
+// escalation sends in-app right away and emails 30 minutes later if unread.
+var escalation = &domain.Workflow{
+	Name: "escalation",
+	Steps: []domain.WorkflowStep{
+		{Name: "notify", Channels: []domain.NotificationType{domain.NotificationTypeInApp}},
+		{Name: "email", Channels: []domain.NotificationType{domain.NotificationTypeEmail}, Delay: "30m", Condition: domain.WorkflowConditionUnread},
+	},
+}
+
+// newTestWorkflowEngine creates an engine whose service queues through the fakes.
+func newTestWorkflowEngine(t *testing.T, notifications *fakeNotificationRepo, jobs *fakeJobRepo, runs *fakeWorkflowRunRepo, defs fakeWorkflows) *WorkflowEngine {
+	t.Helper()
+	registry, err := templates.Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	svc := NewNotificationService(notifications, jobs, nil, nil, nil, &fakeEmailSender{}, nil, nil, registry, nil)
+	return NewWorkflowEngine(svc, runs, defs, time.Second)
+}
+
+// assertAbout fails unless got is within a second of want.
+func assertAbout(t *testing.T, what string, got, want time.Time) {
+	t.Helper()
+	if d := got.Sub(want); d < -time.Second || d > time.Second {
+		t.Errorf("%s = %v, want about %v", what, got, want)
+	}
+}
+
+func TestStartRunSendsFirstStepRightAway(t *testing.T) {
+	tests := []struct {
+		name      string
+		delays    map[string]string
+		wantDelay time.Duration
+	}{
+		{"step delay", nil, 30 * time.Minute},
+		{"delay override", map[string]string{"email": "10m"}, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := &fakeJobRepo{}
+			runs := &fakeWorkflowRunRepo{}
+			engine := newTestWorkflowEngine(t, newFakeNotificationRepo(), jobs, runs, fakeWorkflows{"escalation": escalation})
+
+			run, err := engine.StartRun(context.Background(), "escalation", uuid.New(), domain.WorkflowInput{
+				Email: "user@example.com", Title: "Hi", Body: "Body", Delays: tt.delays,
+			})
+			if err != nil {
+				t.Fatalf("StartRun: %v", err)
+			}
+
+			if len(run.NotificationIDs) != 1 || len(jobs.enqueued) != 1 {
+				t.Fatalf("first step queued %d notifications, want 1", len(jobs.enqueued))
+			}
+			if run.Step != 1 || run.Status != domain.WorkflowRunStatusRunning {
+				t.Errorf("run at step %d, %s; want step 1, running", run.Step, run.Status)
+			}
+			assertAbout(t, "next run", run.NextRunAt, time.Now().Add(tt.wantDelay))
+			if runs.updates != 1 {
+				t.Errorf("run saved %d times, want 1", runs.updates)
+			}
+		})
+	}
+}
+
+func TestStartRunRejectsBadInput(t *testing.T) {
+	engine := newTestWorkflowEngine(t, newFakeNotificationRepo(), &fakeJobRepo{}, &fakeWorkflowRunRepo{}, fakeWorkflows{"escalation": escalation})
+
+	tests := []struct {
+		name           string
+		workflow       string
+		delays         map[string]string
+		wantValidation bool // Otherwise ErrNotFound
+	}{
+		{"unknown workflow", "missing", nil, false},
+		{"unknown step", "escalation", map[string]string{"sms": "10m"}, true},
+		{"bad delay", "escalation", map[string]string{"email": "soon"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := engine.StartRun(context.Background(), tt.workflow, uuid.New(), domain.WorkflowInput{Delays: tt.delays})
+
+			var notFound *domain.ErrNotFound
+			var invalid *domain.ErrValidation
+			if tt.wantValidation && !errors.As(err, &invalid) {
+				t.Errorf("StartRun error = %v, want ErrValidation", err)
+			}
+			if !tt.wantValidation && !errors.As(err, &notFound) {
+				t.Errorf("StartRun error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestWorkflowEngineStep(t *testing.T) {
+	unread := domain.NewNotification(uuid.New(), domain.NotificationTypeInApp, "escalation", "Hi", "Body")
+	unread.MarkAsSent()
+	read := domain.NewNotification(uuid.New(), domain.NotificationTypeInApp, "escalation", "Hi", "Body")
+	read.MarkAsSent()
+	read.MarkAsRead()
+
+	onlyIfFailed := &domain.Workflow{Name: "only_if_failed", Steps: []domain.WorkflowStep{
+		escalation.Steps[0],
+		{Name: "email", Channels: []domain.NotificationType{domain.NotificationTypeEmail}, Condition: domain.WorkflowConditionFailed},
+		{Name: "reminder", Channels: []domain.NotificationType{domain.NotificationTypeInApp}, Delay: "1h"},
+	}}
+	otp := &domain.Workflow{Name: "otp", Template: "otp_verification", Steps: []domain.WorkflowStep{
+		{Name: "email", Channels: []domain.NotificationType{domain.NotificationTypeEmail}},
+	}}
+	defs := fakeWorkflows{"escalation": escalation, "only_if_failed": onlyIfFailed, "otp": otp}
+
+	tests := []struct {
+		name       string
+		workflow   string
+		step       int
+		sent       *domain.Notification
+		wantSent   int
+		wantStep   int
+		wantStatus domain.WorkflowRunStatus
+	}{
+		{"sends when condition holds and completes after the last step", "escalation", 1, unread, 1, 2, domain.WorkflowRunStatusCompleted},
+		{"cancelled once the user engaged", "escalation", 1, read, 0, 1, domain.WorkflowRunStatusCancelled},
+		{"skipped when condition fails", "only_if_failed", 1, unread, 0, 2, domain.WorkflowRunStatusRunning},
+		{"missing variables fail the run", "otp", 0, nil, 0, 0, domain.WorkflowRunStatusFailed},
+		{"removed workflow fails the run", "gone", 0, nil, 0, 0, domain.WorkflowRunStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifications := newFakeNotificationRepo(unread, read)
+			jobs := &fakeJobRepo{}
+			runs := &fakeWorkflowRunRepo{}
+			engine := newTestWorkflowEngine(t, notifications, jobs, runs, defs)
+
+			run := domain.NewWorkflowRun(tt.workflow, uuid.New(), domain.WorkflowInput{Email: "user@example.com", Title: "Hi", Body: "Body"}, time.Now())
+			run.Step = tt.step
+			if tt.sent != nil {
+				run.NotificationIDs = []uuid.UUID{tt.sent.ID}
+			}
+			runs.Create(context.Background(), run)
+
+			engine.advance(context.Background(), run)
+
+			if len(jobs.enqueued) != tt.wantSent {
+				t.Errorf("queued %d notifications, want %d", len(jobs.enqueued), tt.wantSent)
+			}
+			if run.Step != tt.wantStep || run.Status != tt.wantStatus {
+				t.Errorf("run at step %d, %s (%s); want step %d, %s", run.Step, run.Status, run.Outcome, tt.wantStep, tt.wantStatus)
+			}
+			if tt.wantStatus == domain.WorkflowRunStatusFailed && run.Outcome == "" {
+				t.Error("failed run has no outcome")
+			}
+			if tt.wantStatus == domain.WorkflowRunStatusRunning {
+				assertAbout(t, "next run", run.NextRunAt, time.Now().Add(time.Hour))
+			}
+			if runs.updates != 1 {
+				t.Errorf("run saved %d times, want 1", runs.updates)
+			}
+		})
+	}
+}
+
+// TestWorkflowEngineRetriesFailedStep checks that a step that couldn't be
+// queued is left leased and unsaved, so it runs again once the lease expires.
+func TestWorkflowEngineRetriesFailedStep(t *testing.T) {
+	jobs := &fakeJobRepo{enqueueErr: errors.New("connection refused")}
+	runs := &fakeWorkflowRunRepo{}
+	engine := newTestWorkflowEngine(t, newFakeNotificationRepo(), jobs, runs, fakeWorkflows{"escalation": escalation})
+
+	leasedUntil := time.Now().Add(workflowLease)
+	run := domain.NewWorkflowRun("escalation", uuid.New(), domain.WorkflowInput{Title: "Hi", Body: "Body"}, leasedUntil)
+	runs.Create(context.Background(), run)
+
+	engine.advance(context.Background(), run)
+
+	if run.Step != 0 || run.Status != domain.WorkflowRunStatusRunning {
+		t.Errorf("run at step %d, %s; want step 0, running", run.Step, run.Status)
+	}
+	if !run.NextRunAt.Equal(leasedUntil) {
+		t.Errorf("next run = %v, want the lease expiry %v", run.NextRunAt, leasedUntil)
+	}
+	if runs.updates != 0 {
+		t.Errorf("run saved %d times, want 0", runs.updates)
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+// WebSocketTicket is a single-use credential for opening a WebSocket. It is
+// exchanged for the user's JWT so the JWT never appears in a URL, where it
+// would end up in proxy and access logs.
+type WebSocketTicket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TicketService issues and redeems WebSocket tickets.
+type TicketService struct {
+	tickets domain.WebSocketTicketRepository
+	ttl     time.Duration
+}
+
+// NewTicketService creates a ticket service whose tickets expire after ttl.
+func NewTicketService(tickets domain.WebSocketTicketRepository, ttl time.Duration) *TicketService {
+	return &TicketService{tickets: tickets, ttl: ttl}
+}
+
+// Issue creates a ticket for userID.
+func (s *TicketService) Issue(ctx context.Context, userID uuid.UUID) (*WebSocketTicket, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate ticket: %w", err)
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(raw)
+
+	expiresAt := time.Now().Add(s.ttl)
+	if err := s.tickets.Create(ctx, hashTicket(ticket), userID, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return &WebSocketTicket{Ticket: ticket, ExpiresAt: expiresAt}, nil
+}
+
+// Redeem uses up a ticket and returns the user it was issued to.
+// Returns ErrNotFound if the ticket is unknown, already used or expired.
+func (s *TicketService) Redeem(ctx context.Context, ticket string) (uuid.UUID, error) {
+	return s.tickets.Consume(ctx, hashTicket(ticket))
+}
+
+// hashTicket returns the hex SHA-256 of a ticket. Tickets are random, so a
+// plain hash is enough to keep a database leak from yielding usable ones.
+func hashTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,509 @@
+// Package templates loads the named notification templates (subject, text
+// body and HTML body) and renders them with notification data.
+//
+// Templates live in files/ and are embedded in the binary. Each template is a
+// set of files sharing a name:
+//
+//	otp_verification.json  manifest: subject, required variables, critical flag
+//	otp_verification.html  HTML body (html/template)
+//	otp_verification.txt   text body (text/template)
+//
+// The bodies define a "content" block that is placed into the shared layouts
+// in files/layouts (header and footer with the brand links). A directory
+// passed to Load overrides files by path, so copy can be changed without a
+// Go deploy.
+//
+// Translations are files with the locale before the extension, e.g.
+// otp_verification.de.html, otp_verification.de.txt and (optionally)
+// otp_verification.de.json for the subject and push copy. Layouts can be
+// translated the same way (layouts/footer.de.html). Rendering walks a
+// fallback chain from the requested locale to DefaultLocale: de-AT -> de -> en.
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+//go:embed files
+var embedded embed.FS
+
+// DefaultLocale is the locale of the template files without a locale suffix.
+const DefaultLocale = "en"
+
+// Manifest is the JSON file that declares a template's subject and variables.
+// Subject and push fields are text/templates rendered with the same data as the bodies.
+type Manifest struct {
+	Subject   string   `json:"subject"`
+	PushTitle string   `json:"push_title,omitempty"` // Optional: replaces the request title for push/in-app
+	PushBody  string   `json:"push_body,omitempty"`  // Optional: replaces the request body for push/in-app
+	Required  []string `json:"required"`             // Variables that must be present in the data (base manifest only)
+	Critical  bool     `json:"critical,omitempty"`   // Notifications from this template are always critical (base manifest only)
+}
+
+// Template is a loaded, parsed template with all of its translations.
+type Template struct {
+	Name     string
+	Required []string
+	Critical bool
+
+	variants map[string]*variant // By lower-cased locale
+}
+
+// variant is one translation of a template.
+type variant struct {
+	locale    string
+	subject   *texttemplate.Template
+	pushTitle *texttemplate.Template // nil if the manifest has no push copy
+	pushBody  *texttemplate.Template
+	text      *texttemplate.Template
+	html      *htmltemplate.Template
+}
+
+// Rendered is the output of rendering a template.
+type Rendered struct {
+	Locale    string `json:"locale"` // The translation that was used
+	Subject   string `json:"subject"`
+	Text      string `json:"text"`
+	HTML      string `json:"html"`
+	PushTitle string `json:"push_title,omitempty"`
+	PushBody  string `json:"push_body,omitempty"`
+}
+
+// ErrMissingVariables is returned when the data lacks variables a template requires.
+type ErrMissingVariables struct {
+	Template  string
+	Variables []string
+}
+
+func (e *ErrMissingVariables) Error() string {
+	return fmt.Sprintf("template %s is missing required variables: %s", e.Template, strings.Join(e.Variables, ", "))
+}
+
+// Registry holds all loaded templates by name.
+type Registry struct {
+	templates map[string]*Template
+}
+
+// Load parses every template from the embedded files, with files under dir
+// (if not empty) taking precedence over the embedded ones.
+func Load(dir string) (*Registry, error) {
+	files, err := fs.Sub(embedded, "files")
+	if err != nil {
+		return nil, err
+	}
+
+	src := &overlay{base: files}
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("templates directory: %w", err)
+		}
+		src.override = os.DirFS(dir)
+	}
+
+	manifests, err := src.glob("*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{templates: make(map[string]*Template)}
+	for _, manifestPath := range manifests {
+		name, locale, _ := splitPath(manifestPath)
+		if locale != "" {
+			continue // Translation manifests are loaded with their template
+		}
+
+		t, err := loadTemplate(src, name)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		r.templates[name] = t
+	}
+
+	return r, nil
+}
+
+// loadTemplate parses a template's default files and every translation of it.
+func loadTemplate(src *overlay, name string) (*Template, error) {
+	manifest, err := readManifest(src, name+".json")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Template{
+		Name:     name,
+		Required: manifest.Required,
+		Critical: manifest.Critical,
+		variants: make(map[string]*variant),
+	}
+
+	base, err := loadVariant(src, name, "", manifest)
+	if err != nil {
+		return nil, err
+	}
+	t.variants[DefaultLocale] = base
+
+	translations, err := src.glob(name + ".*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range translations {
+		_, locale, _ := splitPath(p)
+
+		// A translation without its own manifest keeps the default subject and push copy
+		localized := *manifest
+		if exists, err := src.exists(name + "." + locale + ".json"); err != nil {
+			return nil, err
+		} else if exists {
+			m, err := readManifest(src, name+"."+locale+".json")
+			if err != nil {
+				return nil, err
+			}
+			localized.Subject, localized.PushTitle, localized.PushBody = m.Subject, m.PushTitle, m.PushBody
+		}
+
+		v, err := loadVariant(src, name, locale, &localized)
+		if err != nil {
+			return nil, fmt.Errorf("locale %s: %w", locale, err)
+		}
+		t.variants[strings.ToLower(locale)] = v
+	}
+
+	return t, nil
+}
+
+// loadVariant parses one translation of a template ("" is the default) on top of the layouts.
+func loadVariant(src *overlay, name, locale string, manifest *Manifest) (*variant, error) {
+	suffix := ""
+	v := &variant{locale: DefaultLocale}
+	if locale != "" {
+		suffix = "." + locale
+		v.locale = locale
+	}
+
+	var err error
+	if v.subject, err = texttemplate.New("subject").Parse(manifest.Subject); err != nil {
+		return nil, fmt.Errorf("invalid subject: %w", err)
+	}
+	if manifest.PushTitle != "" {
+		if v.pushTitle, err = texttemplate.New("push_title").Parse(manifest.PushTitle); err != nil {
+			return nil, fmt.Errorf("invalid push_title: %w", err)
+		}
+	}
+	if manifest.PushBody != "" {
+		if v.pushBody, err = texttemplate.New("push_body").Parse(manifest.PushBody); err != nil {
+			return nil, fmt.Errorf("invalid push_body: %w", err)
+		}
+	}
+
+	// Each variant redefines "content" (and maybe translated layout blocks),
+	// so it gets its own template set
+	funcs := htmltemplate.FuncMap{"locale": func() string { return v.locale }}
+	htmlSet, err := parseHTML(src, htmltemplate.New("layout").Funcs(funcs), layoutPaths(src, "html", locale))
+	if err != nil {
+		return nil, err
+	}
+	if v.html, err = parseHTML(src, htmlSet, []string{name + suffix + ".html"}); err != nil {
+		return nil, err
+	}
+
+	textSet, err := parseText(src, texttemplate.New("layout"), layoutPaths(src, "txt", locale))
+	if err != nil {
+		return nil, err
+	}
+	if v.text, err = parseText(src, textSet, []string{name + suffix + ".txt"}); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// layoutPaths lists the layout files with the given extension: the default
+// ones first, then the translations for locale, which redefine their blocks.
+func layoutPaths(src *overlay, ext, locale string) []string {
+	all, _ := src.glob("layouts/*." + ext)
+
+	var defaults, localized []string
+	for _, p := range all {
+		_, l, _ := splitPath(p)
+		switch {
+		case l == "":
+			defaults = append(defaults, p)
+		case locale != "" && strings.EqualFold(l, locale):
+			localized = append(localized, p)
+		}
+	}
+	return append(defaults, localized...)
+}
+
+// readManifest reads and decodes a manifest file.
+func readManifest(src *overlay, p string) (*Manifest, error) {
+	raw, err := src.readFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", p, err)
+	}
+	return &manifest, nil
+}
+
+// splitPath splits "dir/name.locale.ext" into name, locale ("" if none) and ext.
+func splitPath(p string) (name, locale, ext string) {
+	base := path.Base(p)
+	ext = path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if i := strings.Index(stem, "."); i >= 0 {
+		return stem[:i], stem[i+1:], ext
+	}
+	return stem, "", ext
+}
+
+// Fallbacks returns the locales tried for locale, most specific first and
+// always ending with DefaultLocale: "de-AT" gives de-AT, de, en.
+func Fallbacks(locale string) []string {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+
+	var chain []string
+	for locale != "" {
+		chain = append(chain, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+
+	if len(chain) == 0 || !strings.EqualFold(chain[len(chain)-1], DefaultLocale) {
+		chain = append(chain, DefaultLocale)
+	}
+	return chain
+}
+
+// Has reports whether a template with the given name exists.
+func (r *Registry) Has(name string) bool {
+	_, ok := r.templates[name]
+	return ok
+}
+
+// Critical reports whether the named template's manifest marks its
+// notifications critical. Unknown templates aren't.
+func (r *Registry) Critical(name string) bool {
+	t, ok := r.templates[name]
+	return ok && t.Critical
+}
+
+// Get returns a template by name.
+func (r *Registry) Get(name string) (*Template, error) {
+	t, ok := r.templates[name]
+	if !ok {
+		return nil, domain.NewErrNotFound("template", name)
+	}
+	return t, nil
+}
+
+// Names returns the names of all loaded templates, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render renders the named template in the best available translation for locale.
+// Returns ErrNotFound for unknown templates and ErrMissingVariables if data
+// lacks any of the template's required variables.
+func (r *Registry) Render(name, locale string, data map[string]interface{}) (*Rendered, error) {
+	t, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.Render(locale, data)
+}
+
+// Locales returns the locales a template is available in, sorted.
+func (t *Template) Locales() []string {
+	locales := make([]string, 0, len(t.variants))
+	for _, v := range t.variants {
+		locales = append(locales, v.locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Missing returns the required variables that are absent from data.
+func (t *Template) Missing(data map[string]interface{}) []string {
+	var missing []string
+	for _, v := range t.Required {
+		if value, ok := data[v]; !ok || value == nil || value == "" {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+// Render renders the template's subject, bodies and push copy with data,
+// using the first translation in the fallback chain for locale.
+func (t *Template) Render(locale string, data map[string]interface{}) (*Rendered, error) {
+	if missing := t.Missing(data); len(missing) > 0 {
+		return nil, &ErrMissingVariables{Template: t.Name, Variables: missing}
+	}
+
+	v := t.variants[DefaultLocale]
+	for _, l := range Fallbacks(locale) {
+		if found, ok := t.variants[strings.ToLower(l)]; ok {
+			v = found
+			break
+		}
+	}
+
+	subject, err := execText(v.subject, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render subject: %w", err)
+	}
+	pushTitle, err := execText(v.pushTitle, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render push title: %w", err)
+	}
+	pushBody, err := execText(v.pushBody, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render push body: %w", err)
+	}
+
+	var text, html bytes.Buffer
+	if err := v.text.ExecuteTemplate(&text, "base", data); err != nil {
+		return nil, fmt.Errorf("failed to render text body: %w", err)
+	}
+	if err := v.html.ExecuteTemplate(&html, "base", data); err != nil {
+		return nil, fmt.Errorf("failed to render HTML body: %w", err)
+	}
+
+	return &Rendered{
+		Locale:    v.locale,
+		Subject:   subject,
+		Text:      strings.TrimSpace(text.String()),
+		HTML:      html.String(),
+		PushTitle: pushTitle,
+		PushBody:  pushBody,
+	}, nil
+}
+
+// execText renders a one-line text template; a nil template renders as "".
+func execText(t *texttemplate.Template, data map[string]interface{}) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// parseHTML parses the files at paths into set.
+func parseHTML(src *overlay, set *htmltemplate.Template, paths []string) (*htmltemplate.Template, error) {
+	for _, p := range paths {
+		content, err := src.readFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := set.New(p).Parse(string(content)); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// parseText parses the files at paths into set.
+func parseText(src *overlay, set *texttemplate.Template, paths []string) (*texttemplate.Template, error) {
+	for _, p := range paths {
+		content, err := src.readFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := set.New(p).Parse(string(content)); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// overlay reads files from override when present there, otherwise from base.
+type overlay struct {
+	base     fs.FS
+	override fs.FS // Optional
+}
+
+// readFile reads a file, preferring the override directory.
+func (o *overlay) readFile(name string) ([]byte, error) {
+	if o.override != nil {
+		content, err := fs.ReadFile(o.override, name)
+		if err == nil {
+			return content, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return fs.ReadFile(o.base, name)
+}
+
+// exists reports whether a file exists in either file system.
+func (o *overlay) exists(name string) (bool, error) {
+	for _, fsys := range []fs.FS{o.override, o.base} {
+		if fsys == nil {
+			continue
+		}
+		_, err := fs.Stat(fsys, name)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// glob returns the sorted union of the paths matching pattern in both file systems.
+func (o *overlay) glob(pattern string) ([]string, error) {
+	seen := make(map[string]bool)
+
+	for _, fsys := range []fs.FS{o.base, o.override} {
+		if fsys == nil {
+			continue
+		}
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			seen[path.Clean(m)] = true
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,124 @@
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestWelcomeTrialOnlyShowsTrialWhenTrue renders welcome_trial with the
+// values callers send for hasTrial. Data usually arrives as JSON from other
+// services, so "false" as a string must hide the trial like false does.
+func TestWelcomeTrialOnlyShowsTrialWhenTrue(t *testing.T) {
+	registry, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		hasTrial  interface{}
+		wantTrial bool
+	}{
+		{"bool true", true, true},
+		{"string true", "true", true},
+		{"bool false", false, false},
+		{"string false", "false", false},
+		{"missing", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"name": "Ada"}
+			if tt.hasTrial != nil {
+				data["hasTrial"] = tt.hasTrial
+			}
+
+			rendered, err := registry.Render("welcome_trial", "", data)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			outputs := map[string]string{"html": rendered.HTML, "text": rendered.Text, "push body": rendered.PushBody}
+			for part, out := range outputs {
+				if got := strings.Contains(out, "free trial"); got != tt.wantTrial {
+					t.Errorf("%s mentions the free trial: %v, want %v", part, got, tt.wantTrial)
+				}
+			}
+		})
+	}
+}
+
+func TestFallbacks(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"de-AT", []string{"de-AT", "de", "en"}},
+		{"de", []string{"de", "en"}},
+		{"de_AT", []string{"de-AT", "de", "en"}},
+		{" de-AT ", []string{"de-AT", "de", "en"}},
+		{"zh-Hant-TW", []string{"zh-Hant-TW", "zh-Hant", "zh", "en"}},
+		{"en-GB", []string{"en-GB", "en"}},
+		{"en", []string{"en"}},
+		{"EN", []string{"EN"}},
+		{"", []string{"en"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			if got := Fallbacks(tt.locale); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fallbacks(%q) = %v, want %v", tt.locale, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderOTPVerificationLocales renders the shipped otp_verification
+// template, which has a German translation, in several locales.
+func TestRenderOTPVerificationLocales(t *testing.T) {
+	registry, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		locale      string
+		wantLocale  string
+		wantSubject string
+		wantText    string
+	}{
+		{"de-AT", "de", "Dein PrepMyApp-Bestätigungscode", "Dein PrepMyApp-Bestätigungscode lautet: 123456"},
+		{"de", "de", "Dein PrepMyApp-Bestätigungscode", "Dein PrepMyApp-Bestätigungscode lautet: 123456"},
+		{"fr-FR", "en", "Your PrepMyApp verification code", "Your PrepMyApp verification code is: 123456"},
+		{"", "en", "Your PrepMyApp verification code", "Your PrepMyApp verification code is: 123456"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			rendered, err := registry.Render("otp_verification", tt.locale, map[string]interface{}{"otp": "123456"})
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+
+			if rendered.Locale != tt.wantLocale {
+				t.Errorf("locale = %q, want %q", rendered.Locale, tt.wantLocale)
+			}
+			if rendered.Subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", rendered.Subject, tt.wantSubject)
+			}
+			if !strings.Contains(rendered.Text, tt.wantText) {
+				t.Errorf("text = %q, want it to contain %q", rendered.Text, tt.wantText)
+			}
+			if !strings.Contains(rendered.HTML, "123456") {
+				t.Error("HTML body is missing the code")
+			}
+		})
+	}
+
+	var missing *ErrMissingVariables
+	if _, err := registry.Render("otp_verification", "de", nil); !errors.As(err, &missing) {
+		t.Errorf("rendering without otp: %v, want missing variables", err)
+	}
+}
@@ -0,0 +1,145 @@
+// Package workflows loads the named multi-step notification workflows that
+// the internal API can start by name.
+//
+// Each workflow is a JSON file in files/ (embedded in the binary):
+//
+//	{
+//	  "name": "interview_reminder",
+//	  "template": "interview_reminder",
+//	  "steps": [
+//	    {"name": "notify", "channels": ["in_app", "push"]},
+//	    {"name": "email", "channels": ["email"], "delay": "30m", "condition": "unread"}
+//	  ]
+//	}
+//
+// A step runs its delay after the previous one, if its condition holds for
+// the notifications the earlier steps sent. See service.WorkflowEngine.
+package workflows
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/prepmyapp/notification/internal/domain"
+)
+
+//go:embed files
+var embedded embed.FS
+
+// Registry holds all loaded workflows by name.
+type Registry struct {
+	workflows map[string]*domain.Workflow
+}
+
+// Load reads and validates every embedded workflow definition.
+func Load() (*Registry, error) {
+	paths, err := fs.Glob(embedded, "files/*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{workflows: make(map[string]*domain.Workflow)}
+	for _, p := range paths {
+		data, err := embedded.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		var w domain.Workflow
+		if err := json.Unmarshal(data, &w); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if err := validate(&w); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if _, ok := r.workflows[w.Name]; ok {
+			return nil, fmt.Errorf("%s: duplicate workflow %s", p, w.Name)
+		}
+
+		r.workflows[w.Name] = &w
+	}
+
+	return r, nil
+}
+
+// validate checks a workflow definition, so mistakes fail at startup rather
+// than halfway through a run.
+func validate(w *domain.Workflow) error {
+	if w.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(w.Steps) == 0 {
+		return fmt.Errorf("workflow %s has no steps", w.Name)
+	}
+
+	names := make(map[string]bool)
+	for i, step := range w.Steps {
+		if step.Name == "" || names[step.Name] {
+			return fmt.Errorf("step %d needs a unique name", i)
+		}
+		names[step.Name] = true
+
+		if len(step.Channels) == 0 {
+			return fmt.Errorf("step %s has no channels", step.Name)
+		}
+		for _, ch := range step.Channels {
+			switch ch {
+			case domain.NotificationTypeEmail, domain.NotificationTypePush, domain.NotificationTypeInApp:
+			default:
+				return fmt.Errorf("step %s: unknown channel %q", step.Name, ch)
+			}
+		}
+
+		if _, err := ParseDelay(step.Delay); err != nil {
+			return fmt.Errorf("step %s: %w", step.Name, err)
+		}
+
+		switch step.Condition {
+		case "", domain.WorkflowConditionAlways, domain.WorkflowConditionUnread,
+			domain.WorkflowConditionNotDelivered, domain.WorkflowConditionFailed:
+		default:
+			return fmt.Errorf("step %s: unknown condition %q", step.Name, step.Condition)
+		}
+	}
+
+	return nil
+}
+
+// ParseDelay parses a step delay such as "30m"; "" means no delay.
+func ParseDelay(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid delay %q: %w", s, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid delay %q: must not be negative", s)
+	}
+	return d, nil
+}
+
+// Get returns a workflow by name.
+func (r *Registry) Get(name string) (*domain.Workflow, error) {
+	w, ok := r.workflows[name]
+	if !ok {
+		return nil, domain.NewErrNotFound("workflow", name)
+	}
+	return w, nil
+}
+
+// Names returns the names of all loaded workflows, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.workflows))
+	for name := range r.workflows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,16 @@
+-- Delivery outbox: one job per notification awaiting delivery
+CREATE TABLE IF NOT EXISTS delivery_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    payload JSONB DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    locked_by VARCHAR(100),
+    locked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Indexes for delivery_jobs
+CREATE INDEX IF NOT EXISTS idx_delivery_jobs_notification_id ON delivery_jobs(notification_id);
+CREATE INDEX IF NOT EXISTS idx_delivery_jobs_pending ON delivery_jobs(created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_delivery_jobs_processing ON delivery_jobs(locked_at) WHERE status = 'processing';
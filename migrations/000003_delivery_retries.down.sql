@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_delivery_jobs_dead_lettered;
+DROP INDEX IF EXISTS idx_delivery_jobs_pending;
+CREATE INDEX IF NOT EXISTS idx_delivery_jobs_pending ON delivery_jobs(created_at) WHERE status = 'pending';
+
+ALTER TABLE delivery_jobs DROP COLUMN IF EXISTS last_error;
+ALTER TABLE delivery_jobs DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE delivery_jobs DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,9 @@
+-- Retry bookkeeping for delivery jobs
+ALTER TABLE delivery_jobs ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE delivery_jobs ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE delivery_jobs ADD COLUMN IF NOT EXISTS last_error TEXT;
+
+-- Pending jobs are now claimed in next_attempt_at order
+DROP INDEX IF EXISTS idx_delivery_jobs_pending;
+CREATE INDEX IF NOT EXISTS idx_delivery_jobs_pending ON delivery_jobs(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_delivery_jobs_dead_lettered ON delivery_jobs(updated_at DESC) WHERE status = 'dead_lettered';
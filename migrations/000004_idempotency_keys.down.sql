@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_notifications_idempotency_key;
+ALTER TABLE notifications DROP COLUMN IF EXISTS idempotency_key;
+
+DROP TABLE IF EXISTS idempotency_keys;
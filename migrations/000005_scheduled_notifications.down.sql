@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_delivery_jobs_scheduled;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS scheduled_at;
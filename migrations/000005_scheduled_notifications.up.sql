@@ -0,0 +1,5 @@
+-- Scheduled notifications: the job waits in 'scheduled' until the scheduler
+-- releases it into the pending queue at next_attempt_at
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_delivery_jobs_scheduled ON delivery_jobs(next_attempt_at) WHERE status = 'scheduled';
@@ -0,0 +1,2 @@
+-- Why a notification's delivery was pushed back (e.g. 'quiet_hours')
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS defer_reason VARCHAR(50);
@@ -0,0 +1,2 @@
+-- IANA time zone the user's quiet hours are expressed in
+ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,2 @@
+-- Locale (BCP 47 tag) templated notifications are rendered in; '' means the default
+ALTER TABLE notification_preferences ADD COLUMN IF NOT EXISTS locale VARCHAR(35) NOT NULL DEFAULT '';
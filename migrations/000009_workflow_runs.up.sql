@@ -0,0 +1,18 @@
+-- Workflow runs: durable state of multi-step notification workflows
+CREATE TABLE IF NOT EXISTS workflow_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workflow VARCHAR(100) NOT NULL,
+    user_id UUID NOT NULL,
+    input JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    step INTEGER NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    notification_ids JSONB NOT NULL DEFAULT '[]',
+    outcome TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- The engine polls running runs in next_run_at order
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_due ON workflow_runs(next_run_at) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_user_id ON workflow_runs(user_id);
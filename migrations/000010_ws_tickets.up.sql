@@ -0,0 +1,10 @@
+-- Single-use tickets that authenticate WebSocket connections.
+-- Only a SHA-256 hash of each ticket is stored.
+CREATE TABLE IF NOT EXISTS ws_tickets (
+    ticket_hash VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ws_tickets_expires_at ON ws_tickets(expires_at);
@@ -0,0 +1,20 @@
+-- Delivery history reported by providers after sending
+-- (delivered, bounced, dropped, opened, clicked, spam_report)
+CREATE TABLE IF NOT EXISTS notification_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    provider_event_id VARCHAR(255),
+    recipient VARCHAR(255),
+    reason TEXT,
+    data JSONB DEFAULT '{}',
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_events_notification_id ON notification_events(notification_id, occurred_at);
+
+-- Providers redeliver webhooks; each event is stored once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_events_provider_event
+    ON notification_events(provider, provider_event_id) WHERE provider_event_id IS NOT NULL;
@@ -0,0 +1,11 @@
+-- Email addresses that must not be emailed again (hard bounces, spam
+-- reports, unsubscribes). Addresses are stored lowercased.
+CREATE TABLE IF NOT EXISTS email_suppressions (
+    email VARCHAR(255) PRIMARY KEY,
+    reason VARCHAR(50) NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    details TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_suppressions_created_at ON email_suppressions(created_at);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_notifications_idempotency_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_idempotency_key
+    ON notifications(idempotency_key, user_id, type)
+    WHERE idempotency_key IS NOT NULL;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS idempotency_expires_at;
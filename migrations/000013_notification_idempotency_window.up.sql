@@ -0,0 +1,14 @@
+-- Notification idempotency keys only dedupe for as long as the request's
+-- idempotency key is replayed (IDEMPOTENCY_KEY_TTL); NULL never expires.
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS idempotency_expires_at TIMESTAMP;
+
+-- Keys are now unique per user: each channel of a request stores its own key,
+-- so a fallback that changes a notification's type can't break the lookup
+UPDATE notifications SET idempotency_key = idempotency_key || ':' || type
+    WHERE idempotency_key IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_notifications_idempotency_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_idempotency_key
+    ON notifications(idempotency_key, user_id)
+    WHERE idempotency_key IS NOT NULL;